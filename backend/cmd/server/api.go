@@ -1,13 +1,21 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
 	"net/http"
 	"time"
 
+	"github.com/Bwise1/interstellar/internal/alerts"
 	"github.com/Bwise1/interstellar/internal/auditlogs"
+	"github.com/Bwise1/interstellar/internal/exchange"
 	"github.com/Bwise1/interstellar/internal/fxrates"
+	"github.com/Bwise1/interstellar/internal/marketdata"
 	"github.com/Bwise1/interstellar/internal/middleware"
+	"github.com/Bwise1/interstellar/internal/orders"
+	"github.com/Bwise1/interstellar/internal/rbac"
+	"github.com/Bwise1/interstellar/internal/savings"
 	"github.com/Bwise1/interstellar/internal/transactions"
 	"github.com/Bwise1/interstellar/internal/users"
 	"github.com/Bwise1/interstellar/internal/wallets"
@@ -25,60 +33,165 @@ func (app *application) mount() http.Handler {
 	r.Use(chimiddleware.RealIP)
 	r.Use(chimiddleware.Logger)
 	r.Use(chimiddleware.Recoverer)
-	r.Use(chimiddleware.Timeout(60 * time.Second))
 
-	// Health check
-	r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.Write([]byte("server healthy"))
-	})
+	// Long-lived streams are mounted outside the request timeout
+	r.Get("/api/fx-rates/stream", app.fxHandler.Stream) // Live rate updates (SSE) for subscribed pairs
 
-	// API routes
-	r.Route("/api", func(r chi.Router) {
-		// Auth routes (public) - with audit logging
-		r.Route("/auth", func(r chi.Router) {
-			r.Use(middleware.AuditMiddleware(app.auditService))
-			r.Post("/register", app.userHandler.Register)
-			r.Post("/login", app.userHandler.Login)
-		})
+	r.Group(func(r chi.Router) {
+		r.Use(chimiddleware.Timeout(60 * time.Second))
 
-		// FX Rates routes (public - no auth required)
-		r.Route("/fx-rates", func(r chi.Router) {
-			r.Get("/", app.fxHandler.GetAllRates)              // Get all rates (default USD base)
-			r.Get("/{currency}", app.fxHandler.GetRates)       // Get rates for specific base currency
-			r.Post("/convert", app.fxHandler.Convert)          // Convert between currencies
-			r.Post("/refresh", app.fxHandler.RefreshRates)    // Force refresh cache
+		// Health check
+		r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("server healthy"))
 		})
 
-		// Protected routes (require JWT authentication)
-		r.Group(func(r chi.Router) {
-			r.Use(middleware.AuthMiddleware)
-			r.Use(middleware.AuditMiddleware(app.auditService))
-
-			// Wallet routes
-			r.Route("/wallets", func(r chi.Router) {
-				r.Get("/", app.walletHandler.GetWallet)                    // Get user's wallet
-				r.Get("/{id}", app.walletHandler.GetWalletByID)            // Get wallet by ID
-				r.Get("/balance/{currency}", app.walletHandler.GetBalance) // Get specific currency balance
-				r.Get("/balances", app.walletHandler.GetAllBalances)       // Get all balances
+		// API routes
+		r.Route("/api", func(r chi.Router) {
+			// Auth routes (public) - with audit logging
+			r.Route("/auth", func(r chi.Router) {
+				r.Use(middleware.AuditMiddleware(app.auditService))
+				r.Post("/register", app.userHandler.Register)
+				r.Post("/login", app.userHandler.Login)
+				r.Post("/login/2fa", app.userHandler.LoginTwoFactor) // Complete login with a TOTP or recovery code
+				r.Post("/refresh", app.userHandler.Refresh)          // Rotate a refresh token for new tokens
+
+				r.Group(func(r chi.Router) {
+					r.Use(middleware.AuthMiddleware(app.tokenDenylist))
+
+					r.Post("/logout", app.userHandler.Logout)        // Revoke this session
+					r.Post("/logout-all", app.userHandler.LogoutAll) // Revoke every session
+				})
 			})
 
-			// Transaction routes
-			r.Route("/transactions", func(r chi.Router) {
-				r.Post("/deposit", app.transactionHandler.Deposit)     // Deposit funds
-				r.Post("/swap", app.transactionHandler.Swap)           // Swap currencies
-				r.Post("/transfer", app.transactionHandler.Transfer)   // Transfer to another wallet
-				r.Get("/", app.transactionHandler.GetTransactions)     // Get all transactions
-				r.Get("/{id}", app.transactionHandler.GetTransaction)  // Get transaction by ID
+			// FX Rates routes (public - no auth required)
+			r.Route("/fx-rates", func(r chi.Router) {
+				r.Get("/", app.fxHandler.GetAllRates)        // Get all rates (default USD base)
+				r.Get("/history", app.fxHandler.GetHistory)  // Get stored rate history for a pair
+				r.Get("/{currency}", app.fxHandler.GetRates) // Get rates for specific base currency
+				r.Post("/convert", app.fxHandler.Convert)    // Convert between currencies
+
+				// Rate alerts (require JWT authentication)
+				r.Route("/alerts", func(r chi.Router) {
+					r.Use(middleware.AuthMiddleware(app.tokenDenylist))
+
+					r.Post("/", app.alertHandler.CreateAlert)                  // Create a rate alert
+					r.Get("/", app.alertHandler.GetAlerts)                     // List user's rate alerts
+					r.Get("/notifications", app.alertHandler.GetNotifications) // List triggered alert notifications
+					r.Get("/{id}", app.alertHandler.GetAlert)                  // Get rate alert by ID
+					r.Put("/{id}", app.alertHandler.UpdateAlert)               // Replace a rate alert and re-arm it
+					r.Delete("/{id}", app.alertHandler.DeleteAlert)            // Delete a rate alert
+				})
 			})
 
-			// Audit logs routes
-			r.Route("/audit-logs", func(r chi.Router) {
-				r.Get("/", app.auditHandler.GetUserAuditLogs) // Get user's audit logs
+			// Market data (public)
+			r.Route("/markets", func(r chi.Router) {
+				r.Get("/", app.exchangeHandler.GetMarkets)             // List pairs traded on the order book
+				r.Get("/{pair}/depth", app.exchangeHandler.GetDepth)   // Aggregated bids and asks
+				r.Get("/{pair}/trades", app.exchangeHandler.GetTrades) // Recent trades
+				r.Get("/{pair}/candles", app.marketHandler.GetCandles) // OHLC candles from snapshots and executions
+				r.Get("/{pair}/stats", app.marketHandler.GetStats)     // 24h change, range and volume
 			})
 
-			// User routes
-			r.Route("/users", func(r chi.Router) {
-				r.Post("/verify-password", app.userHandler.VerifyPassword) // Verify password
+			// Protected routes (require JWT authentication)
+			r.Group(func(r chi.Router) {
+				r.Use(middleware.AuthMiddleware(app.tokenDenylist))
+				r.Use(middleware.AuditMiddleware(app.auditService))
+
+				// Wallet routes
+				r.Route("/wallets", func(r chi.Router) {
+					r.Get("/", app.walletHandler.GetWallet)                         // Get user's wallet
+					r.Get("/{id}", app.walletHandler.GetWalletByID)                 // Get wallet by ID
+					r.Get("/balance/{currency}", app.walletHandler.GetBalance)      // Get specific currency balance
+					r.Get("/balances", app.walletHandler.GetAllBalances)            // Get all balances (optionally at a past time)
+					r.Get("/balances/history", app.walletHandler.GetBalanceHistory) // Get daily balance history
+					r.Get("/valuation", app.walletHandler.GetValuation)             // Get portfolio value in a reporting currency
+				})
+
+				// Transaction routes
+				r.Route("/transactions", func(r chi.Router) {
+					r.Post("/deposit", app.transactionHandler.Deposit)                  // Deposit funds
+					r.Post("/swap", app.transactionHandler.Swap)                        // Swap currencies
+					r.Post("/swap/preview", app.transactionHandler.SwapPreview)         // Dry-run a swap: breakdown and blocking rules
+					r.Post("/transfer", app.transactionHandler.Transfer)                // Transfer to another wallet (large transfers need X-TOTP-Code)
+					r.Post("/transfer/preview", app.transactionHandler.TransferPreview) // Dry-run a transfer: breakdown and blocking rules
+					r.Get("/", app.transactionHandler.GetTransactions)                  // Get all transactions
+					r.Get("/{id}", app.transactionHandler.GetTransaction)               // Get transaction by ID
+				})
+
+				// Savings routes
+				r.Route("/savings", func(r chi.Router) {
+					r.Get("/rates", app.savingsHandler.GetRates)                  // Get APR per currency
+					r.Post("/pockets", app.savingsHandler.CreatePocket)           // Lock funds into a pocket
+					r.Get("/pockets", app.savingsHandler.GetPockets)              // List pockets with accrued interest
+					r.Get("/pockets/{id}", app.savingsHandler.GetPocket)          // Get pocket by ID
+					r.Post("/pockets/{id}/close", app.savingsHandler.ClosePocket) // Close pocket and unlock principal
+				})
+
+				// Limit order routes
+				r.Route("/orders", func(r chi.Router) {
+					r.Post("/", app.orderHandler.PlaceOrder)             // Place a limit order and reserve funds
+					r.Get("/", app.orderHandler.GetOrders)               // List user's limit orders (optionally by status)
+					r.Get("/{id}", app.orderHandler.GetOrder)            // Get limit order by ID
+					r.Post("/{id}/cancel", app.orderHandler.CancelOrder) // Cancel an open order and release funds
+				})
+
+				// Order book routes
+				r.Route("/exchange/orders", func(r chi.Router) {
+					r.Post("/", app.exchangeHandler.PlaceOrder)             // Post a bid or ask; matches immediately where it crosses
+					r.Get("/", app.exchangeHandler.GetOrders)               // List user's order book orders (optionally by status)
+					r.Get("/{id}", app.exchangeHandler.GetOrder)            // Get order by ID
+					r.Post("/{id}/cancel", app.exchangeHandler.CancelOrder) // Cancel the unfilled remainder and release funds
+				})
+
+				// Audit logs routes
+				r.Route("/audit-logs", func(r chi.Router) {
+					r.Get("/", app.auditHandler.GetUserAuditLogs) // Get user's own audit logs
+				})
+
+				// Admin routes, each group guarded by the permission it needs
+				r.Route("/admin", func(r chi.Router) {
+					r.Group(func(r chi.Router) {
+						r.Use(middleware.RequirePermission(rbac.PermAuditRead))
+
+						r.Get("/audit-logs", app.auditHandler.GetAuditLogs) // Get every user's audit logs (optionally by user_id or operation)
+					})
+
+					r.Group(func(r chi.Router) {
+						r.Use(middleware.RequirePermission(rbac.PermWalletsManage))
+
+						r.Put("/wallets/{id}/status", app.walletHandler.ChangeStatus) // Freeze, unfreeze or close a wallet
+					})
+
+					r.Group(func(r chi.Router) {
+						r.Use(middleware.RequirePermission(rbac.PermFXManage))
+
+						r.Get("/fx-rates/quarantine", app.fxHandler.GetQuarantined)        // List rates held back as anomalous
+						r.Post("/fx-rates/overrides", app.fxHandler.SetOverride)           // Pin a rate for a pair until it expires
+						r.Get("/fx-rates/overrides", app.fxHandler.GetOverrides)           // List active rate overrides
+						r.Delete("/fx-rates/overrides/{id}", app.fxHandler.RemoveOverride) // Remove a rate override
+						r.Post("/fx-rates/refresh", app.fxHandler.RefreshRates)            // Force refresh cache (subject to cooldown)
+						r.Get("/fx-rates/quota", app.fxHandler.GetQuotaUsage)              // Upstream provider quota usage
+					})
+
+					r.Group(func(r chi.Router) {
+						r.Use(middleware.RequirePermission(rbac.PermRolesManage))
+
+						r.Get("/roles", app.userHandler.GetRoleDefinitions)      // List grantable roles and their permissions
+						r.Get("/users/{id}/roles", app.userHandler.GetUserRoles) // List a user's roles
+						r.Put("/users/{id}/roles", app.userHandler.SetUserRoles) // Replace a user's roles
+					})
+				})
+
+				// User routes
+				r.Route("/users", func(r chi.Router) {
+					r.Get("/2fa", app.userHandler.GetTwoFactorStatus)                      // Two-factor status and recovery codes left
+					r.Post("/2fa/setup", app.userHandler.SetupTwoFactor)                   // Start TOTP enrolment: secret and provisioning URI
+					r.Post("/2fa/enable", app.userHandler.EnableTwoFactor)                 // Confirm a code to enable 2FA; returns recovery codes
+					r.Post("/2fa/disable", app.userHandler.DisableTwoFactor)               // Disable 2FA with a TOTP or recovery code
+					r.Post("/2fa/recovery-codes", app.userHandler.RegenerateRecoveryCodes) // Replace recovery codes
+					r.Get("/sessions", app.userHandler.GetSessions)                        // List active sessions and devices
+					r.Delete("/sessions/{id}", app.userHandler.RevokeSession)              // Sign a session out
+				})
 			})
 		})
 	})
@@ -86,7 +199,10 @@ func (app *application) mount() http.Handler {
 	return r
 }
 
-func (app *application) run(handler http.Handler) error {
+// shutdownTimeout bounds how long in-flight requests get to finish after a shutdown signal
+const shutdownTimeout = 15 * time.Second
+
+func (app *application) run(ctx context.Context, handler http.Handler) error {
 	srv := &http.Server{
 		Addr:         app.config.addr,
 		Handler:      handler,
@@ -95,18 +211,45 @@ func (app *application) run(handler http.Handler) error {
 		IdleTimeout:  time.Minute,
 	}
 
-	log.Printf("server has started at addr %s", app.config.addr)
+	errCh := make(chan error, 1)
+	go func() {
+		log.Printf("server has started at addr %s", app.config.addr)
+		errCh <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Printf("shutting down server")
 
-	return srv.ListenAndServe()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-errCh; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
 }
 
 type application struct {
 	config             config
+	tokenDenylist      middleware.TokenDenylist
 	db                 *pgxpool.Pool
 	userHandler        *users.Handler
 	walletHandler      *wallets.Handler
 	transactionHandler *transactions.Handler
+	savingsHandler     *savings.Handler
+	orderHandler       *orders.Handler
+	exchangeHandler    *exchange.Handler
+	marketHandler      *marketdata.Handler
 	fxHandler          *fxrates.Handler
+	alertHandler       *alerts.Handler
 	auditService       *auditlogs.Service
 	auditHandler       *auditlogs.Handler
 }
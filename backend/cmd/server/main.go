@@ -2,12 +2,23 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"log/slog"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
+	"github.com/Bwise1/interstellar/internal/alerts"
 	"github.com/Bwise1/interstellar/internal/auditlogs"
+	"github.com/Bwise1/interstellar/internal/exchange"
 	"github.com/Bwise1/interstellar/internal/fxrates"
+	"github.com/Bwise1/interstellar/internal/marketdata"
+	"github.com/Bwise1/interstellar/internal/orders"
+	"github.com/Bwise1/interstellar/internal/savings"
 	"github.com/Bwise1/interstellar/internal/transactions"
 	"github.com/Bwise1/interstellar/internal/users"
 	"github.com/Bwise1/interstellar/internal/utils"
@@ -17,7 +28,9 @@ import (
 )
 
 func main() {
-	ctx := context.Background()
+	// Cancelled on SIGINT/SIGTERM so the server and background jobs shut down together
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	// load env
 	if err := godotenv.Load(); err != nil {
@@ -60,19 +73,21 @@ func main() {
 		log.Fatal("JWT_SECRET is required")
 	}
 
-	// Initialize JWT with secret and expiration (7 days)
-	utils.InitJWT(jwtSecret, 24*7)
-
-	// Get ExchangeRate-API key
-	fxAPIKey := getEnv("EXCHANGERATE_API_KEY", "")
-	if fxAPIKey == "" {
-		log.Fatal("EXCHANGERATE_API_KEY is required")
+	// Access tokens are short-lived; clients renew them with a refresh token
+	accessTokenTTL, err := time.ParseDuration(getEnv("ACCESS_TOKEN_TTL", "15m"))
+	if err != nil {
+		log.Fatal("Invalid ACCESS_TOKEN_TTL:", err)
 	}
+	refreshTokenTTL, err := time.ParseDuration(getEnv("REFRESH_TOKEN_TTL", "720h"))
+	if err != nil {
+		log.Fatal("Invalid REFRESH_TOKEN_TTL:", err)
+	}
+	utils.InitJWT(jwtSecret, accessTokenTTL)
 
-	// Get Audit Logs Password
-	auditPassword := getEnv("AUDIT_PASSWORD", "")
-	if auditPassword == "" {
-		log.Fatal("AUDIT_PASSWORD is required")
+	// Build FX rate providers in priority order
+	fxProviders := buildFXProviders(getEnv("FX_PROVIDERS", "fastforex,exchangerate-api,static"))
+	if len(fxProviders) == 0 {
+		log.Fatal("at least one FX provider must be configured (FASTFOREX_API_KEY, EXCHANGERATE_API_V6_KEY or FX_STATIC_RATES_FILE)")
 	}
 
 	// Initialize audit log dependencies
@@ -81,38 +96,165 @@ func main() {
 	auditHandler := auditlogs.NewHandler(auditService)
 
 	// Initialize FX rates dependencies
-	fxService := fxrates.NewService(fxAPIKey)
+	fxCacheTTL, err := time.ParseDuration(getEnv("FX_CACHE_TTL", "1h"))
+	if err != nil {
+		log.Fatal("Invalid FX_CACHE_TTL:", err)
+	}
+	fxRefreshIntervals, err := parseDurationConfig(getEnv("FX_REFRESH_INTERVALS", "USD=15m"))
+	if err != nil {
+		log.Fatal("Invalid FX_REFRESH_INTERVALS:", err)
+	}
+	fxMaxDeviation, err := strconv.ParseFloat(getEnv("FX_MAX_DEVIATION", "0.1"), 64)
+	if err != nil {
+		log.Fatal("Invalid FX_MAX_DEVIATION:", err)
+	}
+	fxPairMaxDeviation, err := parseRatioConfig(getEnv("FX_PAIR_MAX_DEVIATION", ""))
+	if err != nil {
+		log.Fatal("Invalid FX_PAIR_MAX_DEVIATION:", err)
+	}
+	fxMaxRateAge, err := time.ParseDuration(getEnv("FX_MAX_RATE_AGE", "2h"))
+	if err != nil {
+		log.Fatal("Invalid FX_MAX_RATE_AGE:", err)
+	}
+	fxRefreshCooldown, err := time.ParseDuration(getEnv("FX_REFRESH_COOLDOWN", "1m"))
+	if err != nil {
+		log.Fatal("Invalid FX_REFRESH_COOLDOWN:", err)
+	}
+	fxProviderQuotas, err := parseInt64Config(getEnv("FX_PROVIDER_QUOTAS", ""))
+	if err != nil {
+		log.Fatal("Invalid FX_PROVIDER_QUOTAS:", err)
+	}
+	fxQuotaReserve, err := strconv.ParseFloat(getEnv("FX_QUOTA_RESERVE", "0.1"), 64)
+	if err != nil {
+		log.Fatal("Invalid FX_QUOTA_RESERVE:", err)
+	}
+	fxRepo := fxrates.NewRepository(pool)
+	fxService := fxrates.NewService(fxRepo, auditService, fxrates.Config{
+		Providers:        fxProviders,
+		CacheTTL:         fxCacheTTL,
+		PivotCurrency:    getEnv("FX_PIVOT_CURRENCY", "USD"),
+		RefreshIntervals: fxRefreshIntervals,
+		MaxDeviation:     fxMaxDeviation,
+		PairMaxDeviation: fxPairMaxDeviation,
+		MaxRateAge:       fxMaxRateAge,
+		RefreshCooldown:  fxRefreshCooldown,
+		ProviderQuotas:   fxProviderQuotas,
+		QuotaReserve:     fxQuotaReserve,
+	})
+	if err := fxService.LoadQuotaUsage(ctx); err != nil {
+		logger.Warn("failed to load fx provider usage", "error", err)
+	}
+	if err := fxService.WarmCache(ctx); err != nil {
+		logger.Warn("failed to warm fx rate cache", "error", err)
+	}
+	if err := fxService.LoadOverrides(ctx); err != nil {
+		log.Fatal("Unable to load fx rate overrides:", err)
+	}
+
+	// Refresh rates ahead of expiry so requests rarely wait on an upstream call
+	go fxService.RunRefresher(ctx)
 	fxHandler := fxrates.NewHandler(fxService)
 
-	// Initialize wallet dependencies
-	walletRepo := wallets.NewRepository(pool)
-	walletService := wallets.NewService(walletRepo)
-	walletHandler := wallets.NewHandler(walletService)
+	// Initialize rate alert dependencies
+	alertRepo := alerts.NewRepository(pool)
+	alertService := alerts.NewService(alertRepo, fxService, alerts.LogNotifier{})
+	alertHandler := alerts.NewHandler(alertService)
+
+	// Evaluate rate alerts whenever rates change
+	go alertService.RunEvaluator(ctx)
+
+	// Initialize user service; transfers use it for two-factor step-up and AuthMiddleware for revoked tokens
+	userRepo := users.NewRepository(pool)
+	userService := users.NewService(userRepo, getEnv("TOTP_ISSUER", "Kora Exchange"), refreshTokenTTL, users.LogNotifier{})
+	if err := userService.LoadDenylist(ctx); err != nil {
+		log.Fatal("Unable to load revoked tokens:", err)
+	}
+
+	// Grant the admin role to ADMIN_EMAILS (comma-separated) so someone can manage roles
+	if err := userService.BootstrapAdmins(ctx, strings.Split(getEnv("ADMIN_EMAILS", ""), ",")); err != nil {
+		log.Fatal("Unable to grant admin roles:", err)
+	}
+
+	// Pick up token revocations made by other instances
+	go userService.RunDenylistSync(ctx)
 
 	// Initialize transaction dependencies
+	stepUpAmount, err := strconv.ParseFloat(getEnv("TRANSFER_STEP_UP_AMOUNT", "1000"), 64)
+	if err != nil || stepUpAmount < 0 {
+		log.Fatal("Invalid TRANSFER_STEP_UP_AMOUNT:", getEnv("TRANSFER_STEP_UP_AMOUNT", ""))
+	}
+	walletRepo := wallets.NewRepository(pool)
 	transactionRepo := transactions.NewRepository(pool)
-	transactionService := transactions.NewService(transactionRepo, walletRepo, fxService)
-	transactionHandler := transactions.NewHandler(transactionService)
+	transactionService := transactions.NewService(transactionRepo, walletRepo, fxService, stepUpAmount)
+	transactionHandler := transactions.NewHandler(transactionService, userService)
+
+	// Initialize wallet dependencies
+	walletService := wallets.NewService(walletRepo, fxService, auditService, transactionService)
+	walletHandler := wallets.NewHandler(walletService)
+
+	// Snapshot wallet balances daily for historical queries
+	go walletService.RunSnapshots(ctx, 24*time.Hour)
+
+	// Initialize savings dependencies
+	savingsAPR, err := parseInt64Config(getEnv("SAVINGS_APR_BPS", "USDx=400,EURx=300"))
+	if err != nil {
+		log.Fatal("Invalid SAVINGS_APR_BPS:", err)
+	}
+	savingsRepo := savings.NewRepository(pool)
+	savingsService := savings.NewService(savingsRepo, transactionService, savingsAPR, savings.SystemClock)
+	savingsHandler := savings.NewHandler(savingsService)
+
+	// Accrue savings interest; runs hourly but credits at most once per day
+	go savingsService.RunAccrual(ctx, time.Hour)
+
+	// Initialize limit order dependencies
+	orderRepo := orders.NewRepository(pool)
+	orderService := orders.NewService(orderRepo, transactionService, fxService)
+	orderHandler := orders.NewHandler(orderService)
+
+	// Execute limit orders as rates move
+	go orderService.RunMatcher(ctx)
+
+	// Initialize order book dependencies
+	exchangePairs, err := exchange.ParsePairs(getEnv("EXCHANGE_PAIRS", "USDx/cNGN,EURx/USDx"))
+	if err != nil {
+		log.Fatal("Invalid EXCHANGE_PAIRS:", err)
+	}
+	exchangeRepo := exchange.NewRepository(pool)
+	exchangeService := exchange.NewService(exchangeRepo, transactionService, walletRepo, exchangePairs)
+	if err := exchangeService.LoadBooks(ctx); err != nil {
+		log.Fatal("Unable to load order books:", err)
+	}
+	exchangeHandler := exchange.NewHandler(exchangeService)
+
+	// Initialize market data dependencies
+	marketRepo := marketdata.NewRepository(pool)
+	marketService := marketdata.NewService(marketRepo)
+	marketHandler := marketdata.NewHandler(marketService)
 
 	// Initialize user dependencies
-	userRepo := users.NewRepository(pool)
-	userService := users.NewService(userRepo)
-	userHandler := users.NewHandler(userService, walletService, auditPassword)
+	userHandler := users.NewHandler(userService, walletService)
 
 	api := application{
 		config:             cfg,
+		tokenDenylist:      userService,
 		db:                 pool,
 		userHandler:        userHandler,
 		walletHandler:      walletHandler,
 		transactionHandler: transactionHandler,
+		savingsHandler:     savingsHandler,
+		orderHandler:       orderHandler,
+		exchangeHandler:    exchangeHandler,
+		marketHandler:      marketHandler,
 		fxHandler:          fxHandler,
+		alertHandler:       alertHandler,
 		auditService:       auditService,
 		auditHandler:       auditHandler,
 	}
 
 	logger.Info("starting server", "address", cfg.addr)
 
-	if err := api.run(api.mount()); err != nil {
+	if err := api.run(ctx, api.mount()); err != nil {
 		slog.Error("server failed to start", "error", err)
 		os.Exit(1)
 	}
@@ -124,3 +266,100 @@ func getEnv(key, defaultValue string) string {
 	}
 	return value
 }
+
+// parseInt64Config parses "USDx=400,EURx=300" into key -> non-negative integer
+func parseInt64Config(value string) (map[string]int64, error) {
+	values := make(map[string]int64)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		key, numStr, found := strings.Cut(entry, "=")
+		if !found {
+			return nil, fmt.Errorf("expected KEY=NUMBER, got %q", entry)
+		}
+
+		num, err := strconv.ParseInt(strings.TrimSpace(numStr), 10, 64)
+		if err != nil || num < 0 {
+			return nil, fmt.Errorf("invalid number for %s: %q", key, numStr)
+		}
+		values[strings.TrimSpace(key)] = num
+	}
+	return values, nil
+}
+
+// parseDurationConfig parses "USD=15m,USD/NGN=5m" into key -> duration
+func parseDurationConfig(value string) (map[string]time.Duration, error) {
+	durations := make(map[string]time.Duration)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		key, durationStr, found := strings.Cut(entry, "=")
+		if !found {
+			return nil, fmt.Errorf("expected KEY=DURATION, got %q", entry)
+		}
+
+		d, err := time.ParseDuration(strings.TrimSpace(durationStr))
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid duration for %s: %q", key, durationStr)
+		}
+		durations[strings.TrimSpace(key)] = d
+	}
+	return durations, nil
+}
+
+// parseRatioConfig parses "USD/NGN=0.05,EUR/XAF=0.01" into key -> ratio
+func parseRatioConfig(value string) (map[string]float64, error) {
+	ratios := make(map[string]float64)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		key, ratioStr, found := strings.Cut(entry, "=")
+		if !found {
+			return nil, fmt.Errorf("expected KEY=RATIO, got %q", entry)
+		}
+
+		ratio, err := strconv.ParseFloat(strings.TrimSpace(ratioStr), 64)
+		if err != nil || ratio <= 0 {
+			return nil, fmt.Errorf("invalid ratio for %s: %q", key, ratioStr)
+		}
+		ratios[strings.TrimSpace(key)] = ratio
+	}
+	return ratios, nil
+}
+
+// buildFXProviders creates the configured FX rate providers in the order listed.
+// Providers without credentials are skipped.
+func buildFXProviders(order string) []fxrates.RateProvider {
+	var providers []fxrates.RateProvider
+	for _, name := range strings.Split(order, ",") {
+		switch strings.TrimSpace(name) {
+		case "fastforex":
+			// EXCHANGERATE_API_KEY historically held the FastForex key
+			key := getEnv("FASTFOREX_API_KEY", getEnv("EXCHANGERATE_API_KEY", ""))
+			if key != "" {
+				providers = append(providers, fxrates.NewFastForexProvider(key, getEnv("FASTFOREX_BASE_URL", "")))
+			}
+		case "exchangerate-api":
+			if key := getEnv("EXCHANGERATE_API_V6_KEY", ""); key != "" {
+				providers = append(providers, fxrates.NewExchangeRateAPIProvider(key, getEnv("EXCHANGERATE_API_BASE_URL", "")))
+			}
+		case "static":
+			if path := getEnv("FX_STATIC_RATES_FILE", ""); path != "" {
+				providers = append(providers, fxrates.NewStaticProvider(path))
+			}
+		case "":
+		default:
+			log.Printf("unknown FX provider %q ignored", name)
+		}
+	}
+	return providers
+}
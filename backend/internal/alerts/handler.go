@@ -0,0 +1,180 @@
+package alerts
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Bwise1/interstellar/internal/utils"
+	"github.com/Bwise1/interstellar/pkg/response"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// Handler handles HTTP requests for rate alerts
+type Handler struct {
+	service *Service
+}
+
+// NewHandler creates a new rate alert handler
+func NewHandler(service *Service) *Handler {
+	return &Handler{
+		service: service,
+	}
+}
+
+// POST /api/fx-rates/alerts
+func (h *Handler) CreateAlert(w http.ResponseWriter, r *http.Request) {
+	userID, ok := utils.GetUserIDFromContext(r.Context())
+	if !ok || userID == uuid.Nil {
+		response.Error(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req AlertRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	alert, err := h.service.CreateAlert(r.Context(), userID, &req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	response.Success(w, http.StatusCreated, "Rate alert created successfully", alert)
+}
+
+// GET /api/fx-rates/alerts
+func (h *Handler) GetAlerts(w http.ResponseWriter, r *http.Request) {
+	userID, ok := utils.GetUserIDFromContext(r.Context())
+	if !ok || userID == uuid.Nil {
+		response.Error(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	alerts, err := h.service.GetAlerts(r.Context(), userID)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to retrieve rate alerts")
+		return
+	}
+
+	response.Success(w, http.StatusOK, "Rate alerts retrieved successfully", alerts)
+}
+
+// GET /api/fx-rates/alerts/{id}
+func (h *Handler) GetAlert(w http.ResponseWriter, r *http.Request) {
+	userID, ok := utils.GetUserIDFromContext(r.Context())
+	if !ok || userID == uuid.Nil {
+		response.Error(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	alertID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid alert ID")
+		return
+	}
+
+	alert, err := h.service.GetAlert(r.Context(), userID, alertID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	response.Success(w, http.StatusOK, "Rate alert retrieved successfully", alert)
+}
+
+// PUT /api/fx-rates/alerts/{id}
+func (h *Handler) UpdateAlert(w http.ResponseWriter, r *http.Request) {
+	userID, ok := utils.GetUserIDFromContext(r.Context())
+	if !ok || userID == uuid.Nil {
+		response.Error(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	alertID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid alert ID")
+		return
+	}
+
+	var req AlertRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	alert, err := h.service.UpdateAlert(r.Context(), userID, alertID, &req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	response.Success(w, http.StatusOK, "Rate alert updated successfully", alert)
+}
+
+// DELETE /api/fx-rates/alerts/{id}
+func (h *Handler) DeleteAlert(w http.ResponseWriter, r *http.Request) {
+	userID, ok := utils.GetUserIDFromContext(r.Context())
+	if !ok || userID == uuid.Nil {
+		response.Error(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	alertID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid alert ID")
+		return
+	}
+
+	if err := h.service.DeleteAlert(r.Context(), userID, alertID); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	response.Success(w, http.StatusOK, "Rate alert deleted successfully", nil)
+}
+
+// GET /api/fx-rates/alerts/notifications?limit=50&offset=0
+func (h *Handler) GetNotifications(w http.ResponseWriter, r *http.Request) {
+	userID, ok := utils.GetUserIDFromContext(r.Context())
+	if !ok || userID == uuid.Nil {
+		response.Error(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	limit := 50 // default limit
+	offset := 0 // default offset
+
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 {
+		limit = l
+	}
+	if o, err := strconv.Atoi(r.URL.Query().Get("offset")); err == nil && o >= 0 {
+		offset = o
+	}
+
+	notifications, err := h.service.GetNotifications(r.Context(), userID, limit, offset)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to retrieve rate alert notifications")
+		return
+	}
+
+	response.Success(w, http.StatusOK, "Rate alert notifications retrieved successfully", notifications)
+}
+
+// writeError maps rate alert errors to HTTP responses
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrAlertNotFound):
+		response.Error(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, ErrInvalidAlert), errors.Is(err, ErrUnsupportedPair):
+		response.Error(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, ErrTooManyAlerts):
+		response.Error(w, http.StatusConflict, err.Error())
+	default:
+		response.Error(w, http.StatusInternalServerError, err.Error())
+	}
+}
@@ -0,0 +1,72 @@
+package alerts
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Direction is the side of the threshold that triggers an alert
+type Direction string
+
+const (
+	DirectionAbove Direction = "ABOVE" // triggers when the rate rises to or above the threshold
+	DirectionBelow Direction = "BELOW" // triggers when the rate falls to or below the threshold
+)
+
+// AlertStatus represents the state of a rate alert
+type AlertStatus string
+
+const (
+	AlertStatusActive    AlertStatus = "ACTIVE"
+	AlertStatusTriggered AlertStatus = "TRIGGERED" // a one-shot alert that has fired
+)
+
+// Alert watches a pair and notifies its owner when the rate crosses a threshold.
+// A recurring alert disarms when it fires and re-arms once the rate crosses back.
+type Alert struct {
+	ID              uuid.UUID   `json:"id"`
+	UserID          uuid.UUID   `json:"user_id"`
+	BaseCurrency    string      `json:"base_currency"`
+	QuoteCurrency   string      `json:"quote_currency"`
+	Direction       Direction   `json:"direction"`
+	Threshold       float64     `json:"threshold"`
+	Recurring       bool        `json:"recurring"`
+	Status          AlertStatus `json:"status"`
+	Armed           bool        `json:"armed"`
+	LastTriggeredAt *time.Time  `json:"last_triggered_at,omitempty"`
+	CreatedAt       time.Time   `json:"created_at"`
+	UpdatedAt       time.Time   `json:"updated_at"`
+}
+
+// AlertRequest creates or replaces an alert
+type AlertRequest struct {
+	Pair      string    `json:"pair"` // e.g. "USD/NGN" or "USDx/cNGN"
+	Direction Direction `json:"direction"`
+	Threshold float64   `json:"threshold"`
+	Recurring bool      `json:"recurring"`
+}
+
+// Notification is a recorded alert trigger and the result of delivering it
+type Notification struct {
+	ID          uuid.UUID  `json:"id"`
+	AlertID     uuid.UUID  `json:"alert_id"`
+	UserID      uuid.UUID  `json:"user_id"`
+	Pair        string     `json:"pair"`
+	Direction   Direction  `json:"direction"`
+	Threshold   float64    `json:"threshold"`
+	Rate        float64    `json:"rate"`
+	Message     string     `json:"message"`
+	Channel     string     `json:"channel"`
+	DeliveredAt *time.Time `json:"delivered_at,omitempty"`
+	Error       string     `json:"error,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// crossed reports whether rate is on the triggering side of the threshold
+func (a *Alert) crossed(rate float64) bool {
+	if a.Direction == DirectionAbove {
+		return rate >= a.Threshold
+	}
+	return rate <= a.Threshold
+}
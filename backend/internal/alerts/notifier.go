@@ -0,0 +1,23 @@
+package alerts
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Notifier delivers triggered alerts to their owner
+type Notifier interface {
+	Channel() string
+	Notify(ctx context.Context, n *Notification) error
+}
+
+// LogNotifier writes notifications to the application log. It stands in until a push, email
+// or webhook channel is configured; notifications are recorded in the database either way.
+type LogNotifier struct{}
+
+func (LogNotifier) Channel() string { return "log" }
+
+func (LogNotifier) Notify(ctx context.Context, n *Notification) error {
+	slog.Info("rate alert triggered", "user_id", n.UserID, "alert_id", n.AlertID, "pair", n.Pair, "rate", n.Rate, "message", n.Message)
+	return nil
+}
@@ -0,0 +1,259 @@
+package alerts
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Repository handles database operations for rate alerts
+type Repository struct {
+	db *pgxpool.Pool
+}
+
+// NewRepository creates a new rate alert repository
+func NewRepository(db *pgxpool.Pool) *Repository {
+	return &Repository{db: db}
+}
+
+const alertColumns = `
+	id, user_id, base_currency, quote_currency, direction, threshold::float8,
+	recurring, status, armed, last_triggered_at, created_at, updated_at
+`
+
+// scanAlert scans a single alert row
+func scanAlert(row pgx.Row) (*Alert, error) {
+	var a Alert
+	err := row.Scan(
+		&a.ID,
+		&a.UserID,
+		&a.BaseCurrency,
+		&a.QuoteCurrency,
+		&a.Direction,
+		&a.Threshold,
+		&a.Recurring,
+		&a.Status,
+		&a.Armed,
+		&a.LastTriggeredAt,
+		&a.CreatedAt,
+		&a.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// Create inserts a new alert
+func (r *Repository) Create(ctx context.Context, a *Alert) error {
+	query := `
+		INSERT INTO rate_alerts (
+			id, user_id, base_currency, quote_currency, direction, threshold,
+			recurring, status, armed, created_at, updated_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	`
+
+	_, err := r.db.Exec(
+		ctx,
+		query,
+		a.ID,
+		a.UserID,
+		a.BaseCurrency,
+		a.QuoteCurrency,
+		a.Direction,
+		a.Threshold,
+		a.Recurring,
+		a.Status,
+		a.Armed,
+		a.CreatedAt,
+		a.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create rate alert: %w", err)
+	}
+
+	return nil
+}
+
+// Update replaces an alert's definition and re-activates it
+func (r *Repository) Update(ctx context.Context, a *Alert) error {
+	query := `
+		UPDATE rate_alerts
+		SET base_currency = $1, quote_currency = $2, direction = $3, threshold = $4,
+			recurring = $5, status = $6, armed = $7, updated_at = $8
+		WHERE id = $9
+	`
+
+	_, err := r.db.Exec(ctx, query, a.BaseCurrency, a.QuoteCurrency, a.Direction, a.Threshold, a.Recurring, a.Status, a.Armed, a.UpdatedAt, a.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update rate alert: %w", err)
+	}
+
+	return nil
+}
+
+// Delete removes an alert; its notifications are kept
+func (r *Repository) Delete(ctx context.Context, id uuid.UUID) error {
+	if _, err := r.db.Exec(ctx, `DELETE FROM rate_alerts WHERE id = $1`, id); err != nil {
+		return fmt.Errorf("failed to delete rate alert: %w", err)
+	}
+	return nil
+}
+
+// GetByID retrieves an alert, returning nil when it does not exist
+func (r *Repository) GetByID(ctx context.Context, id uuid.UUID) (*Alert, error) {
+	query := `SELECT ` + alertColumns + ` FROM rate_alerts WHERE id = $1`
+
+	alert, err := scanAlert(r.db.QueryRow(ctx, query, id))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get rate alert: %w", err)
+	}
+
+	return alert, nil
+}
+
+// GetByUserID lists a user's alerts, newest first
+func (r *Repository) GetByUserID(ctx context.Context, userID uuid.UUID) ([]*Alert, error) {
+	query := `SELECT ` + alertColumns + ` FROM rate_alerts WHERE user_id = $1 ORDER BY created_at DESC`
+	return r.list(ctx, query, userID)
+}
+
+// GetActive lists every active alert for evaluation
+func (r *Repository) GetActive(ctx context.Context) ([]*Alert, error) {
+	query := `SELECT ` + alertColumns + ` FROM rate_alerts WHERE status = 'ACTIVE' ORDER BY created_at`
+	return r.list(ctx, query)
+}
+
+// CountActiveByUser counts a user's active alerts
+func (r *Repository) CountActiveByUser(ctx context.Context, userID uuid.UUID) (int, error) {
+	var count int
+	err := r.db.QueryRow(ctx, `SELECT COUNT(*) FROM rate_alerts WHERE user_id = $1 AND status = 'ACTIVE'`, userID).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count rate alerts: %w", err)
+	}
+	return count, nil
+}
+
+// list runs an alert query and scans every row
+func (r *Repository) list(ctx context.Context, query string, args ...any) ([]*Alert, error) {
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list rate alerts: %w", err)
+	}
+	defer rows.Close()
+
+	alerts := make([]*Alert, 0)
+	for rows.Next() {
+		alert, err := scanAlert(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan rate alert: %w", err)
+		}
+		alerts = append(alerts, alert)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rate alerts: %w", err)
+	}
+
+	return alerts, nil
+}
+
+// Trigger fires an armed alert and records its notification in one transaction. It returns false
+// without recording anything if the alert was already fired, disarmed or changed concurrently.
+func (r *Repository) Trigger(ctx context.Context, alert *Alert, n *Notification) (bool, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	status := AlertStatusActive
+	if !alert.Recurring {
+		status = AlertStatusTriggered
+	}
+
+	result, err := tx.Exec(ctx, `
+		UPDATE rate_alerts
+		SET armed = FALSE, status = $1, last_triggered_at = $2, updated_at = $2
+		WHERE id = $3 AND status = 'ACTIVE' AND armed AND updated_at = $4
+	`, status, n.CreatedAt, alert.ID, alert.UpdatedAt)
+	if err != nil {
+		return false, fmt.Errorf("failed to trigger rate alert: %w", err)
+	}
+	if result.RowsAffected() != 1 {
+		return false, nil
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO rate_alert_notifications (
+			id, alert_id, user_id, pair, direction, threshold, rate, message, channel, created_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`, n.ID, n.AlertID, n.UserID, n.Pair, n.Direction, n.Threshold, n.Rate, n.Message, n.Channel, n.CreatedAt)
+	if err != nil {
+		return false, fmt.Errorf("failed to record rate alert notification: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return false, fmt.Errorf("failed to commit rate alert trigger: %w", err)
+	}
+	return true, nil
+}
+
+// Rearm re-arms a recurring alert once the rate has crossed back
+func (r *Repository) Rearm(ctx context.Context, id uuid.UUID, at time.Time) error {
+	query := `UPDATE rate_alerts SET armed = TRUE, updated_at = $2 WHERE id = $1 AND status = 'ACTIVE' AND NOT armed`
+	if _, err := r.db.Exec(ctx, query, id, at); err != nil {
+		return fmt.Errorf("failed to re-arm rate alert: %w", err)
+	}
+	return nil
+}
+
+// RecordDelivery stores the outcome of delivering a notification
+func (r *Repository) RecordDelivery(ctx context.Context, id uuid.UUID, deliveredAt *time.Time, deliveryErr string) error {
+	query := `UPDATE rate_alert_notifications SET delivered_at = $2, error = NULLIF($3, '') WHERE id = $1`
+	if _, err := r.db.Exec(ctx, query, id, deliveredAt, deliveryErr); err != nil {
+		return fmt.Errorf("failed to record notification delivery: %w", err)
+	}
+	return nil
+}
+
+// GetNotificationsByUser lists a user's notifications, newest first
+func (r *Repository) GetNotificationsByUser(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*Notification, error) {
+	query := `
+		SELECT id, alert_id, user_id, pair, direction, threshold::float8, rate::float8, message,
+			channel, delivered_at, COALESCE(error, ''), created_at
+		FROM rate_alert_notifications
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2 OFFSET $3
+	`
+
+	rows, err := r.db.Query(ctx, query, userID, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list rate alert notifications: %w", err)
+	}
+	defer rows.Close()
+
+	notifications := make([]*Notification, 0)
+	for rows.Next() {
+		var n Notification
+		if err := rows.Scan(&n.ID, &n.AlertID, &n.UserID, &n.Pair, &n.Direction, &n.Threshold, &n.Rate, &n.Message,
+			&n.Channel, &n.DeliveredAt, &n.Error, &n.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan rate alert notification: %w", err)
+		}
+		notifications = append(notifications, &n)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rate alert notifications: %w", err)
+	}
+
+	return notifications, nil
+}
@@ -0,0 +1,273 @@
+package alerts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Bwise1/interstellar/internal/fxrates"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrAlertNotFound   = errors.New("rate alert not found")
+	ErrInvalidAlert    = errors.New("invalid rate alert")
+	ErrTooManyAlerts   = errors.New("too many active rate alerts")
+	ErrUnsupportedPair = errors.New("no rate available for this pair")
+)
+
+const (
+	maxActiveAlerts     = 50
+	notificationTimeout = 10 * time.Second
+)
+
+// RateSource supplies current pair rates and signals when they change
+type RateSource interface {
+	PairUpdate(ctx context.Context, base, quote string) (*fxrates.RateUpdate, error)
+	Subscribe() (<-chan struct{}, func())
+}
+
+// Service manages rate alerts and evaluates them on every rate change
+type Service struct {
+	repo     *Repository
+	rates    RateSource
+	notifier Notifier
+}
+
+// NewService creates a new rate alert service
+func NewService(repo *Repository, rates RateSource, notifier Notifier) *Service {
+	return &Service{
+		repo:     repo,
+		rates:    rates,
+		notifier: notifier,
+	}
+}
+
+// CreateAlert validates and stores a new alert for a user
+func (s *Service) CreateAlert(ctx context.Context, userID uuid.UUID, req *AlertRequest) (*Alert, error) {
+	base, quote, err := s.validate(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	count, err := s.repo.CountActiveByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if count >= maxActiveAlerts {
+		return nil, fmt.Errorf("%w: limit is %d", ErrTooManyAlerts, maxActiveAlerts)
+	}
+
+	now := time.Now()
+	alert := &Alert{
+		ID:            uuid.New(),
+		UserID:        userID,
+		BaseCurrency:  base,
+		QuoteCurrency: quote,
+		Direction:     req.Direction,
+		Threshold:     req.Threshold,
+		Recurring:     req.Recurring,
+		Status:        AlertStatusActive,
+		Armed:         true,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+
+	if err := s.repo.Create(ctx, alert); err != nil {
+		return nil, err
+	}
+	return alert, nil
+}
+
+// GetAlerts lists a user's alerts
+func (s *Service) GetAlerts(ctx context.Context, userID uuid.UUID) ([]*Alert, error) {
+	return s.repo.GetByUserID(ctx, userID)
+}
+
+// GetAlert returns one of a user's alerts
+func (s *Service) GetAlert(ctx context.Context, userID, alertID uuid.UUID) (*Alert, error) {
+	alert, err := s.repo.GetByID(ctx, alertID)
+	if err != nil {
+		return nil, err
+	}
+	if alert == nil || alert.UserID != userID {
+		return nil, ErrAlertNotFound
+	}
+	return alert, nil
+}
+
+// UpdateAlert replaces an alert's definition; a fired one-shot alert becomes active again
+func (s *Service) UpdateAlert(ctx context.Context, userID, alertID uuid.UUID, req *AlertRequest) (*Alert, error) {
+	alert, err := s.GetAlert(ctx, userID, alertID)
+	if err != nil {
+		return nil, err
+	}
+
+	base, quote, err := s.validate(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	alert.BaseCurrency = base
+	alert.QuoteCurrency = quote
+	alert.Direction = req.Direction
+	alert.Threshold = req.Threshold
+	alert.Recurring = req.Recurring
+	alert.Status = AlertStatusActive
+	alert.Armed = true
+	alert.UpdatedAt = time.Now()
+
+	if err := s.repo.Update(ctx, alert); err != nil {
+		return nil, err
+	}
+	return alert, nil
+}
+
+// DeleteAlert removes one of a user's alerts
+func (s *Service) DeleteAlert(ctx context.Context, userID, alertID uuid.UUID) error {
+	if _, err := s.GetAlert(ctx, userID, alertID); err != nil {
+		return err
+	}
+	return s.repo.Delete(ctx, alertID)
+}
+
+// GetNotifications lists a user's triggered alert notifications
+func (s *Service) GetNotifications(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*Notification, error) {
+	return s.repo.GetNotificationsByUser(ctx, userID, limit, offset)
+}
+
+// validate checks an alert request and splits its pair
+func (s *Service) validate(ctx context.Context, req *AlertRequest) (string, string, error) {
+	base, quote, found := strings.Cut(strings.TrimSpace(req.Pair), "/")
+	base, quote = strings.TrimSpace(base), strings.TrimSpace(quote)
+	switch {
+	case !found || base == "" || quote == "":
+		return "", "", fmt.Errorf("%w: pair must look like USD/NGN", ErrInvalidAlert)
+	case fxrates.MapToRealCurrency(base) == fxrates.MapToRealCurrency(quote):
+		return "", "", fmt.Errorf("%w: pair must be two different currencies", ErrInvalidAlert)
+	case req.Direction != DirectionAbove && req.Direction != DirectionBelow:
+		return "", "", fmt.Errorf("%w: direction must be ABOVE or BELOW", ErrInvalidAlert)
+	case math.IsNaN(req.Threshold) || math.IsInf(req.Threshold, 0) || req.Threshold <= 0:
+		return "", "", fmt.Errorf("%w: threshold must be greater than 0", ErrInvalidAlert)
+	}
+
+	if _, err := s.rates.PairUpdate(ctx, base, quote); err != nil {
+		return "", "", fmt.Errorf("%w: %s/%s", ErrUnsupportedPair, base, quote)
+	}
+	return base, quote, nil
+}
+
+// EvaluateAlerts checks every active alert against the current rates, looking each pair up once
+func (s *Service) EvaluateAlerts(ctx context.Context) error {
+	alerts, err := s.repo.GetActive(ctx)
+	if err != nil {
+		return err
+	}
+
+	rates := make(map[string]float64)
+	for _, alert := range alerts {
+		pair := alert.BaseCurrency + "/" + alert.QuoteCurrency
+		rate, seen := rates[pair]
+		if !seen {
+			update, err := s.rates.PairUpdate(ctx, alert.BaseCurrency, alert.QuoteCurrency)
+			if err != nil {
+				slog.Warn("rate alert pair unavailable", "pair", pair, "error", err)
+				rates[pair] = 0
+				continue
+			}
+			rate = update.Rate
+			rates[pair] = rate
+		}
+		if rate <= 0 {
+			continue
+		}
+
+		if err := s.evaluate(ctx, alert, pair, rate); err != nil {
+			slog.Error("failed to evaluate rate alert", "alert_id", alert.ID, "error", err)
+		}
+	}
+	return nil
+}
+
+// evaluate fires an armed alert whose threshold was crossed, or re-arms a recurring one that crossed back
+func (s *Service) evaluate(ctx context.Context, alert *Alert, pair string, rate float64) error {
+	if !alert.crossed(rate) {
+		if !alert.Armed {
+			return s.repo.Rearm(ctx, alert.ID, time.Now())
+		}
+		return nil
+	}
+	if !alert.Armed {
+		return nil
+	}
+
+	n := &Notification{
+		ID:        uuid.New(),
+		AlertID:   alert.ID,
+		UserID:    alert.UserID,
+		Pair:      pair,
+		Direction: alert.Direction,
+		Threshold: alert.Threshold,
+		Rate:      rate,
+		Message:   alertMessage(pair, alert.Direction, alert.Threshold, rate),
+		Channel:   s.notifier.Channel(),
+		CreatedAt: time.Now(),
+	}
+
+	fired, err := s.repo.Trigger(ctx, alert, n)
+	if err != nil || !fired {
+		return err
+	}
+
+	// Delivery runs after the trigger is committed, so a failing channel never fires an alert twice
+	notifyCtx, cancel := context.WithTimeout(ctx, notificationTimeout)
+	defer cancel()
+
+	var deliveredAt *time.Time
+	var deliveryErr string
+	if err := s.notifier.Notify(notifyCtx, n); err != nil {
+		deliveryErr = err.Error()
+		slog.Error("failed to deliver rate alert", "alert_id", alert.ID, "channel", n.Channel, "error", err)
+	} else {
+		now := time.Now()
+		deliveredAt = &now
+	}
+	return s.repo.RecordDelivery(ctx, n.ID, deliveredAt, deliveryErr)
+}
+
+// alertMessage renders the human-readable notification text
+func alertMessage(pair string, direction Direction, threshold, rate float64) string {
+	side := "risen above"
+	if direction == DirectionBelow {
+		side = "fallen below"
+	}
+	return fmt.Sprintf("%s has %s %s (now %s)", pair, side,
+		strconv.FormatFloat(threshold, 'f', -1, 64), strconv.FormatFloat(rate, 'f', -1, 64))
+}
+
+// RunEvaluator evaluates alerts on startup and whenever fxrates refreshes or an override changes,
+// until ctx is cancelled
+func (s *Service) RunEvaluator(ctx context.Context) {
+	updates, unsubscribe := s.rates.Subscribe()
+	defer unsubscribe()
+
+	if err := s.EvaluateAlerts(ctx); err != nil {
+		slog.Error("rate alert evaluation failed", "error", err)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-updates:
+			if err := s.EvaluateAlerts(ctx); err != nil {
+				slog.Error("rate alert evaluation failed", "error", err)
+			}
+		}
+	}
+}
@@ -30,27 +30,48 @@ func (h *Handler) GetUserAuditLogs(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Parse query parameters for pagination
-	limitStr := r.URL.Query().Get("limit")
-	offsetStr := r.URL.Query().Get("offset")
+	limit, offset := parsePage(r)
 
-	limit := 50 // default limit
-	offset := 0 // default offset
-
-	if limitStr != "" {
-		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
-			limit = l
-		}
+	// Get audit logs for the user
+	logs, err := h.service.GetByUserID(r.Context(), userID, limit, offset)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to fetch audit logs")
+		return
 	}
 
-	if offsetStr != "" {
-		if o, err := strconv.Atoi(offsetStr); err == nil && o >= 0 {
-			offset = o
+	response.Success(w, http.StatusOK, "Audit logs retrieved successfully", logs)
+}
+
+// GET /api/admin/audit-logs - Get every user's audit logs, optionally for one user (user_id),
+// one operation, or both. Requires the audit:read permission.
+func (h *Handler) GetAuditLogs(w http.ResponseWriter, r *http.Request) {
+	limit, offset := parsePage(r)
+
+	var userID uuid.UUID
+	if userIDStr := r.URL.Query().Get("user_id"); userIDStr != "" {
+		parsed, err := uuid.Parse(userIDStr)
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, "Invalid user_id")
+			return
 		}
+		userID = parsed
 	}
+	operation := r.URL.Query().Get("operation")
 
-	// Get audit logs for the user
-	logs, err := h.service.GetByUserID(r.Context(), userID, limit, offset)
+	var (
+		logs []*AuditLog
+		err  error
+	)
+	switch {
+	case userID != uuid.Nil && operation != "":
+		logs, err = h.service.GetByUserIDAndOperation(r.Context(), userID, operation, limit, offset)
+	case userID != uuid.Nil:
+		logs, err = h.service.GetByUserID(r.Context(), userID, limit, offset)
+	case operation != "":
+		logs, err = h.service.GetByOperation(r.Context(), operation, limit, offset)
+	default:
+		logs, err = h.service.GetAll(r.Context(), limit, offset)
+	}
 	if err != nil {
 		response.Error(w, http.StatusInternalServerError, "Failed to fetch audit logs")
 		return
@@ -58,3 +79,27 @@ func (h *Handler) GetUserAuditLogs(w http.ResponseWriter, r *http.Request) {
 
 	response.Success(w, http.StatusOK, "Audit logs retrieved successfully", logs)
 }
+
+// maxPageSize caps how many logs one request can read
+const maxPageSize = 500
+
+// parsePage reads the limit and offset query parameters, defaulting to the first 50 logs. Larger
+// limits are clamped to maxPageSize.
+func parsePage(r *http.Request) (limit, offset int) {
+	limit = 50 // default limit
+	offset = 0 // default offset
+
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
+			limit = min(l, maxPageSize)
+		}
+	}
+
+	if offsetStr := r.URL.Query().Get("offset"); offsetStr != "" {
+		if o, err := strconv.Atoi(offsetStr); err == nil && o >= 0 {
+			offset = o
+		}
+	}
+
+	return limit, offset
+}
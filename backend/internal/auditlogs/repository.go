@@ -123,6 +123,46 @@ func (r *Repository) GetByOperation(ctx context.Context, operation string, limit
 	return logs, rows.Err()
 }
 
+// GetByUserIDAndOperation retrieves a user's audit logs for a specific operation
+func (r *Repository) GetByUserIDAndOperation(ctx context.Context, userID uuid.UUID, operation string, limit, offset int) ([]*AuditLog, error) {
+	query := `
+		SELECT id, user_id, operation, client_ip, user_agent,
+		       request_method, request_path, request_body, timestamp
+		FROM audit_logs
+		WHERE user_id = $1 AND operation = $2
+		ORDER BY timestamp DESC
+		LIMIT $3 OFFSET $4
+	`
+
+	rows, err := r.db.Query(ctx, query, userID, operation, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var logs []*AuditLog
+	for rows.Next() {
+		var log AuditLog
+		err := rows.Scan(
+			&log.ID,
+			&log.UserID,
+			&log.Operation,
+			&log.ClientIP,
+			&log.UserAgent,
+			&log.RequestMethod,
+			&log.RequestPath,
+			&log.RequestBody,
+			&log.Timestamp,
+		)
+		if err != nil {
+			return nil, err
+		}
+		logs = append(logs, &log)
+	}
+
+	return logs, rows.Err()
+}
+
 // GetByDateRange retrieves audit logs within a date range
 func (r *Repository) GetByDateRange(ctx context.Context, startDate, endDate time.Time, limit, offset int) ([]*AuditLog, error) {
 	query := `
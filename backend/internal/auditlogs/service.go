@@ -34,6 +34,11 @@ func (s *Service) GetByOperation(ctx context.Context, operation string, limit, o
 	return s.repo.GetByOperation(ctx, operation, limit, offset)
 }
 
+// GetByUserIDAndOperation retrieves a user's audit logs for a specific operation
+func (s *Service) GetByUserIDAndOperation(ctx context.Context, userID uuid.UUID, operation string, limit, offset int) ([]*AuditLog, error) {
+	return s.repo.GetByUserIDAndOperation(ctx, userID, operation, limit, offset)
+}
+
 // GetByDateRange retrieves audit logs within a date range
 func (s *Service) GetByDateRange(ctx context.Context, startDate, endDate time.Time, limit, offset int) ([]*AuditLog, error) {
 	return s.repo.GetByDateRange(ctx, startDate, endDate, limit, offset)
@@ -0,0 +1,185 @@
+package exchange
+
+import (
+	"errors"
+	"math"
+	"sort"
+
+	"github.com/google/uuid"
+)
+
+// ErrSelfTrade is returned when an order would execute against the same user's resting order
+var ErrSelfTrade = errors.New("order would trade against your own resting order")
+
+// Fill is one execution of a taker against a maker, at the maker's price
+type Fill struct {
+	Maker    *Order
+	Taker    *Order
+	Price    float64
+	Quantity float64 // base currency
+
+	// Remaining quantity of each order once this fill is applied
+	MakerRemaining float64
+	TakerRemaining float64
+}
+
+// Book is an in-memory limit order book for one pair. It does no I/O and reads no clock, so
+// the same sequence of calls always yields the same fills; callers serialise access to it.
+type Book struct {
+	pair Pair
+	bids []*Order // highest price first, then lowest Seq
+	asks []*Order // lowest price first, then lowest Seq
+}
+
+// NewBook creates an empty book for pair
+func NewBook(pair Pair) *Book {
+	return &Book{pair: pair}
+}
+
+// Match executes taker against the opposite side with price-time priority: best price first and,
+// within a price, the order that arrived first. Each fill is priced at the maker's price and
+// reduces both orders' Remaining; fully filled makers leave the book. The taker is not rested.
+// Makers for which skip returns true are passed over and stay on the book. If the taker would
+// reach one of its owner's resting orders the book is left untouched and ErrSelfTrade is returned.
+func (b *Book) Match(taker *Order, skip func(maker *Order) bool) ([]Fill, error) {
+	var fills []Fill
+
+	opposite := b.opposite(taker.Side)
+	for i := 0; taker.Remaining > 0 && i < len(*opposite); {
+		maker := (*opposite)[i]
+		if !crosses(taker, maker) {
+			break
+		}
+		if skip != nil && skip(maker) {
+			i++
+			continue
+		}
+		if maker.UserID == taker.UserID {
+			b.Revert(fills)
+			return nil, ErrSelfTrade
+		}
+
+		quantity := math.Min(taker.Remaining, maker.Remaining)
+		maker.Remaining = roundAmount(maker.Remaining - quantity)
+		taker.Remaining = roundAmount(taker.Remaining - quantity)
+
+		fills = append(fills, Fill{
+			Maker:          maker,
+			Taker:          taker,
+			Price:          maker.Price,
+			Quantity:       quantity,
+			MakerRemaining: maker.Remaining,
+			TakerRemaining: taker.Remaining,
+		})
+
+		if maker.Remaining == 0 {
+			*opposite = append((*opposite)[:i], (*opposite)[i+1:]...)
+		}
+	}
+
+	return fills, nil
+}
+
+// Revert undoes fills returned by Match, putting filled makers back in their place
+func (b *Book) Revert(fills []Fill) {
+	for i := len(fills) - 1; i >= 0; i-- {
+		fill := fills[i]
+		if fill.Maker.Remaining == 0 {
+			fill.Maker.Remaining = fill.Quantity
+			b.Rest(fill.Maker)
+		} else {
+			fill.Maker.Remaining = roundAmount(fill.Maker.Remaining + fill.Quantity)
+		}
+		fill.Taker.Remaining = roundAmount(fill.Taker.Remaining + fill.Quantity)
+	}
+}
+
+// Rest adds an order with quantity remaining to its side of the book
+func (b *Book) Rest(order *Order) {
+	side := b.side(order.Side)
+	i := sort.Search(len(*side), func(i int) bool {
+		return before(order, (*side)[i])
+	})
+
+	*side = append(*side, nil)
+	copy((*side)[i+1:], (*side)[i:])
+	(*side)[i] = order
+}
+
+// Remove takes an order off the book, returning the resting order or nil if it wasn't there
+func (b *Book) Remove(id uuid.UUID) *Order {
+	for _, side := range []*[]*Order{&b.bids, &b.asks} {
+		for i, order := range *side {
+			if order.ID == id {
+				*side = append((*side)[:i], (*side)[i+1:]...)
+				return order
+			}
+		}
+	}
+	return nil
+}
+
+// Depth aggregates up to levels price levels per side
+func (b *Book) Depth(levels int) *Depth {
+	return &Depth{
+		Pair: b.pair.Symbol(),
+		Bids: aggregate(b.bids, levels),
+		Asks: aggregate(b.asks, levels),
+	}
+}
+
+func (b *Book) side(side Side) *[]*Order {
+	if side == SideBuy {
+		return &b.bids
+	}
+	return &b.asks
+}
+
+func (b *Book) opposite(side Side) *[]*Order {
+	if side == SideBuy {
+		return &b.asks
+	}
+	return &b.bids
+}
+
+// crosses reports whether taker is willing to trade at maker's price
+func crosses(taker, maker *Order) bool {
+	if taker.Side == SideBuy {
+		return taker.Price >= maker.Price
+	}
+	return taker.Price <= maker.Price
+}
+
+// before reports whether order belongs in front of other: a better price, or the same price
+// and an earlier arrival
+func before(order, other *Order) bool {
+	if order.Price != other.Price {
+		if order.Side == SideBuy {
+			return order.Price > other.Price
+		}
+		return order.Price < other.Price
+	}
+	return order.Seq < other.Seq
+}
+
+// aggregate sums orders into price levels, keeping the side's order
+func aggregate(orders []*Order, levels int) []PriceLevel {
+	result := make([]PriceLevel, 0)
+	for _, order := range orders {
+		if n := len(result); n > 0 && result[n-1].Price == order.Price {
+			result[n-1].Quantity = roundAmount(result[n-1].Quantity + order.Remaining)
+			result[n-1].Orders++
+			continue
+		}
+		if len(result) == levels {
+			break
+		}
+		result = append(result, PriceLevel{Price: order.Price, Quantity: order.Remaining, Orders: 1})
+	}
+	return result
+}
+
+// roundAmount rounds to the 8 decimal places balances are stored with
+func roundAmount(v float64) float64 {
+	return math.Round(v*1e8) / 1e8
+}
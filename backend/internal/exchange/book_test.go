@@ -0,0 +1,228 @@
+package exchange
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+var testPair = Pair{Base: "USDx", Quote: "cNGN"}
+
+// newTestOrder builds an order with its whole quantity remaining
+func newTestOrder(user uuid.UUID, side Side, price, quantity float64, seq int64) *Order {
+	return &Order{
+		ID:        uuid.New(),
+		UserID:    user,
+		Pair:      testPair.Symbol(),
+		Side:      side,
+		Price:     price,
+		Quantity:  quantity,
+		Remaining: quantity,
+		Status:    OrderStatusOpen,
+		Seq:       seq,
+	}
+}
+
+// bookIDs lists the orders on one side of the book in priority order
+func bookIDs(book *Book, side Side) []uuid.UUID {
+	var ids []uuid.UUID
+	for _, order := range *book.side(side) {
+		ids = append(ids, order.ID)
+	}
+	return ids
+}
+
+func sameIDs(got, want []uuid.UUID) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestMatchPriceTimePriority(t *testing.T) {
+	book := NewBook(testPair)
+	seller := uuid.New()
+	// Rested out of order: the best price wins, then the earliest arrival at that price
+	late := newTestOrder(seller, SideSell, 1500, 1, 3)
+	worse := newTestOrder(seller, SideSell, 1510, 1, 1)
+	early := newTestOrder(seller, SideSell, 1500, 1, 2)
+	for _, order := range []*Order{late, worse, early} {
+		book.Rest(order)
+	}
+
+	if got, want := bookIDs(book, SideSell), []uuid.UUID{early.ID, late.ID, worse.ID}; !sameIDs(got, want) {
+		t.Fatalf("asks = %v, want %v", got, want)
+	}
+
+	taker := newTestOrder(uuid.New(), SideBuy, 1510, 3, 4)
+	fills, err := book.Match(taker, nil)
+	if err != nil {
+		t.Fatalf("Match: %v", err)
+	}
+	if len(fills) != 3 {
+		t.Fatalf("got %d fills, want 3", len(fills))
+	}
+	for i, want := range []*Order{early, late, worse} {
+		if fills[i].Maker != want {
+			t.Errorf("fill %d maker seq %d, want seq %d", i, fills[i].Maker.Seq, want.Seq)
+		}
+		if fills[i].Price != want.Price {
+			t.Errorf("fill %d price %v, want the maker's %v", i, fills[i].Price, want.Price)
+		}
+	}
+	if len(bookIDs(book, SideSell)) != 0 {
+		t.Error("filled makers left on the book")
+	}
+}
+
+func TestMatchStopsAtLimit(t *testing.T) {
+	book := NewBook(testPair)
+	bid := newTestOrder(uuid.New(), SideBuy, 1490, 1, 1)
+	book.Rest(bid)
+
+	taker := newTestOrder(uuid.New(), SideSell, 1500, 1, 2)
+	fills, err := book.Match(taker, nil)
+	if err != nil {
+		t.Fatalf("Match: %v", err)
+	}
+	if len(fills) != 0 || taker.Remaining != 1 || bid.Remaining != 1 {
+		t.Errorf("ask above the best bid traded: %d fills, taker %v, bid %v left", len(fills), taker.Remaining, bid.Remaining)
+	}
+}
+
+func TestMatchPartialFills(t *testing.T) {
+	t.Run("maker partly filled", func(t *testing.T) {
+		book := NewBook(testPair)
+		maker := newTestOrder(uuid.New(), SideBuy, 1500, 5, 1)
+		book.Rest(maker)
+
+		taker := newTestOrder(uuid.New(), SideSell, 1500, 2, 2)
+		fills, err := book.Match(taker, nil)
+		if err != nil {
+			t.Fatalf("Match: %v", err)
+		}
+		if len(fills) != 1 || fills[0].Quantity != 2 {
+			t.Fatalf("fills = %+v, want one fill of 2", fills)
+		}
+		if fills[0].MakerRemaining != 3 || fills[0].TakerRemaining != 0 {
+			t.Errorf("remaining maker %v taker %v, want 3 and 0", fills[0].MakerRemaining, fills[0].TakerRemaining)
+		}
+		if got := bookIDs(book, SideBuy); !sameIDs(got, []uuid.UUID{maker.ID}) {
+			t.Error("partly filled maker should stay on the book")
+		}
+	})
+
+	t.Run("taker partly filled", func(t *testing.T) {
+		book := NewBook(testPair)
+		first := newTestOrder(uuid.New(), SideSell, 1500, 0.4, 1)
+		second := newTestOrder(uuid.New(), SideSell, 1505, 0.35, 2)
+		book.Rest(first)
+		book.Rest(second)
+
+		taker := newTestOrder(uuid.New(), SideBuy, 1505, 1, 3)
+		fills, err := book.Match(taker, nil)
+		if err != nil {
+			t.Fatalf("Match: %v", err)
+		}
+		if len(fills) != 2 {
+			t.Fatalf("got %d fills, want 2", len(fills))
+		}
+		if fills[0].TakerRemaining != 0.6 || fills[1].TakerRemaining != 0.25 {
+			t.Errorf("taker remaining %v then %v, want 0.6 then 0.25", fills[0].TakerRemaining, fills[1].TakerRemaining)
+		}
+		if taker.Remaining != 0.25 {
+			t.Errorf("taker remaining = %v, want 0.25", taker.Remaining)
+		}
+		if len(bookIDs(book, SideSell)) != 0 {
+			t.Error("filled makers left on the book")
+		}
+	})
+}
+
+func TestMatchRejectsSelfTrade(t *testing.T) {
+	book := NewBook(testPair)
+	user := uuid.New()
+	other := newTestOrder(uuid.New(), SideSell, 1500, 1, 1)
+	own := newTestOrder(user, SideSell, 1501, 1, 2)
+	book.Rest(other)
+	book.Rest(own)
+
+	taker := newTestOrder(user, SideBuy, 1501, 2, 3)
+	fills, err := book.Match(taker, nil)
+	if !errors.Is(err, ErrSelfTrade) {
+		t.Fatalf("err = %v, want ErrSelfTrade", err)
+	}
+	if fills != nil {
+		t.Errorf("fills = %+v, want none", fills)
+	}
+
+	// The fill against the other user's order is undone
+	if taker.Remaining != 2 || other.Remaining != 1 || own.Remaining != 1 {
+		t.Errorf("remaining taker %v, other %v, own %v; want 2, 1, 1", taker.Remaining, other.Remaining, own.Remaining)
+	}
+	if got, want := bookIDs(book, SideSell), []uuid.UUID{other.ID, own.ID}; !sameIDs(got, want) {
+		t.Errorf("asks = %v, want %v", got, want)
+	}
+}
+
+func TestMatchSkipsMakers(t *testing.T) {
+	book := NewBook(testPair)
+	frozen := newTestOrder(uuid.New(), SideSell, 1500, 1, 1)
+	next := newTestOrder(uuid.New(), SideSell, 1500, 1, 2)
+	book.Rest(frozen)
+	book.Rest(next)
+
+	taker := newTestOrder(uuid.New(), SideBuy, 1500, 1, 3)
+	fills, err := book.Match(taker, func(maker *Order) bool { return maker == frozen })
+	if err != nil {
+		t.Fatalf("Match: %v", err)
+	}
+	if len(fills) != 1 || fills[0].Maker != next {
+		t.Fatalf("fills = %+v, want one against the next maker", fills)
+	}
+	if got := bookIDs(book, SideSell); !sameIDs(got, []uuid.UUID{frozen.ID}) {
+		t.Errorf("asks = %v, want only the skipped order", got)
+	}
+}
+
+func TestRevert(t *testing.T) {
+	book := NewBook(testPair)
+	first := newTestOrder(uuid.New(), SideBuy, 1510, 1, 1)
+	second := newTestOrder(uuid.New(), SideBuy, 1505, 2, 2)
+	third := newTestOrder(uuid.New(), SideBuy, 1500, 1, 3)
+	for _, order := range []*Order{first, second, third} {
+		book.Rest(order)
+	}
+
+	taker := newTestOrder(uuid.New(), SideSell, 1500, 2.5, 4)
+	fills, err := book.Match(taker, nil)
+	if err != nil {
+		t.Fatalf("Match: %v", err)
+	}
+	if len(fills) != 2 || second.Remaining != 0.5 {
+		t.Fatalf("got %d fills leaving %v of the second bid, want 2 leaving 0.5", len(fills), second.Remaining)
+	}
+
+	// Undo only the second fill, as settlement does when it fails partway
+	book.Revert(fills[1:])
+	if taker.Remaining != 1.5 || second.Remaining != 2 {
+		t.Errorf("after reverting one fill: taker %v, second %v; want 1.5 and 2", taker.Remaining, second.Remaining)
+	}
+	if got, want := bookIDs(book, SideBuy), []uuid.UUID{second.ID, third.ID}; !sameIDs(got, want) {
+		t.Errorf("bids = %v, want %v", got, want)
+	}
+
+	book.Revert(fills[:1])
+	if taker.Remaining != 2.5 || first.Remaining != 1 {
+		t.Errorf("after reverting everything: taker %v, first %v; want 2.5 and 1", taker.Remaining, first.Remaining)
+	}
+	if got, want := bookIDs(book, SideBuy), []uuid.UUID{first.ID, second.ID, third.ID}; !sameIDs(got, want) {
+		t.Errorf("bids = %v, want the original book %v", got, want)
+	}
+}
@@ -0,0 +1,168 @@
+package exchange
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Bwise1/interstellar/internal/utils"
+	"github.com/Bwise1/interstellar/internal/wallets"
+	"github.com/Bwise1/interstellar/pkg/response"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// Handler handles HTTP requests for the order book
+type Handler struct {
+	service *Service
+}
+
+// NewHandler creates a new order book handler
+func NewHandler(service *Service) *Handler {
+	return &Handler{
+		service: service,
+	}
+}
+
+// GET /api/markets
+func (h *Handler) GetMarkets(w http.ResponseWriter, r *http.Request) {
+	response.Success(w, http.StatusOK, "Markets retrieved successfully", h.service.GetPairs())
+}
+
+// GET /api/markets/{pair}/depth?levels=20
+func (h *Handler) GetDepth(w http.ResponseWriter, r *http.Request) {
+	levels, _ := strconv.Atoi(r.URL.Query().Get("levels"))
+
+	depth, err := h.service.GetDepth(chi.URLParam(r, "pair"), levels)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	response.Success(w, http.StatusOK, "Order book retrieved successfully", depth)
+}
+
+// GET /api/markets/{pair}/trades?limit=50
+func (h *Handler) GetTrades(w http.ResponseWriter, r *http.Request) {
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+	trades, err := h.service.GetTrades(r.Context(), chi.URLParam(r, "pair"), limit)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	response.Success(w, http.StatusOK, "Trades retrieved successfully", trades)
+}
+
+// POST /api/exchange/orders
+func (h *Handler) PlaceOrder(w http.ResponseWriter, r *http.Request) {
+	userID, ok := utils.GetUserIDFromContext(r.Context())
+	if !ok || userID == uuid.Nil {
+		response.Error(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req PlaceOrderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	result, err := h.service.PlaceOrder(r.Context(), userID, &req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	response.Success(w, http.StatusCreated, "Order placed successfully", result)
+}
+
+// GET /api/exchange/orders?status=OPEN
+func (h *Handler) GetOrders(w http.ResponseWriter, r *http.Request) {
+	userID, ok := utils.GetUserIDFromContext(r.Context())
+	if !ok || userID == uuid.Nil {
+		response.Error(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	status := OrderStatus(strings.ToUpper(r.URL.Query().Get("status")))
+	switch status {
+	case "", OrderStatusOpen, OrderStatusFilled, OrderStatusCancelled:
+	default:
+		response.Error(w, http.StatusBadRequest, "Invalid status")
+		return
+	}
+
+	orders, err := h.service.GetOrders(r.Context(), userID, status)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to retrieve orders")
+		return
+	}
+
+	response.Success(w, http.StatusOK, "Orders retrieved successfully", orders)
+}
+
+// GET /api/exchange/orders/{id}
+func (h *Handler) GetOrder(w http.ResponseWriter, r *http.Request) {
+	userID, ok := utils.GetUserIDFromContext(r.Context())
+	if !ok || userID == uuid.Nil {
+		response.Error(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	orderID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid order ID")
+		return
+	}
+
+	order, err := h.service.GetOrder(r.Context(), userID, orderID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	response.Success(w, http.StatusOK, "Order retrieved successfully", order)
+}
+
+// POST /api/exchange/orders/{id}/cancel
+func (h *Handler) CancelOrder(w http.ResponseWriter, r *http.Request) {
+	userID, ok := utils.GetUserIDFromContext(r.Context())
+	if !ok || userID == uuid.Nil {
+		response.Error(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	orderID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid order ID")
+		return
+	}
+
+	order, err := h.service.CancelOrder(r.Context(), userID, orderID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	response.Success(w, http.StatusOK, "Order cancelled successfully", order)
+}
+
+// writeError maps order book errors to HTTP responses
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrOrderNotFound), errors.Is(err, ErrUnknownPair):
+		response.Error(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, ErrInvalidOrder), errors.Is(err, wallets.ErrInsufficientFunds):
+		response.Error(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, wallets.ErrWalletFrozen), errors.Is(err, wallets.ErrWalletClosed):
+		response.Error(w, http.StatusForbidden, err.Error())
+	case errors.Is(err, ErrOrderClosed), errors.Is(err, ErrSelfTrade):
+		response.Error(w, http.StatusConflict, err.Error())
+	default:
+		response.Error(w, http.StatusInternalServerError, err.Error())
+	}
+}
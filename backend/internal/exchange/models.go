@@ -0,0 +1,104 @@
+package exchange
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Side is the direction of an order book order, in terms of the pair's base currency
+type Side string
+
+const (
+	SideBuy  Side = "BUY"  // pays the quote currency for the base
+	SideSell Side = "SELL" // pays the base currency for the quote
+)
+
+// OrderStatus represents the state of an order book order
+type OrderStatus string
+
+const (
+	OrderStatusOpen      OrderStatus = "OPEN" // resting on the book, possibly partially filled
+	OrderStatusFilled    OrderStatus = "FILLED"
+	OrderStatusCancelled OrderStatus = "CANCELLED"
+)
+
+// Pair is a tradable market, priced in Quote per unit of Base
+type Pair struct {
+	Base  string `json:"base"`
+	Quote string `json:"quote"`
+}
+
+// Symbol returns the pair as BASE/QUOTE
+func (p Pair) Symbol() string {
+	return p.Base + "/" + p.Quote
+}
+
+// heldCurrency is what an order on side reserves: the quote currency for bids, the base for asks
+func (p Pair) heldCurrency(side Side) string {
+	if side == SideBuy {
+		return p.Quote
+	}
+	return p.Base
+}
+
+// Order is a user's bid or ask. Quantity and Remaining are in the base currency; Held is what
+// is still reserved from the wallet to pay for Remaining (quote for bids, base for asks).
+type Order struct {
+	ID        uuid.UUID   `json:"id"`
+	UserID    uuid.UUID   `json:"user_id"`
+	Pair      string      `json:"pair"`
+	Side      Side        `json:"side"`
+	Price     float64     `json:"price"`
+	Quantity  float64     `json:"quantity"`
+	Remaining float64     `json:"remaining"`
+	Held      float64     `json:"held"`
+	Status    OrderStatus `json:"status"`
+	Seq       int64       `json:"-"` // arrival order, the time in price-time priority
+	CreatedAt time.Time   `json:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at"`
+	ClosedAt  *time.Time  `json:"closed_at,omitempty"`
+}
+
+// Trade is an executed fill between a resting (maker) order and an incoming (taker) order
+type Trade struct {
+	ID          uuid.UUID `json:"id"`
+	Pair        string    `json:"pair"`
+	Price       float64   `json:"price"`
+	Quantity    float64   `json:"quantity"`
+	QuoteAmount float64   `json:"quote_amount"`
+	TakerSide   Side      `json:"taker_side"`
+	BuyOrderID  uuid.UUID `json:"buy_order_id"`
+	SellOrderID uuid.UUID `json:"sell_order_id"`
+	BuyerID     uuid.UUID `json:"-"`
+	SellerID    uuid.UUID `json:"-"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// PriceLevel aggregates the resting orders at one price
+type PriceLevel struct {
+	Price    float64 `json:"price"`
+	Quantity float64 `json:"quantity"`
+	Orders   int     `json:"orders"`
+}
+
+// Depth is an aggregated snapshot of a book, best prices first
+type Depth struct {
+	Pair string       `json:"pair"`
+	Bids []PriceLevel `json:"bids"`
+	Asks []PriceLevel `json:"asks"`
+}
+
+// PlaceOrderRequest represents a request to post a bid or ask
+type PlaceOrderRequest struct {
+	Pair     string  `json:"pair"` // BASE/QUOTE, e.g. USDx/cNGN
+	Side     Side    `json:"side"`
+	Price    float64 `json:"price"`
+	Quantity float64 `json:"quantity"`
+}
+
+// PlaceOrderResult is the placed order together with any trades it executed on arrival
+type PlaceOrderResult struct {
+	Order  *Order   `json:"order"`
+	Trades []*Trade `json:"trades"`
+}
@@ -0,0 +1,230 @@
+package exchange
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Repository handles database operations for the order book
+type Repository struct {
+	db *pgxpool.Pool
+}
+
+// NewRepository creates a new order book repository
+func NewRepository(db *pgxpool.Pool) *Repository {
+	return &Repository{db: db}
+}
+
+const orderColumns = `
+	id, user_id, pair, side, price::float8, quantity::float8, remaining::float8, held::float8,
+	status, seq, created_at, updated_at, closed_at
+`
+
+// scanOrder scans a single order row
+func scanOrder(row pgx.Row) (*Order, error) {
+	var o Order
+	err := row.Scan(
+		&o.ID,
+		&o.UserID,
+		&o.Pair,
+		&o.Side,
+		&o.Price,
+		&o.Quantity,
+		&o.Remaining,
+		&o.Held,
+		&o.Status,
+		&o.Seq,
+		&o.CreatedAt,
+		&o.UpdatedAt,
+		&o.ClosedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+// Create inserts a new order and assigns its arrival sequence
+func (r *Repository) Create(ctx context.Context, o *Order) error {
+	query := `
+		INSERT INTO exchange_orders (
+			id, user_id, pair, side, price, quantity, remaining, held, status, created_at, updated_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING seq
+	`
+
+	err := r.db.QueryRow(
+		ctx,
+		query,
+		o.ID,
+		o.UserID,
+		o.Pair,
+		o.Side,
+		o.Price,
+		o.Quantity,
+		o.Remaining,
+		o.Held,
+		o.Status,
+		o.CreatedAt,
+		o.UpdatedAt,
+	).Scan(&o.Seq)
+	if err != nil {
+		return fmt.Errorf("failed to create order: %w", err)
+	}
+
+	return nil
+}
+
+// GetByID retrieves an order, returning nil when it does not exist
+func (r *Repository) GetByID(ctx context.Context, id uuid.UUID) (*Order, error) {
+	query := `SELECT ` + orderColumns + ` FROM exchange_orders WHERE id = $1`
+
+	order, err := scanOrder(r.db.QueryRow(ctx, query, id))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get order: %w", err)
+	}
+
+	return order, nil
+}
+
+// GetByUserID lists a user's orders, newest first, optionally filtered by status
+func (r *Repository) GetByUserID(ctx context.Context, userID uuid.UUID, status OrderStatus) ([]*Order, error) {
+	if status != "" {
+		query := `SELECT ` + orderColumns + ` FROM exchange_orders WHERE user_id = $1 AND status = $2 ORDER BY seq DESC`
+		return r.list(ctx, query, userID, status)
+	}
+	query := `SELECT ` + orderColumns + ` FROM exchange_orders WHERE user_id = $1 ORDER BY seq DESC`
+	return r.list(ctx, query, userID)
+}
+
+// GetOpen lists every resting order in arrival order, for rebuilding the books
+func (r *Repository) GetOpen(ctx context.Context) ([]*Order, error) {
+	query := `SELECT ` + orderColumns + ` FROM exchange_orders WHERE status = 'OPEN' ORDER BY seq`
+	return r.list(ctx, query)
+}
+
+// list runs an order query and scans every row
+func (r *Repository) list(ctx context.Context, query string, args ...any) ([]*Order, error) {
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list orders: %w", err)
+	}
+	defer rows.Close()
+
+	orders := make([]*Order, 0)
+	for rows.Next() {
+		order, err := scanOrder(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan order: %w", err)
+		}
+		orders = append(orders, order)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating orders: %w", err)
+	}
+
+	return orders, nil
+}
+
+const updateOrderQuery = `
+	UPDATE exchange_orders
+	SET remaining = $1, held = $2, status = $3, updated_at = $4, closed_at = $5
+	WHERE id = $6
+`
+
+// UpdateOrder saves an order's fill state and status
+func (r *Repository) UpdateOrder(ctx context.Context, o *Order) error {
+	if _, err := r.db.Exec(ctx, updateOrderQuery, o.Remaining, o.Held, o.Status, o.UpdatedAt, o.ClosedAt, o.ID); err != nil {
+		return fmt.Errorf("failed to update order: %w", err)
+	}
+	return nil
+}
+
+// RecordTrade stores a trade and the resulting state of both orders as part of tx, the database
+// transaction that settles the trade's funds
+func (r *Repository) RecordTrade(ctx context.Context, tx pgx.Tx, trade *Trade, maker, taker *Order) error {
+	query := `
+		INSERT INTO exchange_trades (
+			id, pair, price, quantity, quote_amount, taker_side,
+			buy_order_id, sell_order_id, buyer_id, seller_id, created_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	`
+	if _, err := tx.Exec(
+		ctx,
+		query,
+		trade.ID,
+		trade.Pair,
+		trade.Price,
+		trade.Quantity,
+		trade.QuoteAmount,
+		trade.TakerSide,
+		trade.BuyOrderID,
+		trade.SellOrderID,
+		trade.BuyerID,
+		trade.SellerID,
+		trade.CreatedAt,
+	); err != nil {
+		return fmt.Errorf("failed to record trade: %w", err)
+	}
+
+	for _, o := range []*Order{maker, taker} {
+		if _, err := tx.Exec(ctx, updateOrderQuery, o.Remaining, o.Held, o.Status, o.UpdatedAt, o.ClosedAt, o.ID); err != nil {
+			return fmt.Errorf("failed to update order: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// GetTrades lists a pair's most recent trades, newest first
+func (r *Repository) GetTrades(ctx context.Context, pair string, limit int) ([]*Trade, error) {
+	query := `
+		SELECT id, pair, price::float8, quantity::float8, quote_amount::float8, taker_side,
+		       buy_order_id, sell_order_id, buyer_id, seller_id, created_at
+		FROM exchange_trades
+		WHERE pair = $1
+		ORDER BY seq DESC
+		LIMIT $2
+	`
+
+	rows, err := r.db.Query(ctx, query, pair, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list trades: %w", err)
+	}
+	defer rows.Close()
+
+	trades := make([]*Trade, 0)
+	for rows.Next() {
+		var t Trade
+		if err := rows.Scan(
+			&t.ID,
+			&t.Pair,
+			&t.Price,
+			&t.Quantity,
+			&t.QuoteAmount,
+			&t.TakerSide,
+			&t.BuyOrderID,
+			&t.SellOrderID,
+			&t.BuyerID,
+			&t.SellerID,
+			&t.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan trade: %w", err)
+		}
+		trades = append(trades, &t)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating trades: %w", err)
+	}
+
+	return trades, nil
+}
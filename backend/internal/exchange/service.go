@@ -0,0 +1,473 @@
+package exchange
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Bwise1/interstellar/internal/transactions"
+	"github.com/Bwise1/interstellar/internal/wallets"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrOrderNotFound = errors.New("order not found")
+	ErrOrderClosed   = errors.New("order is no longer open")
+	ErrInvalidOrder  = errors.New("invalid order")
+	ErrUnknownPair   = errors.New("pair is not traded on the order book")
+)
+
+const (
+	defaultDepthLevels = 20
+	maxDepthLevels     = 100
+	defaultTradeLimit  = 50
+	maxTradeLimit      = 500
+)
+
+// Ledger reserves and releases order funds and settles trades between wallets
+type Ledger interface {
+	ProcessOrderMovement(ctx context.Context, userID uuid.UUID, txType transactions.TransactionType, currency string, amount float64) (*transactions.Transaction, error)
+	ProcessTrade(ctx context.Context, buyer, seller transactions.TradeLeg, record func(tx pgx.Tx) error) ([]*transactions.Transaction, error)
+}
+
+// WalletRepository looks up wallets so orders whose wallet can no longer trade are pulled
+type WalletRepository interface {
+	GetByUserID(ctx context.Context, userID uuid.UUID) (*wallets.Wallet, error)
+}
+
+// market is one pair's book; mu serialises matching and settlement so fills are applied in
+// arrival order
+type market struct {
+	pair Pair
+	mu   sync.Mutex
+	book *Book
+}
+
+// Service runs the order books and settles their trades
+type Service struct {
+	repo    *Repository
+	ledger  Ledger
+	wallets WalletRepository
+	pairs   []Pair
+	markets map[string]*market // by symbol; fixed after construction
+}
+
+// NewService creates a new order book service trading pairs
+func NewService(repo *Repository, ledger Ledger, walletRepo WalletRepository, pairs []Pair) *Service {
+	markets := make(map[string]*market, len(pairs))
+	for _, pair := range pairs {
+		markets[pair.Symbol()] = &market{pair: pair, book: NewBook(pair)}
+	}
+
+	return &Service{
+		repo:    repo,
+		ledger:  ledger,
+		wallets: walletRepo,
+		pairs:   pairs,
+		markets: markets,
+	}
+}
+
+// ParsePair parses BASE/QUOTE; BASE-QUOTE is accepted too so pairs fit in URL paths
+func ParsePair(symbol string) (Pair, error) {
+	base, quote, ok := strings.Cut(strings.TrimSpace(symbol), "/")
+	if !ok {
+		base, quote, ok = strings.Cut(strings.TrimSpace(symbol), "-")
+	}
+	base, quote = strings.TrimSpace(base), strings.TrimSpace(quote)
+	if !ok || base == "" || quote == "" || base == quote {
+		return Pair{}, fmt.Errorf("invalid pair %q, expected BASE/QUOTE", symbol)
+	}
+	return Pair{Base: base, Quote: quote}, nil
+}
+
+// ParsePairs parses a comma-separated pair list such as "USDx/cNGN,EURx/USDx"
+func ParsePairs(list string) ([]Pair, error) {
+	var pairs []Pair
+	seen := make(map[string]bool)
+	for _, symbol := range strings.Split(list, ",") {
+		if strings.TrimSpace(symbol) == "" {
+			continue
+		}
+		pair, err := ParsePair(symbol)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[pair.Symbol()] {
+			seen[pair.Symbol()] = true
+			pairs = append(pairs, pair)
+		}
+	}
+	return pairs, nil
+}
+
+// market looks up a traded pair by symbol
+func (s *Service) market(symbol string) (*market, error) {
+	pair, err := ParsePair(symbol)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownPair, err)
+	}
+	m, ok := s.markets[pair.Symbol()]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownPair, pair.Symbol())
+	}
+	return m, nil
+}
+
+// GetPairs lists the traded pairs
+func (s *Service) GetPairs() []Pair {
+	return s.pairs
+}
+
+// LoadBooks rebuilds the books from the open orders, in arrival order
+func (s *Service) LoadBooks(ctx context.Context) error {
+	orders, err := s.repo.GetOpen(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, order := range orders {
+		m, ok := s.markets[order.Pair]
+		if !ok {
+			// Still cancellable, but it can't trade while the pair is not configured
+			slog.Warn("open order on an untraded pair", "order_id", order.ID, "pair", order.Pair)
+			continue
+		}
+		m.mu.Lock()
+		m.book.Rest(order)
+		m.mu.Unlock()
+	}
+	return nil
+}
+
+// PlaceOrder reserves what the order could pay, matches it against the book and rests any
+// remainder. Trades execute at the resting order's price; a bid filled below its limit gets
+// the unused part of its reservation back.
+func (s *Service) PlaceOrder(ctx context.Context, userID uuid.UUID, req *PlaceOrderRequest) (*PlaceOrderResult, error) {
+	m, err := s.market(req.Pair)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Side = Side(strings.ToUpper(string(req.Side)))
+	quantity := roundAmount(req.Quantity)
+	switch {
+	case req.Side != SideBuy && req.Side != SideSell:
+		return nil, fmt.Errorf("%w: side must be BUY or SELL", ErrInvalidOrder)
+	case math.IsNaN(req.Price) || math.IsInf(req.Price, 0) || req.Price <= 0:
+		return nil, fmt.Errorf("%w: price must be greater than 0", ErrInvalidOrder)
+	case math.IsNaN(quantity) || math.IsInf(quantity, 0) || quantity <= 0:
+		return nil, fmt.Errorf("%w: quantity must be greater than 0", ErrInvalidOrder)
+	}
+
+	now := time.Now()
+	order := &Order{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Pair:      m.pair.Symbol(),
+		Side:      req.Side,
+		Price:     req.Price,
+		Quantity:  quantity,
+		Remaining: quantity,
+		Held:      quantity,
+		Status:    OrderStatusOpen,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if order.Side == SideBuy {
+		order.Held = roundAmount(quantity * req.Price)
+		if order.Held <= 0 {
+			return nil, fmt.Errorf("%w: order value is too small", ErrInvalidOrder)
+		}
+	}
+
+	heldCurrency := m.pair.heldCurrency(order.Side)
+	if _, err := s.ledger.ProcessOrderMovement(ctx, userID, transactions.TransactionTypeOrderReserve, heldCurrency, order.Held); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := s.repo.Create(ctx, order); err != nil {
+		if _, releaseErr := s.ledger.ProcessOrderMovement(ctx, userID, transactions.TransactionTypeOrderRelease, heldCurrency, order.Held); releaseErr != nil {
+			slog.Error("failed to release funds for unsaved order", "user_id", userID, "currency", heldCurrency, "amount", order.Held, "error", releaseErr)
+		}
+		return nil, err
+	}
+
+	// Makers whose wallet was frozen after they rested are pulled as settlement finds them, and the
+	// taker is matched again against what's left
+	skipped := make(map[uuid.UUID]bool)
+	trades := make([]*Trade, 0)
+	for matching := true; matching; {
+		fills, err := m.book.Match(order, func(maker *Order) bool { return skipped[maker.ID] })
+		if err != nil {
+			if cancelErr := s.cancel(ctx, m.pair, order); cancelErr != nil {
+				slog.Error("failed to cancel self-trading order", "order_id", order.ID, "error", cancelErr)
+			}
+			return nil, err
+		}
+
+		matching = false
+		for i, fill := range fills {
+			trade, err := s.settle(ctx, m.pair, fill)
+			if err == nil {
+				trades = append(trades, trade)
+				continue
+			}
+
+			// The remainder would cross the makers that weren't settled, so it can't rest as it is
+			m.book.Revert(fills[i:])
+			if s.pullBlocked(ctx, m, fill.Maker, skipped) {
+				matching = true
+				break
+			}
+			if cancelErr := s.cancel(ctx, m.pair, order); cancelErr != nil {
+				slog.Error("failed to cancel order after settlement failure", "order_id", order.ID, "error", cancelErr)
+			}
+			return nil, fmt.Errorf("trade settlement failed after %d trades: %w", len(trades), err)
+		}
+	}
+
+	if order.Remaining > 0 {
+		m.book.Rest(order)
+	}
+
+	return &PlaceOrderResult{Order: order, Trades: trades}, nil
+}
+
+// pullBlocked takes a maker whose wallet can no longer trade out of the match: the order is
+// cancelled if its funds can be returned, and otherwise skipped while it waits on the book. It
+// reports false when the maker's wallet is fine, so a settlement failure lies elsewhere.
+func (s *Service) pullBlocked(ctx context.Context, m *market, maker *Order, skipped map[uuid.UUID]bool) bool {
+	wallet, err := s.wallets.GetByUserID(ctx, maker.UserID)
+	if err != nil || wallet == nil || (wallet.CanDebit() == nil && wallet.CanCredit() == nil) {
+		return false
+	}
+
+	if m.book.Remove(maker.ID) == nil {
+		return false
+	}
+	if err := s.cancel(ctx, m.pair, maker); err != nil {
+		// A fully frozen wallet can't take its funds back; the order stays until it's unfrozen
+		m.book.Rest(maker)
+		skipped[maker.ID] = true
+		slog.Warn("skipping resting order of a frozen wallet", "order_id", maker.ID, "user_id", maker.UserID, "error", err)
+		return true
+	}
+
+	slog.Info("cancelled resting order of a frozen wallet", "order_id", maker.ID, "user_id", maker.UserID)
+	return true
+}
+
+// settle moves a fill's funds between the two wallets and records the trade and both orders'
+// new state in the same database transaction, so a fill either happens in full or not at all
+func (s *Service) settle(ctx context.Context, pair Pair, fill Fill) (*Trade, error) {
+	maker, taker := fill.Maker, fill.Taker
+	makerRelease := releaseFor(maker, fill.Quantity, fill.MakerRemaining)
+	takerRelease := releaseFor(taker, fill.Quantity, fill.TakerRemaining)
+
+	buy, sell := maker, taker
+	buyRelease, sellRelease := makerRelease, takerRelease
+	if taker.Side == SideBuy {
+		buy, sell = taker, maker
+		buyRelease, sellRelease = takerRelease, makerRelease
+	}
+
+	// Rounding can leave a bid's last fill a fraction of a unit short of the quote it owes
+	quoteAmount := math.Min(roundAmount(fill.Quantity*fill.Price), buyRelease)
+
+	now := time.Now()
+	trade := &Trade{
+		ID:          uuid.New(),
+		Pair:        pair.Symbol(),
+		Price:       fill.Price,
+		Quantity:    fill.Quantity,
+		QuoteAmount: quoteAmount,
+		TakerSide:   taker.Side,
+		BuyOrderID:  buy.ID,
+		SellOrderID: sell.ID,
+		BuyerID:     buy.UserID,
+		SellerID:    sell.UserID,
+		CreatedAt:   now,
+	}
+
+	// The taker's Remaining already reflects every fill of this match; store it as of this one
+	makerState, takerState := *maker, *taker
+	makerState.Remaining, takerState.Remaining = fill.MakerRemaining, fill.TakerRemaining
+	makerState.Held = roundAmount(maker.Held - makerRelease)
+	takerState.Held = roundAmount(taker.Held - takerRelease)
+	applyFill(&makerState, fill.MakerRemaining, now)
+	applyFill(&takerState, fill.TakerRemaining, now)
+
+	if _, err := s.ledger.ProcessTrade(ctx,
+		transactions.TradeLeg{
+			UserID:       buy.UserID,
+			GiveCurrency: pair.Quote,
+			GiveAmount:   quoteAmount,
+			Released:     buyRelease,
+			GetCurrency:  pair.Base,
+			GetAmount:    fill.Quantity,
+		},
+		transactions.TradeLeg{
+			UserID:       sell.UserID,
+			GiveCurrency: pair.Base,
+			GiveAmount:   fill.Quantity,
+			Released:     sellRelease,
+			GetCurrency:  pair.Quote,
+			GetAmount:    quoteAmount,
+		},
+		func(tx pgx.Tx) error {
+			return s.repo.RecordTrade(ctx, tx, trade, &makerState, &takerState)
+		},
+	); err != nil {
+		return nil, err
+	}
+
+	maker.Held, taker.Held = makerState.Held, takerState.Held
+	applyFill(maker, fill.MakerRemaining, now)
+	applyFill(taker, fill.TakerRemaining, now)
+
+	return trade, nil
+}
+
+// releaseFor is the part of an order's held funds consumed by a fill of quantity that leaves
+// remaining; the last fill takes whatever is left so rounding never strands funds
+func releaseFor(order *Order, quantity, remaining float64) float64 {
+	if remaining == 0 {
+		return order.Held
+	}
+	release := quantity
+	if order.Side == SideBuy {
+		release = roundAmount(quantity * order.Price)
+	}
+	return math.Min(release, order.Held)
+}
+
+// applyFill updates an order's status for the quantity left after a fill
+func applyFill(order *Order, remaining float64, at time.Time) {
+	order.UpdatedAt = at
+	if remaining == 0 {
+		order.Status = OrderStatusFilled
+		order.ClosedAt = &at
+	}
+}
+
+// cancel closes an open order and returns its held funds. The order is closed first so its
+// funds can't be released twice; if the release fails it is put back as it was.
+func (s *Service) cancel(ctx context.Context, pair Pair, order *Order) error {
+	now := time.Now()
+	closed := *order
+	closed.Status = OrderStatusCancelled
+	closed.Held = 0
+	closed.UpdatedAt = now
+	closed.ClosedAt = &now
+
+	if err := s.repo.UpdateOrder(ctx, &closed); err != nil {
+		return err
+	}
+
+	if order.Held > 0 {
+		if _, err := s.ledger.ProcessOrderMovement(ctx, order.UserID, transactions.TransactionTypeOrderRelease, pair.heldCurrency(order.Side), order.Held); err != nil {
+			if reopenErr := s.repo.UpdateOrder(ctx, order); reopenErr != nil {
+				slog.Error("failed to reopen order after release failure", "order_id", order.ID, "error", reopenErr)
+			}
+			return fmt.Errorf("failed to release order funds: %w", err)
+		}
+	}
+
+	*order = closed
+	return nil
+}
+
+// CancelOrder takes an open order off the book and returns its held funds
+func (s *Service) CancelOrder(ctx context.Context, userID, orderID uuid.UUID) (*Order, error) {
+	order, err := s.GetOrder(ctx, userID, orderID)
+	if err != nil {
+		return nil, err
+	}
+	pair, err := ParsePair(order.Pair)
+	if err != nil {
+		return nil, err
+	}
+
+	m, traded := s.markets[order.Pair]
+	if !traded {
+		if order.Status != OrderStatusOpen {
+			return nil, ErrOrderClosed
+		}
+		if err := s.cancel(ctx, pair, order); err != nil {
+			return nil, err
+		}
+		return order, nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// The book holds the live copy; it may have filled since it was read
+	live := m.book.Remove(orderID)
+	if live == nil {
+		return nil, ErrOrderClosed
+	}
+
+	if err := s.cancel(ctx, pair, live); err != nil {
+		m.book.Rest(live)
+		return nil, err
+	}
+	return live, nil
+}
+
+// GetOrders lists a user's orders, optionally filtered by status
+func (s *Service) GetOrders(ctx context.Context, userID uuid.UUID, status OrderStatus) ([]*Order, error) {
+	return s.repo.GetByUserID(ctx, userID, status)
+}
+
+// GetOrder returns one of a user's orders
+func (s *Service) GetOrder(ctx context.Context, userID, orderID uuid.UUID) (*Order, error) {
+	order, err := s.repo.GetByID(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if order == nil || order.UserID != userID {
+		return nil, ErrOrderNotFound
+	}
+	return order, nil
+}
+
+// GetDepth returns up to levels aggregated price levels per side of a pair's book
+func (s *Service) GetDepth(symbol string, levels int) (*Depth, error) {
+	m, err := s.market(symbol)
+	if err != nil {
+		return nil, err
+	}
+	if levels <= 0 {
+		levels = defaultDepthLevels
+	}
+	levels = min(levels, maxDepthLevels)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.book.Depth(levels), nil
+}
+
+// GetTrades lists a pair's most recent trades
+func (s *Service) GetTrades(ctx context.Context, symbol string, limit int) ([]*Trade, error) {
+	m, err := s.market(symbol)
+	if err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		limit = defaultTradeLimit
+	}
+	limit = min(limit, maxTradeLimit)
+
+	return s.repo.GetTrades(ctx, m.pair.Symbol(), limit)
+}
@@ -0,0 +1,70 @@
+package fxrates
+
+import (
+	"context"
+	"sync"
+)
+
+// fetchGroup coalesces concurrent refreshes of the same base into one upstream call, like
+// singleflight, but the shared call runs under its own context. That context keeps the first
+// caller's values and is cancelled once every caller waiting on the call has given up, so an
+// abandoned request stops the upstream fetch without one impatient caller failing the rest.
+type fetchGroup struct {
+	mu      sync.Mutex
+	flights map[string]*flight
+}
+
+// flight is one in-progress shared call
+type flight struct {
+	done    chan struct{}
+	err     error
+	waiters int
+	cancel  context.CancelFunc
+}
+
+// Do runs fn for key unless a call for key is already in flight, and waits for the result or
+// for ctx to be done
+func (g *fetchGroup) Do(ctx context.Context, key string, fn func(ctx context.Context) error) error {
+	g.mu.Lock()
+	if g.flights == nil {
+		g.flights = make(map[string]*flight)
+	}
+	f, ok := g.flights[key]
+	if !ok {
+		flightCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		f = &flight{done: make(chan struct{}), cancel: cancel}
+		g.flights[key] = f
+
+		go func() {
+			f.err = fn(flightCtx)
+
+			g.mu.Lock()
+			if g.flights[key] == f {
+				delete(g.flights, key)
+			}
+			g.mu.Unlock()
+
+			cancel()
+			close(f.done)
+		}()
+	}
+	f.waiters++
+	g.mu.Unlock()
+
+	select {
+	case <-f.done:
+		return f.err
+	case <-ctx.Done():
+		g.mu.Lock()
+		f.waiters--
+		if f.waiters == 0 {
+			// Nobody wants the result any more; later callers start a fresh call
+			f.cancel()
+			if g.flights[key] == f {
+				delete(g.flights, key)
+			}
+		}
+		g.mu.Unlock()
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,202 @@
+package fxrates
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// blockingUpstream is a provider endpoint that holds every request until it is released or the
+// request's context is cancelled, and reports which happened
+type blockingUpstream struct {
+	started   chan struct{}
+	release   chan struct{}
+	cancelled chan struct{}
+}
+
+func newBlockingUpstream(t *testing.T) (*blockingUpstream, string) {
+	t.Helper()
+	u := &blockingUpstream{
+		started:   make(chan struct{}, 1),
+		release:   make(chan struct{}),
+		cancelled: make(chan struct{}, 1),
+	}
+	stub := NewStubHandler(map[string]float64{"USD": 1, "NGN": 1500})
+	server, _ := newStubServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u.started <- struct{}{}
+		select {
+		case <-r.Context().Done():
+			u.cancelled <- struct{}{}
+		case <-u.release:
+			stub.ServeHTTP(w, r)
+		}
+	}))
+	// Unblock the handler so the server can close even if a test fails midway
+	t.Cleanup(func() {
+		select {
+		case <-u.release:
+		default:
+			close(u.release)
+		}
+	})
+	return u, server.URL
+}
+
+// waitFor fails the test unless ch receives within a few seconds
+func waitFor(t *testing.T, ch <-chan struct{}, what string) {
+	t.Helper()
+	select {
+	case <-ch:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for %s", what)
+	}
+}
+
+// waitForWaiters blocks until n callers are waiting on key's flight
+func waitForWaiters(t *testing.T, g *fetchGroup, key string, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		g.mu.Lock()
+		f, ok := g.flights[key]
+		waiters := 0
+		if ok {
+			waiters = f.waiters
+		}
+		g.mu.Unlock()
+
+		if waiters == n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d callers waiting on %s, want %d", waiters, key, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// refreshAsync starts a refresh and returns a channel with its result
+func refreshAsync(ctx context.Context, s *Service, base string) <-chan error {
+	result := make(chan error, 1)
+	go func() { result <- s.RefreshCache(ctx, base) }()
+	return result
+}
+
+func TestFlightCancelledWhenLastWaiterGivesUp(t *testing.T) {
+	upstream, url := newBlockingUpstream(t)
+	s := newTestService(NewFastForexProvider("key", url))
+
+	firstCtx, cancelFirst := context.WithCancel(context.Background())
+	secondCtx, cancelSecond := context.WithCancel(context.Background())
+	defer cancelSecond()
+
+	first := refreshAsync(firstCtx, s, "USD")
+	waitFor(t, upstream.started, "the upstream request")
+	second := refreshAsync(secondCtx, s, "USD")
+	waitForWaiters(t, &s.fetches, "USD", 2)
+
+	cancelFirst()
+	if err := <-first; !errors.Is(err, context.Canceled) {
+		t.Fatalf("first caller error = %v, want context.Canceled", err)
+	}
+
+	// The second caller still wants the result, so the upstream request carries on
+	select {
+	case <-upstream.cancelled:
+		t.Fatal("upstream request cancelled while a caller was still waiting")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	cancelSecond()
+	if err := <-second; !errors.Is(err, context.Canceled) {
+		t.Fatalf("second caller error = %v, want context.Canceled", err)
+	}
+	waitFor(t, upstream.cancelled, "the upstream request to be cancelled")
+}
+
+func TestFlightSurvivesImpatientWaiter(t *testing.T) {
+	upstream, url := newBlockingUpstream(t)
+	s := newTestService(NewFastForexProvider("key", url))
+
+	impatientCtx, cancelImpatient := context.WithCancel(context.Background())
+	impatient := refreshAsync(impatientCtx, s, "USD")
+	waitFor(t, upstream.started, "the upstream request")
+	patient := refreshAsync(context.Background(), s, "USD")
+	waitForWaiters(t, &s.fetches, "USD", 2)
+
+	cancelImpatient()
+	if err := <-impatient; !errors.Is(err, context.Canceled) {
+		t.Fatalf("impatient caller error = %v, want context.Canceled", err)
+	}
+
+	close(upstream.release)
+	if err := <-patient; err != nil {
+		t.Fatalf("remaining caller error = %v, want the shared fetch to succeed", err)
+	}
+	select {
+	case <-upstream.cancelled:
+		t.Fatal("upstream request was cancelled")
+	default:
+	}
+
+	rates, ok := s.getCachedRates("USD", false)
+	if !ok || rates.Rates["NGN"] != 1500 {
+		t.Errorf("cached rates = %+v, want the fetched USD table", rates)
+	}
+}
+
+func TestFlightLaterCallerStartsFreshCall(t *testing.T) {
+	var g fetchGroup
+	ctx, cancel := context.WithCancel(context.Background())
+
+	abandoned := make(chan struct{})
+	go g.Do(ctx, "USD", func(ctx context.Context) error {
+		<-ctx.Done()
+		close(abandoned)
+		return ctx.Err()
+	})
+	waitForWaiters(t, &g, "USD", 1)
+	cancel()
+	waitFor(t, abandoned, "the abandoned call to be cancelled")
+
+	// Nobody wanted the first call's result, so it mustn't be shared with a new caller
+	if err := g.Do(context.Background(), "USD", func(ctx context.Context) error { return ctx.Err() }); err != nil {
+		t.Fatalf("fresh call error = %v, want nil", err)
+	}
+}
+
+func TestCancelledColdFetchDoesNotStartCooldown(t *testing.T) {
+	upstream, url := newBlockingUpstream(t)
+	s := newTestService(NewFastForexProvider("key", url))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan error, 1)
+	go func() {
+		_, err := s.GetRates(ctx, "USD")
+		result <- err
+	}()
+	waitFor(t, upstream.started, "the upstream request")
+	waitForWaiters(t, &s.fetches, "USD", 1)
+
+	s.fetches.mu.Lock()
+	abandoned := s.fetches.flights["USD"]
+	s.fetches.mu.Unlock()
+
+	cancel()
+	if err := <-result; !errors.Is(err, context.Canceled) {
+		t.Fatalf("cancelled caller error = %v, want context.Canceled", err)
+	}
+	waitFor(t, abandoned.done, "the abandoned fetch to finish")
+
+	// The next caller fetches again instead of being refused for the cooldown
+	close(upstream.release)
+	rates, err := s.GetRates(context.Background(), "USD")
+	if err != nil {
+		t.Fatalf("GetRates after a cancelled fetch: %v, want a fresh fetch", err)
+	}
+	if rates.Rates["NGN"] != 1500 {
+		t.Errorf("NGN rate = %v, want 1500", rates.Rates["NGN"])
+	}
+}
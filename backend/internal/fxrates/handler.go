@@ -2,10 +2,19 @@ package fxrates
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/Bwise1/interstellar/internal/utils"
 	"github.com/Bwise1/interstellar/pkg/response"
 	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
 )
 
 // Handler handles HTTP requests for FX rates
@@ -27,9 +36,9 @@ func (h *Handler) GetRates(w http.ResponseWriter, r *http.Request) {
 		baseCurrency = "USD" // Default to USD
 	}
 
-	rates, err := h.service.GetRates(baseCurrency)
+	rates, err := h.service.GetRates(r.Context(), baseCurrency)
 	if err != nil {
-		response.Error(w, http.StatusInternalServerError, "Failed to fetch exchange rates: "+err.Error())
+		writeRatesError(w, err)
 		return
 	}
 
@@ -44,9 +53,9 @@ func (h *Handler) GetAllRates(w http.ResponseWriter, r *http.Request) {
 		baseCurrency = "USD"
 	}
 
-	rates, err := h.service.GetRates(baseCurrency)
+	rates, err := h.service.GetRates(r.Context(), baseCurrency)
 	if err != nil {
-		response.Error(w, http.StatusInternalServerError, "Failed to fetch exchange rates: "+err.Error())
+		writeRatesError(w, err)
 		return
 	}
 
@@ -71,8 +80,25 @@ func (h *Handler) Convert(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	result, err := h.service.Convert(req.From, req.To, req.Amount)
+	var result *ConversionResponse
+	var err error
+	if req.At != nil {
+		result, err = h.service.ConvertAt(r.Context(), req.From, req.To, req.Amount, *req.At)
+	} else {
+		result, err = h.service.Convert(r.Context(), req.From, req.To, req.Amount)
+	}
 	if err != nil {
+		switch {
+		case errors.Is(err, ErrMarketUnavailable), errors.Is(err, ErrRatesUnavailable):
+			response.Error(w, http.StatusServiceUnavailable, err.Error())
+			return
+		case errors.Is(err, ErrInvalidAt), errors.Is(err, ErrUnsupportedCurrency):
+			response.Error(w, http.StatusBadRequest, err.Error())
+			return
+		case errors.Is(err, ErrNoHistoricalRate):
+			response.Error(w, http.StatusNotFound, err.Error())
+			return
+		}
 		response.Error(w, http.StatusInternalServerError, "Failed to convert: "+err.Error())
 		return
 	}
@@ -80,18 +106,222 @@ func (h *Handler) Convert(w http.ResponseWriter, r *http.Request) {
 	response.Success(w, http.StatusOK, "Conversion successful", result)
 }
 
-// POST /api/fx-rates/refresh
+// POST /api/admin/fx-rates/refresh
 func (h *Handler) RefreshRates(w http.ResponseWriter, r *http.Request) {
 	baseCurrency := r.URL.Query().Get("base")
 	if baseCurrency == "" {
 		baseCurrency = "USD"
 	}
 
-	if err := h.service.RefreshCache(baseCurrency); err != nil {
+	retryAfter, err := h.service.ManualRefresh(r.Context(), baseCurrency)
+	if err != nil {
+		if errors.Is(err, ErrRefreshCooldown) {
+			seconds := int(math.Ceil(retryAfter.Seconds()))
+			w.Header().Set("Retry-After", strconv.Itoa(seconds))
+			response.Error(w, http.StatusTooManyRequests, fmt.Sprintf("Rates were refreshed recently; retry in %ds", seconds))
+			return
+		}
+		if errors.Is(err, ErrUnsupportedCurrency) {
+			response.Error(w, http.StatusBadRequest, err.Error())
+			return
+		}
 		response.Error(w, http.StatusInternalServerError, "Failed to refresh rates: "+err.Error())
 		return
 	}
 
-	rates := h.service.getCachedRates()
+	rates, _ := h.service.getCachedRates(MapToRealCurrency(baseCurrency), true)
 	response.Success(w, http.StatusOK, "Exchange rates refreshed successfully", rates)
 }
+
+// GET /api/fx-rates/stream?pairs=USD/NGN,cNGN/EURx
+// Server-Sent Events: a "rate" event per pair on connect and whenever the pair's rate, source,
+// staleness or halt state changes, with a comment line as heartbeat while idle.
+func (h *Handler) Stream(w http.ResponseWriter, r *http.Request) {
+	pairs, err := ParsePairs(r.URL.Query().Get("pairs"))
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	for _, pair := range pairs {
+		for _, currency := range pair {
+			if !h.service.SupportsCurrency(currency) {
+				response.Error(w, http.StatusBadRequest, fmt.Sprintf("%s: %s", ErrUnsupportedCurrency, currency))
+				return
+			}
+		}
+	}
+
+	rc := http.NewResponseController(w)
+
+	updates, unsubscribe := h.service.Subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	// write sends one chunk under its own deadline so a stalled client can't hold the handler forever
+	write := func(chunk string) bool {
+		rc.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+		if _, err := io.WriteString(w, chunk); err != nil {
+			return false
+		}
+		return rc.Flush() == nil
+	}
+
+	sent := make(map[string]*RateUpdate, len(pairs))
+	var seq int64
+	push := func() bool {
+		for _, pair := range pairs {
+			update, err := h.service.PairUpdate(r.Context(), pair[0], pair[1])
+			if err != nil {
+				update = &RateUpdate{Pair: pair[0] + "/" + pair[1], Halted: true, HaltReason: err.Error()}
+			}
+			if !update.Changed(sent[update.Pair]) {
+				continue
+			}
+
+			data, _ := json.Marshal(update)
+			seq++
+			if !write(fmt.Sprintf("id: %d\nevent: rate\ndata: %s\n\n", seq, data)) {
+				return false
+			}
+			sent[update.Pair] = update
+		}
+		return true
+	}
+
+	if !write("retry: 5000\n\n") || !push() {
+		return
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-updates:
+			if !push() {
+				return
+			}
+		case <-heartbeat.C:
+			// Also catches changes that don't trigger a refresh, like an override expiring
+			if !push() || !write(": heartbeat\n\n") {
+				return
+			}
+		}
+	}
+}
+
+// GET /api/fx-rates/history?pair=USD/NGN&from=2025-06-01&to=2025-06-30
+func (h *Handler) GetHistory(w http.ResponseWriter, r *http.Request) {
+	base, quote, found := strings.Cut(r.URL.Query().Get("pair"), "/")
+	if !found || base == "" || quote == "" {
+		response.Error(w, http.StatusBadRequest, "pair must look like USD/NGN")
+		return
+	}
+
+	from, to, err := utils.ParseTimeRange(r.URL.Query())
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if !to.After(from) {
+		response.Error(w, http.StatusBadRequest, "from must be before to")
+		return
+	}
+
+	history, err := h.service.GetHistory(r.Context(), base, quote, from, to)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to fetch rate history: "+err.Error())
+		return
+	}
+
+	response.Success(w, http.StatusOK, "Rate history retrieved successfully", history)
+}
+
+// GET /api/admin/fx-rates/quarantine
+func (h *Handler) GetQuarantined(w http.ResponseWriter, r *http.Request) {
+	response.Success(w, http.StatusOK, "Quarantined rates retrieved successfully", h.service.GetQuarantined())
+}
+
+// GET /api/admin/fx-rates/quota
+func (h *Handler) GetQuotaUsage(w http.ResponseWriter, r *http.Request) {
+	response.Success(w, http.StatusOK, "Provider quota usage retrieved successfully", h.service.GetQuotaUsage())
+}
+
+// POST /api/admin/fx-rates/overrides
+func (h *Handler) SetOverride(w http.ResponseWriter, r *http.Request) {
+	actorID, ok := utils.GetUserIDFromContext(r.Context())
+	if !ok {
+		response.Error(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req CreateOverrideRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	override, err := h.service.SetOverride(r.Context(), actorID, &req)
+	if err != nil {
+		if errors.Is(err, ErrInvalidOverride) {
+			response.Error(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		response.Error(w, http.StatusInternalServerError, "Failed to set rate override")
+		return
+	}
+
+	response.Success(w, http.StatusCreated, "Rate override set successfully", override)
+}
+
+// GET /api/admin/fx-rates/overrides
+func (h *Handler) GetOverrides(w http.ResponseWriter, r *http.Request) {
+	response.Success(w, http.StatusOK, "Rate overrides retrieved successfully", h.service.GetOverrides())
+}
+
+// DELETE /api/admin/fx-rates/overrides/{id}
+func (h *Handler) RemoveOverride(w http.ResponseWriter, r *http.Request) {
+	actorID, ok := utils.GetUserIDFromContext(r.Context())
+	if !ok {
+		response.Error(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid override ID")
+		return
+	}
+
+	override, err := h.service.RemoveOverride(r.Context(), actorID, id)
+	if err != nil {
+		if errors.Is(err, ErrOverrideNotFound) {
+			response.Error(w, http.StatusNotFound, "Rate override not found")
+			return
+		}
+		response.Error(w, http.StatusInternalServerError, "Failed to remove rate override")
+		return
+	}
+
+	response.Success(w, http.StatusOK, "Rate override removed successfully", override)
+}
+
+// writeRatesError maps a failed rate lookup to an HTTP status
+func writeRatesError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrUnsupportedCurrency):
+		response.Error(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, ErrRatesUnavailable):
+		response.Error(w, http.StatusServiceUnavailable, err.Error())
+	default:
+		response.Error(w, http.StatusInternalServerError, "Failed to fetch exchange rates: "+err.Error())
+	}
+}
@@ -0,0 +1,123 @@
+package fxrates
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrNoHistoricalRate = errors.New("no stored rate at or before the requested time")
+	ErrInvalidAt        = errors.New("conversion time must not be in the future")
+)
+
+// ConvertAt converts at the rates stored in history as of at. Each leg uses the most recent
+// snapshot fetched at or before at, never a later one, following the same route as a live
+// conversion: the from base's own snapshot if it has one, otherwise a cross through the pivot.
+// Overrides are not applied; stored snapshots only hold rates that passed screening. The
+// response's SnapshotAt is the oldest snapshot used, and Stale is set when that is more than
+// the maximum rate age before at.
+func (s *Service) ConvertAt(ctx context.Context, from, to string, amount float64, at time.Time) (*ConversionResponse, error) {
+	realFrom := MapToRealCurrency(from)
+	realTo := MapToRealCurrency(to)
+	for _, currency := range []string{realFrom, realTo} {
+		if !s.SupportsCurrency(currency) {
+			return nil, fmt.Errorf("%w: %s", ErrUnsupportedCurrency, currency)
+		}
+	}
+
+	if s.repo == nil {
+		return nil, fmt.Errorf("rate history is not enabled")
+	}
+	if at.After(time.Now()) {
+		return nil, ErrInvalidAt
+	}
+
+	conversion := &ConversionResponse{
+		From:   from,
+		To:     to,
+		Amount: amount,
+		Source: RateSourceProvider,
+		At:     &at,
+	}
+
+	var legs []*RatePoint
+	switch {
+	case realFrom == realTo:
+		conversion.Rate = 1
+		conversion.Path = []string{realFrom}
+		conversion.Provider = "par"
+		conversion.Result = amount
+		conversion.LastUpdated = at
+		conversion.SnapshotAt = &at
+		return conversion, nil
+	case realFrom != s.pivot:
+		direct, err := s.repo.GetRateAt(ctx, realFrom, realTo, at)
+		if err != nil {
+			return nil, err
+		}
+		if direct != nil {
+			conversion.Rate = direct.Rate
+			conversion.Path = []string{realFrom, realTo}
+			legs = []*RatePoint{direct}
+		}
+	}
+
+	if legs == nil {
+		fromLeg, err := s.pivotRateAt(ctx, realFrom, at)
+		if err != nil {
+			return nil, err
+		}
+		toLeg, err := s.pivotRateAt(ctx, realTo, at)
+		if err != nil {
+			return nil, err
+		}
+
+		conversion.Rate = toLeg.Rate / fromLeg.Rate
+		switch s.pivot {
+		case realFrom:
+			conversion.Path = []string{realFrom, realTo}
+			legs = []*RatePoint{toLeg}
+		case realTo:
+			conversion.Path = []string{realFrom, realTo}
+			legs = []*RatePoint{fromLeg}
+		default:
+			conversion.Path = []string{realFrom, s.pivot, realTo}
+			legs = []*RatePoint{fromLeg, toLeg}
+		}
+	}
+
+	oldest := legs[0]
+	for _, leg := range legs[1:] {
+		if leg.FetchedAt.Before(oldest.FetchedAt) {
+			oldest = leg
+		}
+	}
+
+	snapshotAt := oldest.FetchedAt
+	conversion.Result = amount * conversion.Rate
+	conversion.Provider = oldest.Provider
+	conversion.LastUpdated = snapshotAt
+	conversion.SnapshotAt = &snapshotAt
+	conversion.AgeSeconds = int64(at.Sub(snapshotAt) / time.Second)
+	conversion.Stale = at.Sub(snapshotAt) > s.maxRateAge
+
+	return conversion, nil
+}
+
+// pivotRateAt returns the stored pivot->currency rate as of at
+func (s *Service) pivotRateAt(ctx context.Context, currency string, at time.Time) (*RatePoint, error) {
+	if currency == s.pivot {
+		return &RatePoint{Rate: 1, Provider: "par", FetchedAt: at}, nil
+	}
+
+	point, err := s.repo.GetRateAt(ctx, s.pivot, currency, at)
+	if err != nil {
+		return nil, err
+	}
+	if point == nil || point.Rate <= 0 {
+		return nil, fmt.Errorf("%w: %s/%s at %s", ErrNoHistoricalRate, s.pivot, currency, at.Format(time.RFC3339))
+	}
+	return point, nil
+}
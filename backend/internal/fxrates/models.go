@@ -1,6 +1,10 @@
 package fxrates
 
-import "time"
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
 
 // FastForexAPIResponse represents the response from FastForex API
 type FastForexAPIResponse struct {
@@ -10,7 +14,7 @@ type FastForexAPIResponse struct {
 	MS      int                `json:"ms"`
 }
 
-// Deprecated: ExchangeRateAPIResponse - keeping for reference
+// ExchangeRateAPIResponse represents the response from ExchangeRate-API (v6)
 type ExchangeRateAPIResponse struct {
 	Result             string             `json:"result"`
 	Documentation      string             `json:"documentation"`
@@ -33,9 +37,15 @@ type FXRate struct {
 
 // FXRatesResponse represents the response sent to clients
 type FXRatesResponse struct {
-	BaseCurrency string             `json:"base_currency"`
-	Rates        map[string]float64 `json:"rates"`
-	LastUpdated  time.Time          `json:"last_updated"`
+	BaseCurrency string                   `json:"base_currency"`
+	Rates        map[string]float64       `json:"rates"`
+	Provider     string                   `json:"provider"`
+	LastUpdated  time.Time                `json:"last_updated"`
+	ExpiresAt    time.Time                `json:"expires_at"`
+	AgeSeconds   int64                    `json:"age_seconds"`
+	Stale        bool                     `json:"stale"`                 // past its TTL; a refresh is pending or failing
+	Quarantined  []string                 `json:"quarantined,omitempty"` // quotes halted for trading; their previous rate is shown
+	Overrides    map[string]*RateOverride `json:"overrides,omitempty"`   // quotes whose rate is admin-pinned
 }
 
 // ConversionRequest represents a currency conversion request
@@ -43,6 +53,9 @@ type ConversionRequest struct {
 	From   string  `json:"from"`
 	To     string  `json:"to"`
 	Amount float64 `json:"amount"`
+
+	// At converts at the stored rates as of this time instead of the live rate
+	At *time.Time `json:"at,omitempty"`
 }
 
 // ConversionResponse represents a currency conversion response
@@ -52,5 +65,83 @@ type ConversionResponse struct {
 	Amount      float64   `json:"amount"`
 	Result      float64   `json:"result"`
 	Rate        float64   `json:"rate"`
+	Path        []string  `json:"path"`   // e.g. [XAF USD KES] when triangulated through the pivot
+	Source      string    `json:"source"` // "provider" or "override"
+	Provider    string    `json:"provider"`
+	LastUpdated time.Time `json:"last_updated"`
+	AgeSeconds  int64     `json:"age_seconds"`
+	Stale       bool      `json:"stale"`
+
+	// InverseRate is to/from derived independently of Rate; Rate*InverseRate should be close to 1
+	InverseRate      *float64 `json:"inverse_rate,omitempty"`
+	InverseDeviation *float64 `json:"inverse_deviation,omitempty"`
+
+	// Historical conversions report the requested time and the stored snapshot actually used
+	At         *time.Time `json:"at,omitempty"`
+	SnapshotAt *time.Time `json:"snapshot_at,omitempty"`
+}
+
+// RateSnapshot is one fetched rate table as persisted in fx_rates
+type RateSnapshot struct {
+	BaseCurrency string
+	Rates        map[string]float64
+	Provider     string
+	FetchedAt    time.Time
+}
+
+// RatePoint is a single stored rate in a pair's history
+type RatePoint struct {
+	Rate      float64   `json:"rate"`
+	Provider  string    `json:"provider"`
+	FetchedAt time.Time `json:"fetched_at"`
+}
+
+// RateHistoryResponse is a rate time series for one pair
+type RateHistoryResponse struct {
+	Base     string      `json:"base"`
+	Quote    string      `json:"quote"`
+	From     time.Time   `json:"from"`
+	To       time.Time   `json:"to"`
+	Inverted bool        `json:"inverted"` // derived from the stored quote/base series
+	Points   []RatePoint `json:"points"`
+}
+
+// Rate sources reported on conversions
+const (
+	RateSourceProvider = "provider"
+	RateSourceOverride = "override"
+)
+
+// RateOverride is an admin-pinned rate for a pair
+type RateOverride struct {
+	ID            uuid.UUID `json:"id"`
+	BaseCurrency  string    `json:"base_currency"`
+	QuoteCurrency string    `json:"quote_currency"`
+	Rate          float64   `json:"rate"`
+	Reason        string    `json:"reason"`
+	CreatedBy     uuid.UUID `json:"created_by"`
+	CreatedAt     time.Time `json:"created_at"`
+	ExpiresAt     time.Time `json:"expires_at"`
+}
+
+// CreateOverrideRequest pins a rate for a pair until ExpiresAt
+type CreateOverrideRequest struct {
+	Base      string    `json:"base"`
+	Quote     string    `json:"quote"`
+	Rate      float64   `json:"rate"`
+	Reason    string    `json:"reason"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// RateUpdate is one pair's state pushed to streaming clients
+type RateUpdate struct {
+	Pair        string    `json:"pair"`
+	Rate        float64   `json:"rate"`
+	Path        []string  `json:"path"`
+	Source      string    `json:"source"`
+	Provider    string    `json:"provider"`
 	LastUpdated time.Time `json:"last_updated"`
+	Stale       bool      `json:"stale"`
+	Halted      bool      `json:"halted"` // trading is halted by the circuit breaker
+	HaltReason  string    `json:"halt_reason,omitempty"`
 }
@@ -0,0 +1,235 @@
+package fxrates
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Bwise1/interstellar/internal/auditlogs"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInvalidOverride  = errors.New("invalid rate override")
+	ErrOverrideNotFound = errors.New("rate override not found")
+)
+
+// overrideStore holds active admin-pinned rates keyed by "BASE/QUOTE"
+type overrideStore struct {
+	mu      sync.RWMutex
+	entries map[string]*RateOverride
+}
+
+// LoadOverrides loads the active overrides from the database into memory
+func (s *Service) LoadOverrides(ctx context.Context) error {
+	if s.repo == nil {
+		return nil
+	}
+
+	overrides, err := s.repo.GetActiveOverrides(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+
+	s.overrides.mu.Lock()
+	defer s.overrides.mu.Unlock()
+
+	for _, override := range overrides {
+		s.overrides.entries[pairKey(override.BaseCurrency, override.QuoteCurrency)] = override
+	}
+	return nil
+}
+
+// SetOverride pins a rate for a pair until it expires, replacing any active override for the pair in
+// either direction. Pinned rates take precedence over provider data and keep the pair tradable while its feed is quarantined.
+func (s *Service) SetOverride(ctx context.Context, actorID uuid.UUID, req *CreateOverrideRequest) (*RateOverride, error) {
+	base := MapToRealCurrency(strings.TrimSpace(req.Base))
+	quote := MapToRealCurrency(strings.TrimSpace(req.Quote))
+	now := time.Now()
+
+	switch {
+	case base == "" || quote == "" || base == quote:
+		return nil, fmt.Errorf("%w: base and quote must be two different currencies", ErrInvalidOverride)
+	case math.IsNaN(req.Rate) || math.IsInf(req.Rate, 0) || req.Rate <= 0:
+		return nil, fmt.Errorf("%w: rate must be greater than 0", ErrInvalidOverride)
+	case strings.TrimSpace(req.Reason) == "":
+		return nil, fmt.Errorf("%w: reason is required", ErrInvalidOverride)
+	case !req.ExpiresAt.After(now):
+		return nil, fmt.Errorf("%w: expires_at must be in the future", ErrInvalidOverride)
+	}
+
+	override := &RateOverride{
+		ID:            uuid.New(),
+		BaseCurrency:  base,
+		QuoteCurrency: quote,
+		Rate:          req.Rate,
+		Reason:        strings.TrimSpace(req.Reason),
+		CreatedBy:     actorID,
+		CreatedAt:     now,
+		ExpiresAt:     req.ExpiresAt,
+	}
+
+	// Store first without holding the lock, so rate reads never wait on the database
+	if s.repo != nil {
+		if err := s.repo.CreateOverride(ctx, override); err != nil {
+			return nil, err
+		}
+	}
+
+	s.overrides.mu.Lock()
+	replaced := s.replaceOverride(base, quote, override)
+	s.overrides.mu.Unlock()
+
+	if replaced {
+		s.notifySubscribers()
+	}
+
+	s.auditOverride(ctx, actorID, "FX_RATE_OVERRIDE_SET", override)
+	return override, nil
+}
+
+// RemoveOverride revokes an active override so the pair falls back to provider rates
+func (s *Service) RemoveOverride(ctx context.Context, actorID, id uuid.UUID) (*RateOverride, error) {
+	key, override := s.findOverride(id)
+	if override == nil {
+		return nil, ErrOverrideNotFound
+	}
+
+	if s.repo != nil {
+		if err := s.repo.RevokeOverride(ctx, id, time.Now()); err != nil {
+			return nil, err
+		}
+	}
+
+	// The pair may have been re-pinned while the revocation was stored; only drop this override
+	s.overrides.mu.Lock()
+	removed := s.overrides.entries[key] == override
+	if removed {
+		delete(s.overrides.entries, key)
+	}
+	s.overrides.mu.Unlock()
+
+	if removed {
+		s.notifySubscribers()
+	}
+	s.auditOverride(ctx, actorID, "FX_RATE_OVERRIDE_REMOVED", override)
+	return override, nil
+}
+
+// replaceOverride installs an override for base/quote in place of any for the pair in either
+// direction, unless a newer one was installed concurrently. It reports whether it changed the
+// store. The caller must hold the write lock.
+func (s *Service) replaceOverride(base, quote string, override *RateOverride) bool {
+	for _, key := range []string{pairKey(base, quote), pairKey(quote, base)} {
+		if existing, ok := s.overrides.entries[key]; ok && existing.CreatedAt.After(override.CreatedAt) {
+			return false
+		}
+	}
+
+	delete(s.overrides.entries, pairKey(quote, base))
+	s.overrides.entries[pairKey(base, quote)] = override
+	return true
+}
+
+// findOverride looks up an override by ID, returning its key
+func (s *Service) findOverride(id uuid.UUID) (string, *RateOverride) {
+	s.overrides.mu.RLock()
+	defer s.overrides.mu.RUnlock()
+
+	for k, o := range s.overrides.entries {
+		if o.ID == id {
+			return k, o
+		}
+	}
+	return "", nil
+}
+
+// GetOverrides returns the overrides that have not yet expired
+func (s *Service) GetOverrides() []*RateOverride {
+	s.overrides.mu.RLock()
+	defer s.overrides.mu.RUnlock()
+
+	now := time.Now()
+	overrides := make([]*RateOverride, 0, len(s.overrides.entries))
+	for _, override := range s.overrides.entries {
+		if override.ExpiresAt.After(now) {
+			overrides = append(overrides, override)
+		}
+	}
+	sort.Slice(overrides, func(i, j int) bool {
+		return overrides[i].CreatedAt.After(overrides[j].CreatedAt)
+	})
+	return overrides
+}
+
+// activeOverride returns the pinned rate for base/quote, inverting an override set on quote/base
+func (s *Service) activeOverride(base, quote string) (*RateOverride, float64, bool) {
+	s.overrides.mu.RLock()
+	defer s.overrides.mu.RUnlock()
+
+	now := time.Now()
+	if o, exists := s.overrides.entries[pairKey(base, quote)]; exists && o.ExpiresAt.After(now) {
+		return o, o.Rate, true
+	}
+	if o, exists := s.overrides.entries[pairKey(quote, base)]; exists && o.ExpiresAt.After(now) {
+		return o, 1 / o.Rate, true
+	}
+	return nil, 0, false
+}
+
+// applyOverrides returns a copy of a base's rates with pinned rates substituted, and the overrides used keyed by quote
+func (s *Service) applyOverrides(baseCurrency string, rates map[string]float64) (map[string]float64, map[string]*RateOverride) {
+	s.overrides.mu.RLock()
+	empty := len(s.overrides.entries) == 0
+	s.overrides.mu.RUnlock()
+	if empty {
+		return rates, nil
+	}
+
+	applied := make(map[string]*RateOverride)
+	result := make(map[string]float64, len(rates))
+	for quote, rate := range rates {
+		if override, pinned, ok := s.activeOverride(baseCurrency, quote); ok {
+			result[quote] = pinned
+			applied[quote] = override
+			continue
+		}
+		result[quote] = rate
+	}
+
+	if len(applied) == 0 {
+		return rates, nil
+	}
+	return result, applied
+}
+
+// auditOverride records an override change; failures are logged rather than undoing the change
+func (s *Service) auditOverride(ctx context.Context, actorID uuid.UUID, operation string, override *RateOverride) {
+	if s.auditLogger == nil {
+		return
+	}
+
+	details, _ := json.Marshal(map[string]string{
+		"override_id": override.ID.String(),
+		"pair":        pairKey(override.BaseCurrency, override.QuoteCurrency),
+		"rate":        strconv.FormatFloat(override.Rate, 'f', -1, 64),
+		"reason":      override.Reason,
+		"expires_at":  override.ExpiresAt.Format(time.RFC3339),
+	})
+	if err := s.auditLogger.LogRequest(ctx, &auditlogs.CreateAuditLogRequest{
+		UserID:      &actorID,
+		Operation:   operation,
+		ClientIP:    "internal",
+		RequestBody: string(details),
+	}); err != nil {
+		slog.Error("failed to audit fx rate override", "override_id", override.ID, "error", err)
+	}
+}
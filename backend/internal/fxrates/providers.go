@@ -0,0 +1,186 @@
+package fxrates
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+const (
+	fastForexBaseURL       = "https://api.fastforex.io"
+	exchangeRateAPIBaseURL = "https://v6.exchangerate-api.com"
+	providerTimeout        = 10 * time.Second
+)
+
+// RateProvider fetches a full set of rates for a base currency from one upstream source
+type RateProvider interface {
+	Name() string
+	FetchRates(ctx context.Context, baseCurrency string) (map[string]float64, error)
+}
+
+// FastForexProvider fetches rates from FastForex
+type FastForexProvider struct {
+	apiKey     string
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewFastForexProvider creates a FastForex provider. An empty baseURL uses the public API.
+func NewFastForexProvider(apiKey, baseURL string) *FastForexProvider {
+	if baseURL == "" {
+		baseURL = fastForexBaseURL
+	}
+	return &FastForexProvider{
+		apiKey:     apiKey,
+		baseURL:    baseURL,
+		httpClient: &http.Client{Timeout: providerTimeout},
+	}
+}
+
+// Name identifies the provider in responses and logs
+func (p *FastForexProvider) Name() string { return "fastforex" }
+
+// FetchRates fetches rates from FastForex
+func (p *FastForexProvider) FetchRates(ctx context.Context, baseCurrency string) (map[string]float64, error) {
+	url := fmt.Sprintf("%s/fetch-all?from=%s&api_key=%s", p.baseURL, baseCurrency, p.apiKey)
+
+	var apiResp FastForexAPIResponse
+	if err := getJSON(ctx, p.httpClient, url, &apiResp); err != nil {
+		return nil, err
+	}
+
+	if len(apiResp.Results) == 0 {
+		return nil, fmt.Errorf("API returned empty results")
+	}
+
+	return apiResp.Results, nil
+}
+
+// ExchangeRateAPIProvider fetches rates from ExchangeRate-API (v6)
+type ExchangeRateAPIProvider struct {
+	apiKey     string
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewExchangeRateAPIProvider creates an ExchangeRate-API provider. An empty baseURL uses the public API.
+func NewExchangeRateAPIProvider(apiKey, baseURL string) *ExchangeRateAPIProvider {
+	if baseURL == "" {
+		baseURL = exchangeRateAPIBaseURL
+	}
+	return &ExchangeRateAPIProvider{
+		apiKey:     apiKey,
+		baseURL:    baseURL,
+		httpClient: &http.Client{Timeout: providerTimeout},
+	}
+}
+
+// Name identifies the provider in responses and logs
+func (p *ExchangeRateAPIProvider) Name() string { return "exchangerate-api" }
+
+// FetchRates fetches rates from ExchangeRate-API
+func (p *ExchangeRateAPIProvider) FetchRates(ctx context.Context, baseCurrency string) (map[string]float64, error) {
+	url := fmt.Sprintf("%s/v6/%s/latest/%s", p.baseURL, p.apiKey, baseCurrency)
+
+	var apiResp ExchangeRateAPIResponse
+	if err := getJSON(ctx, p.httpClient, url, &apiResp); err != nil {
+		return nil, err
+	}
+
+	if apiResp.Result != "success" {
+		return nil, fmt.Errorf("API returned result %q", apiResp.Result)
+	}
+	if len(apiResp.ConversionRates) == 0 {
+		return nil, fmt.Errorf("API returned empty results")
+	}
+
+	return apiResp.ConversionRates, nil
+}
+
+// StaticProvider serves rates from a JSON file of the form {"base": "USD", "rates": {"NGN": 1550}}.
+// Other bases are derived as cross rates, so one file covers every pair.
+type StaticProvider struct {
+	path string
+}
+
+// NewStaticProvider creates a provider backed by a rates file
+func NewStaticProvider(path string) *StaticProvider {
+	return &StaticProvider{path: path}
+}
+
+// Name identifies the provider in responses and logs
+func (p *StaticProvider) Name() string { return "static" }
+
+// FetchRates reads the file on every call so edits take effect on the next refresh
+func (p *StaticProvider) FetchRates(ctx context.Context, baseCurrency string) (map[string]float64, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(p.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read static rates: %w", err)
+	}
+
+	var file struct {
+		Base  string             `json:"base"`
+		Rates map[string]float64 `json:"rates"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to decode static rates: %w", err)
+	}
+
+	return crossRates(file.Base, file.Rates, baseCurrency)
+}
+
+// crossRates rebases a rate table quoted against from onto a new base
+func crossRates(from string, rates map[string]float64, base string) (map[string]float64, error) {
+	if base == from {
+		return rates, nil
+	}
+
+	pivot, exists := rates[base]
+	if !exists || pivot <= 0 {
+		return nil, fmt.Errorf("no %s rate to derive %s base", from, base)
+	}
+
+	rebased := make(map[string]float64, len(rates))
+	rebased[from] = 1 / pivot
+	for currency, rate := range rates {
+		if currency != base {
+			rebased[currency] = rate / pivot
+		}
+	}
+	return rebased, nil
+}
+
+// getJSON issues a GET request bound to ctx and decodes a JSON body, surfacing non-200 responses
+// as errors. Cancelling ctx aborts the request, including a body still being read.
+func getJSON(ctx context.Context, client *http.Client, url string, target interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to build request: %w", err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch rates: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		// Read error body for more details
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(target); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,195 @@
+package fxrates
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	defaultRefreshCooldown = time.Minute
+	defaultQuotaReserve    = 0.1 // share of each monthly quota kept back for when it's really needed
+)
+
+var (
+	ErrRefreshCooldown  = errors.New("rates were refreshed recently")
+	ErrQuotaNearlySpent = errors.New("provider quota nearly exhausted")
+	ErrRatesUnavailable = errors.New("rates temporarily unavailable")
+)
+
+// attemptLog remembers each base's last upstream fetch, whatever its outcome, so on-demand
+// requests can't call upstream for a base more than once per cooldown
+type attemptLog struct {
+	mu      sync.Mutex
+	entries map[string]fetchAttempt
+}
+
+// fetchAttempt is the outcome of a base's last upstream fetch
+type fetchAttempt struct {
+	at  time.Time
+	err error
+}
+
+// quotaTracker counts upstream requests per provider for the current calendar month (UTC)
+type quotaTracker struct {
+	mu      sync.Mutex
+	limits  map[string]int64 // monthly request limit per provider; providers without one are unmetered
+	reserve float64
+	period  time.Time
+	used    map[string]int64
+}
+
+// QuotaUsage reports a provider's upstream request usage for the current month
+type QuotaUsage struct {
+	Provider  string    `json:"provider"`
+	Period    time.Time `json:"period"`
+	Used      int64     `json:"used"`
+	Limit     int64     `json:"limit"`
+	Remaining int64     `json:"remaining"`
+	Throttled bool      `json:"throttled"` // within the reserve; the provider is skipped and cached rates are served
+}
+
+// quotaPeriod returns the first day of the month containing t, in UTC
+func quotaPeriod(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// rollover resets the counters when a new month starts; callers hold mu
+func (q *quotaTracker) rollover(now time.Time) {
+	if period := quotaPeriod(now); !period.Equal(q.period) {
+		q.period = period
+		q.used = make(map[string]int64)
+	}
+}
+
+// throttled reports whether a metered provider has used up everything but its reserve; callers hold mu
+func (q *quotaTracker) throttled(provider string) bool {
+	limit, metered := q.limits[provider]
+	if !metered || limit <= 0 {
+		return false
+	}
+	return float64(q.used[provider]) >= float64(limit)*(1-q.reserve)
+}
+
+// LoadQuotaUsage seeds this month's request counters from the database
+func (s *Service) LoadQuotaUsage(ctx context.Context) error {
+	if s.repo == nil || len(s.quota.limits) == 0 {
+		return nil
+	}
+
+	s.quota.mu.Lock()
+	defer s.quota.mu.Unlock()
+
+	s.quota.rollover(time.Now())
+	used, err := s.repo.GetProviderUsage(ctx, s.quota.period)
+	if err != nil {
+		return err
+	}
+	for provider, count := range used {
+		s.quota.used[provider] = count
+	}
+	return nil
+}
+
+// reserveFetch counts one upstream request against a provider's quota, refusing it once the
+// provider is within its reserve
+func (s *Service) reserveFetch(ctx context.Context, provider string) error {
+	s.quota.mu.Lock()
+	s.quota.rollover(time.Now())
+	if s.quota.throttled(provider) {
+		s.quota.mu.Unlock()
+		return ErrQuotaNearlySpent
+	}
+	s.quota.used[provider]++
+	period := s.quota.period
+	_, metered := s.quota.limits[provider]
+	s.quota.mu.Unlock()
+
+	if metered && s.repo != nil {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), persistTimeout)
+		defer cancel()
+
+		if err := s.repo.IncrementProviderUsage(ctx, provider, period); err != nil {
+			slog.Error("failed to record fx provider usage", "provider", provider, "error", err)
+		}
+	}
+	return nil
+}
+
+// GetQuotaUsage returns this month's usage for every metered provider
+func (s *Service) GetQuotaUsage() []QuotaUsage {
+	s.quota.mu.Lock()
+	defer s.quota.mu.Unlock()
+
+	s.quota.rollover(time.Now())
+	usage := make([]QuotaUsage, 0, len(s.quota.limits))
+	for provider, limit := range s.quota.limits {
+		used := s.quota.used[provider]
+		usage = append(usage, QuotaUsage{
+			Provider:  provider,
+			Period:    s.quota.period,
+			Used:      used,
+			Limit:     limit,
+			Remaining: max(limit-used, 0),
+			Throttled: s.quota.throttled(provider),
+		})
+	}
+	sort.Slice(usage, func(i, j int) bool { return usage[i].Provider < usage[j].Provider })
+	return usage
+}
+
+// recordAttempt notes that an upstream fetch for a base just finished
+func (s *Service) recordAttempt(baseCurrency string, err error) {
+	s.attempts.mu.Lock()
+	defer s.attempts.mu.Unlock()
+	s.attempts.entries[baseCurrency] = fetchAttempt{at: time.Now(), err: err}
+}
+
+// recentAttempt returns a base's last upstream fetch if it finished within the cooldown, and how
+// long until the cooldown ends
+func (s *Service) recentAttempt(baseCurrency string) (fetchAttempt, time.Duration, bool) {
+	s.attempts.mu.Lock()
+	attempt, exists := s.attempts.entries[baseCurrency]
+	s.attempts.mu.Unlock()
+
+	if !exists {
+		return fetchAttempt{}, 0, false
+	}
+	if since := time.Since(attempt.at); since < s.refreshCooldown {
+		return attempt, s.refreshCooldown - since, true
+	}
+	return fetchAttempt{}, 0, false
+}
+
+// refreshOnDemand refreshes a base for a caller unless it was already fetched within the
+// cooldown. A failed fetch is remembered for the cooldown and returned again as
+// ErrRatesUnavailable, so callers can't retry a failing base into the provider's quota.
+func (s *Service) refreshOnDemand(ctx context.Context, baseCurrency string) error {
+	if attempt, _, recent := s.recentAttempt(baseCurrency); recent {
+		if attempt.err != nil {
+			return fmt.Errorf("%w: %v", ErrRatesUnavailable, attempt.err)
+		}
+		return ErrRatesUnavailable
+	}
+	return s.RefreshCache(ctx, baseCurrency)
+}
+
+// ManualRefresh refreshes a base on demand unless it was fetched within the cooldown, whether or
+// not that fetch succeeded, in which case it returns ErrRefreshCooldown and how long to wait
+func (s *Service) ManualRefresh(ctx context.Context, baseCurrency string) (time.Duration, error) {
+	baseCurrency = MapToRealCurrency(baseCurrency)
+	if !s.SupportsCurrency(baseCurrency) {
+		return 0, ErrUnsupportedCurrency
+	}
+
+	if _, wait, recent := s.recentAttempt(baseCurrency); recent {
+		return wait, ErrRefreshCooldown
+	}
+
+	return 0, s.RefreshCache(ctx, baseCurrency)
+}
@@ -0,0 +1,105 @@
+package fxrates
+
+import (
+	"context"
+	"log/slog"
+	"math/rand/v2"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	refreshRetryBase     = 2 * time.Second
+	refreshRetryAttempts = 5
+)
+
+// RunRefresher keeps every configured base fresh in the background until ctx is cancelled.
+// Each base is refreshed at the shortest interval configured for any of its pairs.
+func (s *Service) RunRefresher(ctx context.Context) {
+	intervals := baseIntervals(s.refreshIntervals)
+	if len(intervals) == 0 {
+		return
+	}
+
+	var wg sync.WaitGroup
+	for base, interval := range intervals {
+		wg.Add(1)
+		go func(base string, interval time.Duration) {
+			defer wg.Done()
+			s.refreshLoop(ctx, base, interval)
+		}(base, interval)
+	}
+
+	wg.Wait()
+	slog.Info("fx rate refresher stopped")
+}
+
+// refreshLoop refreshes one base immediately and then on every interval
+func (s *Service) refreshLoop(ctx context.Context, base string, interval time.Duration) {
+	slog.Info("fx rate refresher started", "base", base, "interval", interval)
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+			s.refreshWithRetry(ctx, base, interval)
+			timer.Reset(interval)
+		}
+	}
+}
+
+// refreshWithRetry retries a failed refresh with jittered exponential backoff, giving up once
+// the next scheduled refresh would be due anyway
+func (s *Service) refreshWithRetry(ctx context.Context, base string, interval time.Duration) {
+	delay := refreshRetryBase
+	deadline := time.Now().Add(interval)
+
+	for attempt := 1; ; attempt++ {
+		err := s.RefreshCache(ctx, base)
+		if err == nil {
+			return
+		}
+
+		wait := jitter(delay)
+		if attempt >= refreshRetryAttempts || time.Now().Add(wait).After(deadline) {
+			slog.Error("fx rate refresh failed, serving cached rates", "base", base, "attempts", attempt, "error", err)
+			return
+		}
+
+		slog.Warn("fx rate refresh failed, retrying", "base", base, "attempt", attempt, "retry_in", wait, "error", err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+		delay *= 2
+	}
+}
+
+// jitter spreads a delay uniformly over [d/2, 3d/2) so retries from many bases don't align
+func jitter(d time.Duration) time.Duration {
+	return d/2 + time.Duration(rand.Int64N(int64(d)))
+}
+
+// baseIntervals reduces pair ("USD/NGN") and base ("USD") intervals to the shortest per base
+func baseIntervals(intervals map[string]time.Duration) map[string]time.Duration {
+	bases := make(map[string]time.Duration)
+	for key, interval := range intervals {
+		if interval <= 0 {
+			continue
+		}
+
+		base, _, _ := strings.Cut(key, "/")
+		base = MapToRealCurrency(strings.TrimSpace(base))
+		if current, exists := bases[base]; !exists || interval < current {
+			bases[base] = interval
+		}
+	}
+	return bases
+}
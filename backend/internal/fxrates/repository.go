@@ -0,0 +1,270 @@
+package fxrates
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Repository handles database operations for FX rate history
+type Repository struct {
+	db *pgxpool.Pool
+}
+
+// NewRepository creates a new FX rate repository
+func NewRepository(db *pgxpool.Pool) *Repository {
+	return &Repository{db: db}
+}
+
+// SaveSnapshot stores every quote of one fetched rate table
+func (r *Repository) SaveSnapshot(ctx context.Context, snapshot *RateSnapshot) error {
+	rows := make([][]interface{}, 0, len(snapshot.Rates))
+	for quote, rate := range snapshot.Rates {
+		rows = append(rows, []interface{}{snapshot.BaseCurrency, quote, rate, snapshot.Provider, snapshot.FetchedAt})
+	}
+
+	_, err := r.db.CopyFrom(
+		ctx,
+		pgx.Identifier{"fx_rates"},
+		[]string{"base_currency", "quote_currency", "rate", "provider", "fetched_at"},
+		pgx.CopyFromRows(rows),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save rate snapshot: %w", err)
+	}
+
+	return nil
+}
+
+// GetLatestSnapshots returns the most recent stored rate table for every base
+func (r *Repository) GetLatestSnapshots(ctx context.Context) ([]*RateSnapshot, error) {
+	query := `
+		SELECT f.base_currency, f.quote_currency, f.rate::float8, f.provider, f.fetched_at
+		FROM fx_rates f
+		JOIN (
+			SELECT base_currency, MAX(fetched_at) AS fetched_at
+			FROM fx_rates
+			GROUP BY base_currency
+		) latest ON latest.base_currency = f.base_currency AND latest.fetched_at = f.fetched_at
+	`
+
+	rows, err := r.db.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest rate snapshots: %w", err)
+	}
+	defer rows.Close()
+
+	snapshots := make(map[string]*RateSnapshot)
+	for rows.Next() {
+		var base, quote, provider string
+		var rate float64
+		var fetchedAt time.Time
+		if err := rows.Scan(&base, &quote, &rate, &provider, &fetchedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan rate snapshot: %w", err)
+		}
+
+		snapshot, exists := snapshots[base]
+		if !exists {
+			snapshot = &RateSnapshot{
+				BaseCurrency: base,
+				Rates:        make(map[string]float64),
+				Provider:     provider,
+				FetchedAt:    fetchedAt,
+			}
+			snapshots[base] = snapshot
+		}
+		snapshot.Rates[quote] = rate
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rate snapshots: %w", err)
+	}
+
+	result := make([]*RateSnapshot, 0, len(snapshots))
+	for _, snapshot := range snapshots {
+		result = append(result, snapshot)
+	}
+	return result, nil
+}
+
+// GetHistory returns stored rates for one pair within [from, to], oldest first
+func (r *Repository) GetHistory(ctx context.Context, base, quote string, from, to time.Time, limit int) ([]RatePoint, error) {
+	query := `
+		SELECT rate::float8, provider, fetched_at
+		FROM fx_rates
+		WHERE base_currency = $1 AND quote_currency = $2 AND fetched_at BETWEEN $3 AND $4
+		ORDER BY fetched_at
+		LIMIT $5
+	`
+
+	rows, err := r.db.Query(ctx, query, base, quote, from, to, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rate history: %w", err)
+	}
+	defer rows.Close()
+
+	points := make([]RatePoint, 0)
+	for rows.Next() {
+		var point RatePoint
+		if err := rows.Scan(&point.Rate, &point.Provider, &point.FetchedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan rate history: %w", err)
+		}
+		points = append(points, point)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rate history: %w", err)
+	}
+
+	return points, nil
+}
+
+// GetRateAt returns the most recent stored rate for a pair fetched at or before at, or nil if
+// there is none
+func (r *Repository) GetRateAt(ctx context.Context, base, quote string, at time.Time) (*RatePoint, error) {
+	query := `
+		SELECT rate::float8, provider, fetched_at
+		FROM fx_rates
+		WHERE base_currency = $1 AND quote_currency = $2 AND fetched_at <= $3
+		ORDER BY fetched_at DESC
+		LIMIT 1
+	`
+
+	var point RatePoint
+	err := r.db.QueryRow(ctx, query, base, quote, at).Scan(&point.Rate, &point.Provider, &point.FetchedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get historical rate: %w", err)
+	}
+
+	return &point, nil
+}
+
+// CreateOverride stores an override and revokes any active override for the same pair in either direction
+func (r *Repository) CreateOverride(ctx context.Context, override *RateOverride) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	revoke := `
+		UPDATE fx_rate_overrides
+		SET revoked_at = $3
+		WHERE revoked_at IS NULL
+		  AND ((base_currency = $1 AND quote_currency = $2) OR (base_currency = $2 AND quote_currency = $1))
+	`
+	if _, err := tx.Exec(ctx, revoke, override.BaseCurrency, override.QuoteCurrency, override.CreatedAt); err != nil {
+		return fmt.Errorf("failed to revoke previous rate overrides: %w", err)
+	}
+
+	insert := `
+		INSERT INTO fx_rate_overrides (id, base_currency, quote_currency, rate, reason, created_by, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+	if _, err := tx.Exec(ctx, insert,
+		override.ID,
+		override.BaseCurrency,
+		override.QuoteCurrency,
+		override.Rate,
+		override.Reason,
+		override.CreatedBy,
+		override.CreatedAt,
+		override.ExpiresAt,
+	); err != nil {
+		return fmt.Errorf("failed to create rate override: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit rate override: %w", err)
+	}
+	return nil
+}
+
+// GetActiveOverrides returns overrides that are neither revoked nor expired at the given time
+func (r *Repository) GetActiveOverrides(ctx context.Context, at time.Time) ([]*RateOverride, error) {
+	query := `
+		SELECT id, base_currency, quote_currency, rate::float8, reason, created_by, created_at, expires_at
+		FROM fx_rate_overrides
+		WHERE revoked_at IS NULL AND expires_at > $1
+		ORDER BY created_at
+	`
+
+	rows, err := r.db.Query(ctx, query, at)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rate overrides: %w", err)
+	}
+	defer rows.Close()
+
+	var overrides []*RateOverride
+	for rows.Next() {
+		var o RateOverride
+		if err := rows.Scan(&o.ID, &o.BaseCurrency, &o.QuoteCurrency, &o.Rate, &o.Reason, &o.CreatedBy, &o.CreatedAt, &o.ExpiresAt); err != nil {
+			return nil, fmt.Errorf("failed to scan rate override: %w", err)
+		}
+		overrides = append(overrides, &o)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rate overrides: %w", err)
+	}
+
+	return overrides, nil
+}
+
+// RevokeOverride marks an override as revoked
+func (r *Repository) RevokeOverride(ctx context.Context, id uuid.UUID, at time.Time) error {
+	query := `UPDATE fx_rate_overrides SET revoked_at = $2 WHERE id = $1 AND revoked_at IS NULL`
+
+	if _, err := r.db.Exec(ctx, query, id, at); err != nil {
+		return fmt.Errorf("failed to revoke rate override: %w", err)
+	}
+	return nil
+}
+
+// IncrementProviderUsage counts one upstream request against a provider for the given month
+func (r *Repository) IncrementProviderUsage(ctx context.Context, provider string, period time.Time) error {
+	query := `
+		INSERT INTO fx_provider_usage (provider, period, requests)
+		VALUES ($1, $2, 1)
+		ON CONFLICT (provider, period) DO UPDATE SET requests = fx_provider_usage.requests + 1
+	`
+
+	if _, err := r.db.Exec(ctx, query, provider, period); err != nil {
+		return fmt.Errorf("failed to record provider usage: %w", err)
+	}
+	return nil
+}
+
+// GetProviderUsage returns the request count of every provider for the given month
+func (r *Repository) GetProviderUsage(ctx context.Context, period time.Time) (map[string]int64, error) {
+	query := `SELECT provider, requests FROM fx_provider_usage WHERE period = $1`
+
+	rows, err := r.db.Query(ctx, query, period)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get provider usage: %w", err)
+	}
+	defer rows.Close()
+
+	usage := make(map[string]int64)
+	for rows.Next() {
+		var provider string
+		var requests int64
+		if err := rows.Scan(&provider, &requests); err != nil {
+			return nil, fmt.Errorf("failed to scan provider usage: %w", err)
+		}
+		usage[provider] = requests
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating provider usage: %w", err)
+	}
+
+	return usage, nil
+}
@@ -0,0 +1,178 @@
+package fxrates
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMaxDeviation            = 0.10 // 10% move between consecutive snapshots
+	defaultMaxRateAge              = 2 * time.Hour
+	defaultQuarantineConfirmations = 3
+)
+
+// ErrMarketUnavailable is returned when trading on a pair is halted because its rate is stale or anomalous
+var ErrMarketUnavailable = errors.New("market unavailable")
+
+// QuarantinedRate is a fetched rate held back because it moved too far from the last accepted rate
+type QuarantinedRate struct {
+	Base          string    `json:"base"`
+	Quote         string    `json:"quote"`
+	PreviousRate  float64   `json:"previous_rate"`
+	SuspectRate   float64   `json:"suspect_rate"`
+	Deviation     float64   `json:"deviation"`
+	Provider      string    `json:"provider"`
+	DetectedAt    time.Time `json:"detected_at"`
+	Confirmations int       `json:"confirmations"` // consecutive fetches agreeing with the suspect rate
+}
+
+// quarantineStore holds quarantined rates keyed by "BASE/QUOTE"
+type quarantineStore struct {
+	mu      sync.RWMutex
+	entries map[string]*QuarantinedRate
+}
+
+func pairKey(base, quote string) string {
+	return base + "/" + quote
+}
+
+// maxDeviationFor returns the allowed relative move for a pair in either direction
+func (s *Service) maxDeviationFor(base, quote string) float64 {
+	if d, ok := s.pairMaxDeviation[pairKey(base, quote)]; ok && d > 0 {
+		return d
+	}
+	if d, ok := s.pairMaxDeviation[pairKey(quote, base)]; ok && d > 0 {
+		return d
+	}
+	return s.maxDeviation
+}
+
+// screenRates compares a fetched table with the cached one. Quotes that moved more than the
+// pair's max deviation are quarantined and keep their previous rate; a quarantine is lifted when
+// the feed returns to the previous level or confirms the new level on enough consecutive fetches.
+// It returns the rates to cache and the subset that passed screening.
+func (s *Service) screenRates(baseCurrency, provider string, fetched map[string]float64) (map[string]float64, map[string]float64) {
+	s.cache.mu.RLock()
+	var previous map[string]float64
+	if entry, exists := s.cache.entries[baseCurrency]; exists {
+		previous = entry.rates
+	}
+	s.cache.mu.RUnlock()
+
+	accepted := make(map[string]float64, len(fetched))
+	clean := make(map[string]float64, len(fetched))
+
+	s.quarantine.mu.Lock()
+	defer s.quarantine.mu.Unlock()
+
+	for quote, rate := range fetched {
+		key := pairKey(baseCurrency, quote)
+		maxDev := s.maxDeviationFor(baseCurrency, quote)
+
+		if q, exists := s.quarantine.entries[key]; exists {
+			switch {
+			case deviation(q.PreviousRate, rate) <= maxDev:
+				slog.Info("fx rate quarantine lifted, feed recovered", "pair", key, "rate", rate)
+				delete(s.quarantine.entries, key)
+			case deviation(q.SuspectRate, rate) <= maxDev && q.Confirmations+1 >= s.quarantineConfirmations:
+				slog.Warn("fx rate quarantine lifted, new level confirmed", "pair", key, "previous", q.PreviousRate, "rate", rate)
+				delete(s.quarantine.entries, key)
+			default:
+				if deviation(q.SuspectRate, rate) <= maxDev {
+					q.Confirmations++
+				} else {
+					q.Confirmations = 1
+				}
+				q.SuspectRate = rate
+				q.Deviation = deviation(q.PreviousRate, rate)
+				q.Provider = provider
+				accepted[quote] = q.PreviousRate
+				continue
+			}
+		} else if prev, ok := previous[quote]; ok && prev > 0 {
+			if dev := deviation(prev, rate); dev > maxDev {
+				slog.Warn("fx rate quarantined", "pair", key, "provider", provider, "previous", prev, "rate", rate, "deviation", dev)
+				s.quarantine.entries[key] = &QuarantinedRate{
+					Base:          baseCurrency,
+					Quote:         quote,
+					PreviousRate:  prev,
+					SuspectRate:   rate,
+					Deviation:     dev,
+					Provider:      provider,
+					DetectedAt:    time.Now(),
+					Confirmations: 1,
+				}
+				accepted[quote] = prev
+				continue
+			}
+		}
+
+		accepted[quote] = rate
+		clean[quote] = rate
+	}
+
+	return accepted, clean
+}
+
+// deviation returns the relative move from previous to current
+func deviation(previous, current float64) float64 {
+	return math.Abs(current/previous - 1)
+}
+
+// quarantinedQuotes lists the quotes of a base currently in quarantine
+func (s *Service) quarantinedQuotes(baseCurrency string) []string {
+	s.quarantine.mu.RLock()
+	defer s.quarantine.mu.RUnlock()
+
+	var quotes []string
+	for _, q := range s.quarantine.entries {
+		if q.Base == baseCurrency {
+			quotes = append(quotes, q.Quote)
+		}
+	}
+	sort.Strings(quotes)
+	return quotes
+}
+
+// GetQuarantined returns every quarantined rate
+func (s *Service) GetQuarantined() []QuarantinedRate {
+	s.quarantine.mu.RLock()
+	defer s.quarantine.mu.RUnlock()
+
+	quarantined := make([]QuarantinedRate, 0, len(s.quarantine.entries))
+	for _, q := range s.quarantine.entries {
+		quarantined = append(quarantined, *q)
+	}
+	sort.Slice(quarantined, func(i, j int) bool {
+		return pairKey(quarantined[i].Base, quarantined[i].Quote) < pairKey(quarantined[j].Base, quarantined[j].Quote)
+	})
+	return quarantined
+}
+
+// checkMarket is the circuit breaker for trading: it halts a pair whose rates are too old or
+// whose rate is quarantined in either direction. Admin-pinned rates are always tradable.
+func (s *Service) checkMarket(from, to string, rates *FXRatesResponse) error {
+	if _, pinned := rates.Overrides[to]; pinned {
+		return nil
+	}
+
+	if age := time.Duration(rates.AgeSeconds) * time.Second; age > s.maxRateAge {
+		return fmt.Errorf("%w: %s/%s rates are %s old", ErrMarketUnavailable, from, to, age)
+	}
+
+	s.quarantine.mu.RLock()
+	defer s.quarantine.mu.RUnlock()
+
+	if _, exists := s.quarantine.entries[pairKey(from, to)]; exists {
+		return fmt.Errorf("%w: %s/%s rate is quarantined as anomalous", ErrMarketUnavailable, from, to)
+	}
+	if _, exists := s.quarantine.entries[pairKey(to, from)]; exists {
+		return fmt.Errorf("%w: %s/%s rate is quarantined as anomalous", ErrMarketUnavailable, from, to)
+	}
+	return nil
+}
@@ -1,177 +1,490 @@
 package fxrates
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
-	"io"
-	"net/http"
+	"log/slog"
+	"math"
+	"strings"
 	"sync"
 	"time"
+
+	"github.com/Bwise1/interstellar/internal/auditlogs"
 )
 
 const (
-	baseURL       = "https://api.fastforex.io"
-	cacheDuration = 24 * time.Hour // Cache rates for 24 hours
+	cacheDuration    = time.Hour // Default TTL for a cached base
+	persistTimeout   = 5 * time.Second
+	maxHistoryPoints = 5000
+)
+
+var (
+	ErrNoProviders         = errors.New("no FX rate providers configured")
+	ErrUnsupportedCurrency = errors.New("unsupported currency")
 )
 
+// stablecoinCurrencies maps each supported stablecoin to the fiat currency it tracks
+var stablecoinCurrencies = map[string]string{
+	"cNGN": "NGN",
+	"cXAF": "XAF",
+	"USDx": "USD",
+	"EURx": "EUR",
+	"cGHS": "GHS",
+	"cKES": "KES",
+}
+
+// MapToRealCurrency maps stablecoin codes to their real currency equivalents
+func MapToRealCurrency(currency string) string {
+	if realCurrency, exists := stablecoinCurrencies[currency]; exists {
+		return realCurrency
+	}
+	return currency // Return as-is if not in mapping
+}
+
+// SupportsCurrency reports whether rates are served for a currency: one of the supported
+// stablecoins, the fiat currency it tracks, or the pivot
+func (s *Service) SupportsCurrency(currency string) bool {
+	if _, exists := stablecoinCurrencies[currency]; exists {
+		return true
+	}
+	if currency == s.pivot {
+		return true
+	}
+	for _, fiat := range stablecoinCurrencies {
+		if fiat == currency {
+			return true
+		}
+	}
+	return false
+}
+
+// AuditLogger defines the interface for recording rate override changes
+type AuditLogger interface {
+	LogRequest(ctx context.Context, req *auditlogs.CreateAuditLogRequest) error
+}
+
+// Config configures the FX rates service
+type Config struct {
+	Providers []RateProvider           // tried in priority order until one returns plausible rates
+	CacheTTL  time.Duration            // TTL for cached bases; defaults to cacheDuration
+	BaseTTLs  map[string]time.Duration // per-base TTL overrides, e.g. a shorter TTL for NGN
+
+	PivotCurrency string // currency used to triangulate pairs a base's own snapshot lacks; defaults to USD
+
+	// RefreshIntervals schedules background refreshes, keyed by pair ("USD/NGN") or base ("USD")
+	RefreshIntervals map[string]time.Duration
+
+	MaxDeviation            float64            // max relative move between snapshots before a rate is quarantined
+	PairMaxDeviation        map[string]float64 // per-pair overrides keyed "USD/NGN"
+	MaxRateAge              time.Duration      // trading on a base halts once its rates are older than this
+	QuarantineConfirmations int                // consecutive fetches agreeing on a suspect rate before it's accepted
+
+	RefreshCooldown time.Duration    // minimum time between manual refreshes of a base
+	ProviderQuotas  map[string]int64 // monthly upstream request limit per provider name
+	QuotaReserve    float64          // share of each quota kept back; a provider is skipped once it's reached
+}
+
 // Service handles FX rate operations
 type Service struct {
-	apiKey      string
-	cache       *RateCache
-	httpClient  *http.Client
+	repo             *Repository // optional; nil disables rate history and keeps overrides in memory only
+	auditLogger      AuditLogger
+	providers        []RateProvider
+	cache            *RateCache
+	fetches          fetchGroup // coalesces concurrent misses for the same base
+	refreshIntervals map[string]time.Duration
+	pivot            string
+
+	quarantine              *quarantineStore
+	overrides               *overrideStore
+	broker                  *rateBroker
+	maxDeviation            float64
+	pairMaxDeviation        map[string]float64
+	maxRateAge              time.Duration
+	quarantineConfirmations int
+	refreshCooldown         time.Duration
+	quota                   *quotaTracker
+	attempts                *attemptLog
 }
 
-// RateCache stores cached exchange rates
+// RateCache stores cached exchange rates keyed by base currency
 type RateCache struct {
-	mu          sync.RWMutex
+	mu       sync.RWMutex
+	entries  map[string]*cacheEntry
+	ttl      time.Duration
+	baseTTLs map[string]time.Duration
+}
+
+// cacheEntry is one base currency's rates with its own expiry
+type cacheEntry struct {
 	rates       map[string]float64
-	baseCurrency string
+	provider    string
 	lastUpdated time.Time
+	expiresAt   time.Time
 }
 
 // NewService creates a new FX rates service
-func NewService(apiKey string) *Service {
+func NewService(repo *Repository, auditLogger AuditLogger, cfg Config) *Service {
+	ttl := cfg.CacheTTL
+	if ttl <= 0 {
+		ttl = cacheDuration
+	}
+	maxDeviation := cfg.MaxDeviation
+	if maxDeviation <= 0 {
+		maxDeviation = defaultMaxDeviation
+	}
+	maxRateAge := cfg.MaxRateAge
+	if maxRateAge <= 0 {
+		maxRateAge = defaultMaxRateAge
+	}
+	confirmations := cfg.QuarantineConfirmations
+	if confirmations <= 0 {
+		confirmations = defaultQuarantineConfirmations
+	}
+	pivot := MapToRealCurrency(strings.TrimSpace(cfg.PivotCurrency))
+	if pivot == "" {
+		pivot = defaultPivotCurrency
+	}
+	refreshCooldown := cfg.RefreshCooldown
+	if refreshCooldown <= 0 {
+		refreshCooldown = defaultRefreshCooldown
+	}
+	quotaReserve := cfg.QuotaReserve
+	if quotaReserve <= 0 || quotaReserve >= 1 {
+		quotaReserve = defaultQuotaReserve
+	}
+
 	return &Service{
-		apiKey: apiKey,
-		cache: &RateCache{
-			rates: make(map[string]float64),
+		repo:                    repo,
+		auditLogger:             auditLogger,
+		providers:               cfg.Providers,
+		refreshIntervals:        cfg.RefreshIntervals,
+		pivot:                   pivot,
+		quarantine:              &quarantineStore{entries: make(map[string]*QuarantinedRate)},
+		overrides:               &overrideStore{entries: make(map[string]*RateOverride)},
+		broker:                  &rateBroker{subscribers: make(map[chan struct{}]struct{})},
+		maxDeviation:            maxDeviation,
+		pairMaxDeviation:        cfg.PairMaxDeviation,
+		maxRateAge:              maxRateAge,
+		quarantineConfirmations: confirmations,
+		refreshCooldown:         refreshCooldown,
+		attempts:                &attemptLog{entries: make(map[string]fetchAttempt)},
+		quota: &quotaTracker{
+			limits:  cfg.ProviderQuotas,
+			reserve: quotaReserve,
+			used:    make(map[string]int64),
 		},
-		httpClient: &http.Client{
-			Timeout: 10 * time.Second,
+		cache: &RateCache{
+			entries:  make(map[string]*cacheEntry),
+			ttl:      ttl,
+			baseTTLs: cfg.BaseTTLs,
 		},
 	}
 }
 
-// GetRates retrieves exchange rates for a base currency
-func (s *Service) GetRates(baseCurrency string) (*FXRatesResponse, error) {
+// GetRates retrieves exchange rates for a base currency; stablecoin codes resolve to their fiat.
+// Recently expired rates are served immediately while a refresh runs in the background; only a
+// cold or long-expired base blocks on the upstream call, which ctx can cancel. Either way a base
+// is fetched at most once per refresh cooldown.
+func (s *Service) GetRates(ctx context.Context, baseCurrency string) (*FXRatesResponse, error) {
+	baseCurrency = MapToRealCurrency(baseCurrency)
+	if !s.SupportsCurrency(baseCurrency) {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedCurrency, baseCurrency)
+	}
+
 	// Check cache first
-	if s.isCacheValid(baseCurrency) {
-		return s.getCachedRates(), nil
+	if rates, ok := s.getCachedRates(baseCurrency, false); ok {
+		return rates, nil
 	}
 
-	// Fetch fresh rates from API
-	rates, err := s.fetchRatesFromAPI(baseCurrency)
-	if err != nil {
+	if rates, ok := s.getCachedRates(baseCurrency, true); ok && rates.AgeSeconds < int64(2*s.ttlFor(baseCurrency)/time.Second) {
+		// The background refresh outlives the request, so it keeps ctx's values but not its cancellation
+		go func() {
+			if err := s.refreshOnDemand(context.WithoutCancel(ctx), baseCurrency); err != nil && !errors.Is(err, ErrRatesUnavailable) {
+				slog.Warn("background fx rate refresh failed", "base", baseCurrency, "error", err)
+			}
+		}()
+		return rates, nil
+	}
+
+	if err := s.refreshOnDemand(ctx, baseCurrency); err != nil {
 		// If API fails and we have stale cache, return it
-		if s.cache.baseCurrency == baseCurrency && len(s.cache.rates) > 0 {
-			return s.getCachedRates(), nil
+		if rates, ok := s.getCachedRates(baseCurrency, true); ok {
+			return rates, nil
 		}
 		return nil, err
 	}
 
-	// Update cache
-	s.updateCache(baseCurrency, rates)
-
-	return s.getCachedRates(), nil
+	rates, _ := s.getCachedRates(baseCurrency, true)
+	return rates, nil
 }
 
-// GetRate retrieves a specific exchange rate between two currencies
-func (s *Service) GetRate(from, to string) (float64, error) {
-	rates, err := s.GetRates(from)
+// GetRate retrieves a specific exchange rate between two currencies for trading, triangulating
+// through the pivot when needed. It fails with ErrMarketUnavailable when any leg is halted.
+func (s *Service) GetRate(ctx context.Context, from, to string) (float64, error) {
+	resolved, err := s.resolveRate(ctx, from, to)
 	if err != nil {
 		return 0, err
 	}
 
-	rate, exists := rates.Rates[to]
-	if !exists {
-		return 0, fmt.Errorf("rate not found for %s/%s", from, to)
+	if err := s.checkLegs(resolved); err != nil {
+		return 0, err
 	}
 
-	return rate, nil
+	return resolved.rate, nil
 }
 
 // Convert converts an amount from one currency to another
-func (s *Service) Convert(from, to string, amount float64) (*ConversionResponse, error) {
-	rate, err := s.GetRate(from, to)
+func (s *Service) Convert(ctx context.Context, from, to string, amount float64) (*ConversionResponse, error) {
+	resolved, err := s.resolveRate(ctx, from, to)
 	if err != nil {
 		return nil, err
 	}
 
-	result := amount * rate
+	if err := s.checkLegs(resolved); err != nil {
+		return nil, err
+	}
 
-	return &ConversionResponse{
-		From:        from,
-		To:          to,
-		Amount:      amount,
-		Result:      result,
-		Rate:        rate,
-		LastUpdated: s.cache.lastUpdated,
-	}, nil
-}
+	conversion := &ConversionResponse{
+		From:   from,
+		To:     to,
+		Amount: amount,
+		Result: amount * resolved.rate,
+		Rate:   resolved.rate,
+		Source: resolved.source,
+		Path:   resolved.path,
+	}
+	if table := resolved.table; table != nil {
+		conversion.Provider = table.Provider
+		conversion.LastUpdated = table.LastUpdated
+		conversion.AgeSeconds = table.AgeSeconds
+		conversion.Stale = table.Stale
+	}
+	if inverse, deviation, ok := s.inverseCheck(from, to, resolved); ok {
+		conversion.InverseRate = &inverse
+		conversion.InverseDeviation = &deviation
+	}
 
-// fetchRatesFromAPI fetches rates from FastForex API
-func (s *Service) fetchRatesFromAPI(baseCurrency string) (map[string]float64, error) {
-	url := fmt.Sprintf("%s/fetch-all?from=%s&api_key=%s", baseURL, baseCurrency, s.apiKey)
+	return conversion, nil
+}
 
-	resp, err := s.httpClient.Get(url)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch rates from %s: %w", url, err)
+// checkLegs runs the circuit breaker on every provider leg of a resolved rate
+func (s *Service) checkLegs(resolved *resolvedRate) error {
+	for _, leg := range resolved.legs {
+		if err := s.checkMarket(leg.base, leg.quote, leg.table); err != nil {
+			return err
+		}
 	}
-	defer resp.Body.Close()
+	return nil
+}
 
-	if resp.StatusCode != http.StatusOK {
-		// Read error body for more details
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body))
+// fetchRates tries each provider in priority order, failing over on errors, implausible data or
+// a nearly spent quota. When every provider is skipped callers fall back to cached rates. Once
+// ctx is done no further provider is tried.
+func (s *Service) fetchRates(ctx context.Context, baseCurrency string) (map[string]float64, string, error) {
+	if len(s.providers) == 0 {
+		return nil, "", ErrNoProviders
 	}
 
-	var apiResp FastForexAPIResponse
-	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
-	}
+	var failures []string
+	for _, provider := range s.providers {
+		if err := ctx.Err(); err != nil {
+			return nil, "", err
+		}
 
-	if len(apiResp.Results) == 0 {
-		return nil, fmt.Errorf("API returned empty results")
+		if err := s.reserveFetch(ctx, provider.Name()); err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", provider.Name(), err))
+			continue
+		}
+
+		rates, err := provider.FetchRates(ctx, baseCurrency)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, "", ctxErr
+		}
+		if err == nil {
+			err = validateRates(baseCurrency, rates)
+		}
+		if err != nil {
+			slog.Warn("fx rate provider failed", "provider", provider.Name(), "base", baseCurrency, "error", err)
+			failures = append(failures, fmt.Sprintf("%s: %v", provider.Name(), err))
+			continue
+		}
+
+		return rates, provider.Name(), nil
 	}
 
-	return apiResp.Results, nil
+	return nil, "", fmt.Errorf("all FX rate providers failed: %s", strings.Join(failures, "; "))
 }
 
-// isCacheValid checks if the cache is still valid
-func (s *Service) isCacheValid(baseCurrency string) bool {
-	s.cache.mu.RLock()
-	defer s.cache.mu.RUnlock()
+// validateRates rejects rate tables no real feed would produce
+func validateRates(baseCurrency string, rates map[string]float64) error {
+	if len(rates) == 0 {
+		return fmt.Errorf("empty results")
+	}
 
-	if s.cache.baseCurrency != baseCurrency {
-		return false
+	for currency, rate := range rates {
+		if math.IsNaN(rate) || math.IsInf(rate, 0) || rate <= 0 {
+			return fmt.Errorf("implausible rate %v for %s/%s", rate, baseCurrency, currency)
+		}
 	}
 
-	if len(s.cache.rates) == 0 {
-		return false
+	if self, exists := rates[baseCurrency]; exists && math.Abs(self-1) > 1e-6 {
+		return fmt.Errorf("implausible self rate %v for %s", self, baseCurrency)
 	}
 
-	return time.Since(s.cache.lastUpdated) < cacheDuration
+	return nil
 }
 
-// updateCache updates the cache with new rates
-func (s *Service) updateCache(baseCurrency string, rates map[string]float64) {
+// updateCache stores fresh rates for a base with that base's TTL
+func (s *Service) updateCache(baseCurrency, provider string, rates map[string]float64, fetchedAt time.Time) {
 	s.cache.mu.Lock()
 	defer s.cache.mu.Unlock()
 
-	s.cache.baseCurrency = baseCurrency
-	s.cache.rates = rates
-	s.cache.lastUpdated = time.Now()
+	ttl := s.ttlFor(baseCurrency)
+	s.cache.entries[baseCurrency] = &cacheEntry{
+		rates:       rates,
+		provider:    provider,
+		lastUpdated: fetchedAt,
+		expiresAt:   fetchedAt.Add(ttl),
+	}
 }
 
-// getCachedRates returns the cached rates
-func (s *Service) getCachedRates() *FXRatesResponse {
+// getCachedRates returns the cached rates for a base; expired entries are only returned when allowStale is set
+func (s *Service) getCachedRates(baseCurrency string, allowStale bool) (*FXRatesResponse, bool) {
 	s.cache.mu.RLock()
 	defer s.cache.mu.RUnlock()
 
+	entry, exists := s.cache.entries[baseCurrency]
+	if !exists || len(entry.rates) == 0 {
+		return nil, false
+	}
+	now := time.Now()
+	stale := !now.Before(entry.expiresAt)
+	if !allowStale && stale {
+		return nil, false
+	}
+
+	rates, overrides := s.applyOverrides(baseCurrency, entry.rates)
+
 	return &FXRatesResponse{
-		BaseCurrency: s.cache.baseCurrency,
-		Rates:        s.cache.rates,
-		LastUpdated:  s.cache.lastUpdated,
+		BaseCurrency: baseCurrency,
+		Rates:        rates,
+		Overrides:    overrides,
+		Provider:     entry.provider,
+		LastUpdated:  entry.lastUpdated,
+		ExpiresAt:    entry.expiresAt,
+		AgeSeconds:   int64(now.Sub(entry.lastUpdated) / time.Second),
+		Stale:        stale,
+		Quarantined:  s.quarantinedQuotes(baseCurrency),
+	}, true
+}
+
+// ttlFor returns the cache TTL for a base
+func (s *Service) ttlFor(baseCurrency string) time.Duration {
+	if override, ok := s.cache.baseTTLs[baseCurrency]; ok && override > 0 {
+		return override
+	}
+	return s.cache.ttl
+}
+
+// RefreshCache forces a cache refresh. Concurrent refreshes of the same base share one upstream
+// call, which is cancelled once every caller waiting on it has given up.
+func (s *Service) RefreshCache(ctx context.Context, baseCurrency string) error {
+	return s.fetches.Do(ctx, baseCurrency, func(ctx context.Context) error {
+		rates, provider, err := s.fetchRates(ctx, baseCurrency)
+		// A fetch every caller abandoned says nothing about the base, so it doesn't start a cooldown
+		if !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) {
+			s.recordAttempt(baseCurrency, err)
+		}
+		if err != nil {
+			return err
+		}
+
+		// Quarantined quotes keep their previous rate in the cache and are left out of history
+		accepted, clean := s.screenRates(baseCurrency, provider, rates)
+
+		fetchedAt := time.Now()
+		s.updateCache(baseCurrency, provider, accepted, fetchedAt)
+		s.notifySubscribers()
+		s.persistSnapshot(ctx, &RateSnapshot{
+			BaseCurrency: baseCurrency,
+			Rates:        clean,
+			Provider:     provider,
+			FetchedAt:    fetchedAt,
+		})
+		return nil
+	})
+}
+
+// persistSnapshot records a fetched rate table in the history table. Failures are logged, not
+// returned, so a database outage never blocks serving fresh rates. The write is not cancelled
+// with ctx: once fetched, a snapshot is worth keeping.
+func (s *Service) persistSnapshot(ctx context.Context, snapshot *RateSnapshot) {
+	if s.repo == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), persistTimeout)
+	defer cancel()
+
+	if err := s.repo.SaveSnapshot(ctx, snapshot); err != nil {
+		slog.Error("failed to persist fx rate snapshot", "base", snapshot.BaseCurrency, "error", err)
 	}
 }
 
-// RefreshCache forces a cache refresh
-func (s *Service) RefreshCache(baseCurrency string) error {
-	rates, err := s.fetchRatesFromAPI(baseCurrency)
+// WarmCache loads the latest stored snapshot of every base into the cache. Entries keep their
+// original fetch time, so expired ones are only served as a stale fallback until refreshed.
+func (s *Service) WarmCache(ctx context.Context) error {
+	if s.repo == nil {
+		return nil
+	}
+
+	snapshots, err := s.repo.GetLatestSnapshots(ctx)
 	if err != nil {
 		return err
 	}
 
-	s.updateCache(baseCurrency, rates)
+	for _, snapshot := range snapshots {
+		s.updateCache(snapshot.BaseCurrency, snapshot.Provider, snapshot.Rates, snapshot.FetchedAt)
+	}
+
+	slog.Info("fx rate cache warmed", "bases", len(snapshots))
 	return nil
 }
+
+// GetHistory returns the stored rate series for a pair. When only the opposite direction was
+// stored (e.g. NGN/USD from USD-based fetches) the series is inverted.
+func (s *Service) GetHistory(ctx context.Context, base, quote string, from, to time.Time) (*RateHistoryResponse, error) {
+	if s.repo == nil {
+		return nil, fmt.Errorf("rate history is not enabled")
+	}
+
+	base = MapToRealCurrency(base)
+	quote = MapToRealCurrency(quote)
+
+	history := &RateHistoryResponse{
+		Base:  base,
+		Quote: quote,
+		From:  from,
+		To:    to,
+	}
+
+	points, err := s.repo.GetHistory(ctx, base, quote, from, to, maxHistoryPoints)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(points) == 0 {
+		points, err = s.repo.GetHistory(ctx, quote, base, from, to, maxHistoryPoints)
+		if err != nil {
+			return nil, err
+		}
+		for i := range points {
+			points[i].Rate = 1 / points[i].Rate
+		}
+		history.Inverted = len(points) > 0
+	}
+
+	history.Points = points
+	return history, nil
+}
@@ -0,0 +1,222 @@
+package fxrates
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newStubServer serves the stub FastForex API and counts the requests it gets
+func newStubServer(t *testing.T, handler http.Handler) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return server, &hits
+}
+
+// newStaticFile writes a USD-based rate table for a StaticProvider
+func newStaticFile(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "rates.json")
+	if err := os.WriteFile(path, []byte(`{"base":"USD","rates":{"USD":1,"NGN":1500,"EUR":0.9}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func newTestService(providers ...RateProvider) *Service {
+	return NewService(nil, nil, Config{Providers: providers})
+}
+
+func TestFailoverOnProviderError(t *testing.T) {
+	server, hits := newStubServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "upstream down", http.StatusBadGateway)
+	}))
+	s := newTestService(NewFastForexProvider("key", server.URL), NewStaticProvider(newStaticFile(t)))
+
+	rates, err := s.GetRates(context.Background(), "USD")
+	if err != nil {
+		t.Fatalf("GetRates: %v", err)
+	}
+	if hits.Load() != 1 {
+		t.Errorf("primary provider called %d times, want 1", hits.Load())
+	}
+	if rates.Provider != "static" {
+		t.Errorf("provider = %q, want static", rates.Provider)
+	}
+	if rates.Rates["NGN"] != 1500 {
+		t.Errorf("NGN rate = %v, want 1500", rates.Rates["NGN"])
+	}
+}
+
+func TestFailoverOnUnsupportedBase(t *testing.T) {
+	// The stub can't derive a base missing from its table and answers 400
+	server, _ := newStubServer(t, NewStubHandler(map[string]float64{"USD": 1, "NGN": 1500}))
+	s := newTestService(NewFastForexProvider("key", server.URL), NewStaticProvider(newStaticFile(t)))
+
+	rates, err := s.GetRates(context.Background(), "EUR")
+	if err != nil {
+		t.Fatalf("GetRates: %v", err)
+	}
+	if rates.Provider != "static" {
+		t.Errorf("provider = %q, want static", rates.Provider)
+	}
+}
+
+func TestFailoverOnImplausibleData(t *testing.T) {
+	for name, usdRates := range map[string]map[string]float64{
+		"negative rate":  {"USD": 1, "NGN": -1500},
+		"zero rate":      {"USD": 1, "NGN": 0},
+		"bad self rate":  {"USD": 2, "NGN": 1500},
+		"empty response": {},
+	} {
+		t.Run(name, func(t *testing.T) {
+			server, hits := newStubServer(t, NewStubHandler(usdRates))
+			s := newTestService(NewFastForexProvider("key", server.URL), NewStaticProvider(newStaticFile(t)))
+
+			rates, err := s.GetRates(context.Background(), "USD")
+			if err != nil {
+				t.Fatalf("GetRates: %v", err)
+			}
+			if hits.Load() != 1 {
+				t.Errorf("primary provider called %d times, want 1", hits.Load())
+			}
+			if rates.Provider != "static" {
+				t.Errorf("provider = %q, want static", rates.Provider)
+			}
+		})
+	}
+}
+
+func TestPrimaryProviderRecorded(t *testing.T) {
+	server, _ := newStubServer(t, NewStubHandler(map[string]float64{"USD": 1, "NGN": 1600, "EUR": 0.8}))
+	s := newTestService(NewFastForexProvider("key", server.URL), NewStaticProvider(newStaticFile(t)))
+
+	rates, err := s.GetRates(context.Background(), "EUR")
+	if err != nil {
+		t.Fatalf("GetRates: %v", err)
+	}
+	if rates.Provider != "fastforex" {
+		t.Errorf("provider = %q, want fastforex", rates.Provider)
+	}
+	if got, want := rates.Rates["NGN"], 1600/0.8; got != want {
+		t.Errorf("EUR/NGN = %v, want %v", got, want)
+	}
+}
+
+func TestAllProvidersFail(t *testing.T) {
+	server, _ := newStubServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "upstream down", http.StatusInternalServerError)
+	}))
+	s := newTestService(NewFastForexProvider("key", server.URL), NewStaticProvider(filepath.Join(t.TempDir(), "missing.json")))
+
+	if _, err := s.GetRates(context.Background(), "USD"); err == nil {
+		t.Fatal("expected an error when every provider fails")
+	}
+}
+
+func TestUnsupportedBaseNotFetched(t *testing.T) {
+	server, hits := newStubServer(t, NewStubHandler(map[string]float64{"USD": 1, "NGN": 1500}))
+	s := newTestService(NewFastForexProvider("key", server.URL))
+
+	for _, base := range []string{"XYZ", "usd", ""} {
+		if _, err := s.GetRates(context.Background(), base); !errors.Is(err, ErrUnsupportedCurrency) {
+			t.Errorf("GetRates(%q) error = %v, want ErrUnsupportedCurrency", base, err)
+		}
+	}
+	if _, err := s.Convert(context.Background(), "USD", "XYZ", 10); !errors.Is(err, ErrUnsupportedCurrency) {
+		t.Errorf("Convert error = %v, want ErrUnsupportedCurrency", err)
+	}
+	if _, err := s.ConvertAt(context.Background(), "XYZ", "USD", 10, time.Now().Add(-time.Hour)); !errors.Is(err, ErrUnsupportedCurrency) {
+		t.Errorf("ConvertAt error = %v, want ErrUnsupportedCurrency", err)
+	}
+	if hits.Load() != 0 {
+		t.Errorf("provider called %d times for unsupported currencies, want 0", hits.Load())
+	}
+}
+
+func TestFailedBaseCachedForCooldown(t *testing.T) {
+	server, hits := newStubServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "upstream down", http.StatusBadGateway)
+	}))
+	s := newTestService(NewFastForexProvider("key", server.URL))
+
+	if _, err := s.GetRates(context.Background(), "NGN"); err == nil {
+		t.Fatal("expected the first fetch to fail")
+	}
+	for i := 0; i < 5; i++ {
+		if _, err := s.GetRates(context.Background(), "cNGN"); !errors.Is(err, ErrRatesUnavailable) {
+			t.Fatalf("GetRates error = %v, want ErrRatesUnavailable", err)
+		}
+	}
+	if _, err := s.ManualRefresh(context.Background(), "NGN"); !errors.Is(err, ErrRefreshCooldown) {
+		t.Errorf("ManualRefresh error = %v, want ErrRefreshCooldown", err)
+	}
+	if hits.Load() != 1 {
+		t.Errorf("provider called %d times, want 1", hits.Load())
+	}
+
+	// Other bases have their own cooldown
+	if _, err := s.GetRates(context.Background(), "EUR"); errors.Is(err, ErrRatesUnavailable) {
+		t.Errorf("EUR blocked by NGN's cooldown: %v", err)
+	}
+	if hits.Load() != 2 {
+		t.Errorf("provider called %d times, want 2", hits.Load())
+	}
+}
+
+func TestCrossRateUsesPivotOnly(t *testing.T) {
+	var mu sync.Mutex
+	var bases []string
+	stub := NewStubHandler(map[string]float64{"USD": 1, "NGN": 1500, "EUR": 0.8, "KES": 130})
+	server, _ := newStubServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		bases = append(bases, r.URL.Query().Get("from"))
+		mu.Unlock()
+		stub.ServeHTTP(w, r)
+	}))
+	s := newTestService(NewFastForexProvider("key", server.URL))
+
+	for _, pair := range [][2]string{{"EUR", "NGN"}, {"KES", "EUR"}, {"cNGN", "USDx"}} {
+		conversion, err := s.Convert(context.Background(), pair[0], pair[1], 1)
+		if err != nil {
+			t.Fatalf("Convert %s/%s: %v", pair[0], pair[1], err)
+		}
+		if conversion.Provider != "fastforex" {
+			t.Errorf("%s/%s provider = %q, want fastforex", pair[0], pair[1], conversion.Provider)
+		}
+	}
+	if got, want := fmt.Sprint(bases), "[USD]"; got != want {
+		t.Errorf("fetched bases %s, want %s", got, want)
+	}
+}
+
+func TestCachedDirectBaseUsedWhenPivotLacksPair(t *testing.T) {
+	server, _ := newStubServer(t, NewStubHandler(map[string]float64{"USD": 1, "EUR": 0.8}))
+	s := newTestService(NewFastForexProvider("key", server.URL))
+
+	if _, err := s.Convert(context.Background(), "EUR", "GHS", 1); err == nil {
+		t.Fatal("expected no rate for EUR/GHS without a cached EUR snapshot")
+	}
+
+	s.updateCache("EUR", "static", map[string]float64{"EUR": 1, "GHS": 14}, time.Now())
+	conversion, err := s.Convert(context.Background(), "EUR", "GHS", 2)
+	if err != nil {
+		t.Fatalf("Convert: %v", err)
+	}
+	if conversion.Result != 28 || len(conversion.Path) != 2 {
+		t.Errorf("result = %v via %v, want 28 direct", conversion.Result, conversion.Path)
+	}
+}
@@ -0,0 +1,118 @@
+package fxrates
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	maxStreamPairs     = 20
+	streamHeartbeat    = 15 * time.Second // idle streams send a comment this often so proxies keep them open
+	streamWriteTimeout = 10 * time.Second // a client that can't take a write this fast is disconnected
+)
+
+// rateBroker wakes streaming clients when rates change. Each subscriber has a one-slot signal
+// channel, so notifications coalesce and the refresher never waits on a slow client: a client
+// that falls behind simply reads the latest rates when it catches up.
+type rateBroker struct {
+	mu          sync.Mutex
+	subscribers map[chan struct{}]struct{}
+}
+
+// Subscribe registers for rate change notifications; call the returned func to unsubscribe
+func (s *Service) Subscribe() (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+
+	s.broker.mu.Lock()
+	s.broker.subscribers[ch] = struct{}{}
+	s.broker.mu.Unlock()
+
+	return ch, func() {
+		s.broker.mu.Lock()
+		delete(s.broker.subscribers, ch)
+		s.broker.mu.Unlock()
+	}
+}
+
+// notifySubscribers signals every subscriber without blocking
+func (s *Service) notifySubscribers() {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+
+	for ch := range s.broker.subscribers {
+		select {
+		case ch <- struct{}{}:
+		default: // a signal is already pending
+		}
+	}
+}
+
+// ParsePairs parses a comma-separated "USD/NGN,cNGN/EURx" subscription list
+func ParsePairs(value string) ([][2]string, error) {
+	var pairs [][2]string
+	seen := make(map[string]bool)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		base, quote, found := strings.Cut(entry, "/")
+		if !found || base == "" || quote == "" {
+			return nil, fmt.Errorf("pair %q must look like USD/NGN", entry)
+		}
+		if seen[entry] {
+			continue
+		}
+		seen[entry] = true
+		pairs = append(pairs, [2]string{base, quote})
+	}
+
+	if len(pairs) == 0 {
+		return nil, errors.New("at least one pair is required")
+	}
+	if len(pairs) > maxStreamPairs {
+		return nil, fmt.Errorf("at most %d pairs can be subscribed", maxStreamPairs)
+	}
+	return pairs, nil
+}
+
+// PairUpdate returns the current state of a pair for streaming. A halted market is reported
+// rather than returned as an error.
+func (s *Service) PairUpdate(ctx context.Context, base, quote string) (*RateUpdate, error) {
+	resolved, err := s.resolveRate(ctx, base, quote)
+	if err != nil {
+		return nil, err
+	}
+
+	update := &RateUpdate{
+		Pair:   base + "/" + quote,
+		Rate:   resolved.rate,
+		Path:   resolved.path,
+		Source: resolved.source,
+	}
+	if table := resolved.table; table != nil {
+		update.Provider = table.Provider
+		update.LastUpdated = table.LastUpdated
+		update.Stale = table.Stale
+	}
+	if err := s.checkLegs(resolved); err != nil {
+		update.Halted = true
+		update.HaltReason = err.Error()
+	}
+	return update, nil
+}
+
+// Changed reports whether an update differs from the one previously sent for the pair
+func (u *RateUpdate) Changed(previous *RateUpdate) bool {
+	return previous == nil ||
+		u.Rate != previous.Rate ||
+		u.Source != previous.Source ||
+		u.Stale != previous.Stale ||
+		u.Halted != previous.Halted ||
+		!u.LastUpdated.Equal(previous.LastUpdated)
+}
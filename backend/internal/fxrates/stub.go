@@ -0,0 +1,34 @@
+package fxrates
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// NewStubHandler returns an http.Handler that mimics FastForex's /fetch-all endpoint from a fixed
+// USD-based rate table. Serve it with httptest.NewServer (or locally) and point
+// NewFastForexProvider at its URL to exercise the service without the real API.
+func NewStubHandler(usdRates map[string]float64) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/fetch-all", func(w http.ResponseWriter, r *http.Request) {
+		base := r.URL.Query().Get("from")
+		if base == "" {
+			base = "USD"
+		}
+
+		rates, err := crossRates("USD", usdRates, base)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(FastForexAPIResponse{
+			Base:    base,
+			Results: rates,
+			Updated: time.Now().UTC().Format("2006-01-02 15:04:05"),
+		})
+	})
+	return mux
+}
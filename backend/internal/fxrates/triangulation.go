@@ -0,0 +1,162 @@
+package fxrates
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+)
+
+const defaultPivotCurrency = "USD"
+
+// resolvedRate is a rate for a pair together with how it was derived
+type resolvedRate struct {
+	rate   float64
+	path   []string // currencies the rate passes through, e.g. [XAF USD KES]
+	source string
+	legs   []rateLeg        // provider legs the circuit breaker checks
+	table  *FXRatesResponse // snapshot the rate was read from; nil for a direct override
+}
+
+// rateLeg is one hop of a resolved rate, read from a base's snapshot
+type rateLeg struct {
+	base, quote string
+	table       *FXRatesResponse
+}
+
+// resolveRate finds the rate for from/to. It prefers a pinned override, then triangulates through
+// the pivot using only the pivot's snapshot, and falls back to the from base's own snapshot only
+// when that is already cached.
+func (s *Service) resolveRate(ctx context.Context, from, to string) (*resolvedRate, error) {
+	from = MapToRealCurrency(from)
+	to = MapToRealCurrency(to)
+	for _, currency := range []string{from, to} {
+		if !s.SupportsCurrency(currency) {
+			return nil, fmt.Errorf("%w: %s", ErrUnsupportedCurrency, currency)
+		}
+	}
+
+	if from == to {
+		// A stablecoin and its fiat (or a currency and itself) always trade at par
+		return &resolvedRate{
+			rate:   1,
+			path:   []string{from},
+			source: RateSourceProvider,
+			table:  &FXRatesResponse{BaseCurrency: from, Provider: "par", LastUpdated: time.Now()},
+		}, nil
+	}
+
+	if override, rate, ok := s.activeOverride(from, to); ok {
+		return &resolvedRate{
+			rate:   rate,
+			path:   []string{from, to},
+			source: RateSourceOverride,
+			table: &FXRatesResponse{
+				BaseCurrency: from,
+				Provider:     RateSourceOverride,
+				LastUpdated:  override.CreatedAt,
+				ExpiresAt:    override.ExpiresAt,
+				AgeSeconds:   int64(time.Since(override.CreatedAt) / time.Second),
+			},
+		}, nil
+	}
+
+	// Cross rates come from the pivot's snapshot, so a request for an arbitrary pair never makes
+	// the service fetch that pair's base
+	pivotRates, err := s.GetRates(ctx, s.pivot)
+	var fromRate, toRate float64
+	if err == nil {
+		var fromOK, toOK bool
+		fromRate, fromOK = pivotRate(pivotRates, from, s.pivot)
+		toRate, toOK = pivotRate(pivotRates, to, s.pivot)
+		if !fromOK || !toOK {
+			err = fmt.Errorf("rate not found for %s/%s", from, to)
+		}
+	}
+	if err != nil {
+		// Fall back to the from base's own snapshot, but only if something else already fetched it
+		if rates, cached := s.getCachedRates(from, true); cached {
+			if rate, exists := rates.Rates[to]; exists {
+				return &resolvedRate{
+					rate:   rate,
+					path:   []string{from, to},
+					source: sourceFor(rates, to),
+					legs:   []rateLeg{{from, to, rates}},
+					table:  rates,
+				}, nil
+			}
+		}
+		return nil, err
+	}
+
+	resolved := &resolvedRate{
+		rate:   toRate / fromRate,
+		source: RateSourceProvider,
+		table:  pivotRates,
+	}
+	switch s.pivot {
+	case from:
+		resolved.path = []string{from, to}
+		resolved.source = sourceFor(pivotRates, to)
+		resolved.legs = []rateLeg{{s.pivot, to, pivotRates}}
+	case to:
+		resolved.path = []string{from, to}
+		resolved.source = sourceFor(pivotRates, from)
+		resolved.legs = []rateLeg{{s.pivot, from, pivotRates}}
+	default:
+		resolved.path = []string{from, s.pivot, to}
+		if sourceFor(pivotRates, from) == RateSourceOverride || sourceFor(pivotRates, to) == RateSourceOverride {
+			resolved.source = RateSourceOverride
+		}
+		resolved.legs = []rateLeg{{s.pivot, from, pivotRates}, {s.pivot, to, pivotRates}}
+	}
+	return resolved, nil
+}
+
+// inverseCheck derives to/from independently of the resolved rate, from cached data only, and
+// reports how far rate*inverse strays from 1. ok is false when no independent inverse is cached.
+func (s *Service) inverseCheck(from, to string, resolved *resolvedRate) (inverse, deviation float64, ok bool) {
+	from = MapToRealCurrency(from)
+	to = MapToRealCurrency(to)
+	if from == to {
+		return 1, 0, true
+	}
+
+	// A direct rate is checked against the pivot cross; a triangulated one against the to base's own table
+	if len(resolved.path) == 2 && resolved.table != nil && resolved.table.BaseCurrency != s.pivot {
+		pivotRates, cached := s.getCachedRates(s.pivot, true)
+		if cached {
+			fromRate, fromOK := pivotRate(pivotRates, from, s.pivot)
+			toRate, toOK := pivotRate(pivotRates, to, s.pivot)
+			if fromOK && toOK {
+				inverse = fromRate / toRate
+				return inverse, math.Abs(resolved.rate*inverse - 1), true
+			}
+		}
+	}
+
+	if toRates, cached := s.getCachedRates(to, true); cached {
+		if rate, exists := toRates.Rates[from]; exists {
+			return rate, math.Abs(resolved.rate*rate - 1), true
+		}
+	}
+
+	return 0, 0, false
+}
+
+// pivotRate returns pivot->currency from the pivot's snapshot
+func pivotRate(pivotRates *FXRatesResponse, currency, pivot string) (float64, bool) {
+	if currency == pivot {
+		return 1, true
+	}
+	rate, exists := pivotRates.Rates[currency]
+	return rate, exists && rate > 0
+}
+
+// sourceFor reports whether a quote in a snapshot is provider data or an admin-pinned rate
+func sourceFor(rates *FXRatesResponse, quote string) string {
+	if _, pinned := rates.Overrides[quote]; pinned {
+		return RateSourceOverride
+	}
+	return RateSourceProvider
+}
@@ -0,0 +1,81 @@
+package marketdata
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Bwise1/interstellar/internal/utils"
+	"github.com/Bwise1/interstellar/pkg/response"
+	"github.com/go-chi/chi/v5"
+)
+
+// Handler handles HTTP requests for market data
+type Handler struct {
+	service *Service
+}
+
+// NewHandler creates a new market data handler
+func NewHandler(service *Service) *Handler {
+	return &Handler{
+		service: service,
+	}
+}
+
+// GET /api/markets/{pair}/candles?interval=1h&from=&to=
+func (h *Handler) GetCandles(w http.ResponseWriter, r *http.Request) {
+	interval := Interval(r.URL.Query().Get("interval"))
+	if interval == "" {
+		interval = Interval1h
+	}
+
+	var from, to time.Time
+	if toStr := r.URL.Query().Get("to"); toStr != "" {
+		parsed, err := utils.ParseTimeParam(toStr)
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, "Invalid to timestamp")
+			return
+		}
+		to = parsed
+	}
+
+	if fromStr := r.URL.Query().Get("from"); fromStr != "" {
+		parsed, err := utils.ParseTimeParam(fromStr)
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, "Invalid from timestamp")
+			return
+		}
+		from = parsed
+	}
+
+	candles, err := h.service.GetCandles(r.Context(), chi.URLParam(r, "pair"), interval, from, to)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	response.Success(w, http.StatusOK, "Candles retrieved successfully", candles)
+}
+
+// GET /api/markets/{pair}/stats
+func (h *Handler) GetStats(w http.ResponseWriter, r *http.Request) {
+	stats, err := h.service.GetStats(r.Context(), chi.URLParam(r, "pair"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	response.Success(w, http.StatusOK, "Market statistics retrieved successfully", stats)
+}
+
+// writeError maps market data errors to HTTP responses
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidPair), errors.Is(err, ErrInvalidInterval), errors.Is(err, ErrInvalidRange):
+		response.Error(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, ErrNoMarketData):
+		response.Error(w, http.StatusNotFound, err.Error())
+	default:
+		response.Error(w, http.StatusInternalServerError, err.Error())
+	}
+}
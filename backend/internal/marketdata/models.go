@@ -0,0 +1,62 @@
+package marketdata
+
+import "time"
+
+// Interval is a candle width
+type Interval string
+
+const (
+	Interval1m Interval = "1m"
+	Interval1h Interval = "1h"
+	Interval1d Interval = "1d"
+)
+
+// intervals maps each interval to its width and the date_trunc unit that buckets it
+var intervals = map[Interval]struct {
+	width time.Duration
+	unit  string
+}{
+	Interval1m: {time.Minute, "minute"},
+	Interval1h: {time.Hour, "hour"},
+	Interval1d: {24 * time.Hour, "day"},
+}
+
+// Candle summarises a pair's prices over one interval. Prices are in quote per unit of base;
+// Volume is the base amount executed through swaps and order book trades, which provider
+// snapshots don't contribute to.
+type Candle struct {
+	Time       time.Time `json:"time"` // start of the interval
+	Open       float64   `json:"open"`
+	High       float64   `json:"high"`
+	Low        float64   `json:"low"`
+	Close      float64   `json:"close"`
+	Volume     float64   `json:"volume"`
+	Executions int       `json:"executions"` // swaps and trades in the interval
+	Samples    int       `json:"samples"`    // every price observation, snapshots included
+}
+
+// CandlesResponse represents the candles for a pair over a time range. Intervals without any
+// observation are omitted.
+type CandlesResponse struct {
+	Pair     string    `json:"pair"`
+	Interval Interval  `json:"interval"`
+	From     time.Time `json:"from"`
+	To       time.Time `json:"to"`
+	Candles  []Candle  `json:"candles"`
+}
+
+// Stats summarises a pair over the trailing 24 hours
+type Stats struct {
+	Pair          string    `json:"pair"`
+	Open          float64   `json:"open"` // first price in the window
+	Last          float64   `json:"last"`
+	High          float64   `json:"high"`
+	Low           float64   `json:"low"`
+	Change        float64   `json:"change"`
+	ChangePercent float64   `json:"change_percent"`
+	Volume        float64   `json:"volume"`
+	Executions    int       `json:"executions"`
+	Samples       int       `json:"samples"`
+	From          time.Time `json:"from"`
+	To            time.Time `json:"to"`
+}
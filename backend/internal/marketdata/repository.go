@@ -0,0 +1,122 @@
+package marketdata
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// pointsQuery lists every price observation of a pair in [$5, $6), in quote per base: provider
+// snapshots in either direction ($1/$2 are the fiat codes they are stored under), executed swaps
+// in either direction ($3/$4 as traded) and order book trades on pair $7. Only executions carry
+// volume, in the base currency.
+const pointsQuery = `
+	SELECT fetched_at AS at, rate AS price, 0::numeric AS volume, 0 AS executions
+	FROM fx_rates
+	WHERE base_currency = $1 AND quote_currency = $2 AND fetched_at >= $5 AND fetched_at < $6
+	UNION ALL
+	SELECT fetched_at, 1 / rate, 0, 0
+	FROM fx_rates
+	WHERE base_currency = $2 AND quote_currency = $1 AND fetched_at >= $5 AND fetched_at < $6
+	UNION ALL
+	SELECT created_at, exchange_rate, from_amount, 1
+	FROM transactions
+	WHERE transaction_type = 'SWAP' AND status = 'COMPLETED' AND from_currency = $3 AND to_currency = $4
+	  AND exchange_rate > 0 AND created_at >= $5 AND created_at < $6
+	UNION ALL
+	SELECT created_at, 1 / exchange_rate, to_amount, 1
+	FROM transactions
+	WHERE transaction_type = 'SWAP' AND status = 'COMPLETED' AND from_currency = $4 AND to_currency = $3
+	  AND exchange_rate > 0 AND created_at >= $5 AND created_at < $6
+	UNION ALL
+	SELECT created_at, price, quantity, 1
+	FROM exchange_trades
+	WHERE pair = $7 AND created_at >= $5 AND created_at < $6
+`
+
+// ohlcColumns aggregates points into open, high, low, close, volume, executions and samples
+const ohlcColumns = `
+	(array_agg(price ORDER BY at))[1]::float8,
+	MAX(price)::float8,
+	MIN(price)::float8,
+	(array_agg(price ORDER BY at DESC))[1]::float8,
+	SUM(volume)::float8,
+	SUM(executions)::int,
+	COUNT(*)::int
+`
+
+// pairQuery identifies a pair's observations across the tables that record them
+type pairQuery struct {
+	fiatBase, fiatQuote string // as provider snapshots store them
+	base, quote         string // as traded
+	symbol              string // BASE/QUOTE, as order book trades store it
+}
+
+// Repository reads price observations for market data
+type Repository struct {
+	db *pgxpool.Pool
+}
+
+// NewRepository creates a new market data repository
+func NewRepository(db *pgxpool.Pool) *Repository {
+	return &Repository{db: db}
+}
+
+// GetCandles buckets a pair's observations in [from, to) by unit (a date_trunc field)
+func (r *Repository) GetCandles(ctx context.Context, pair pairQuery, unit string, from, to time.Time) ([]Candle, error) {
+	query := `
+		SELECT date_trunc($8::text, at) AS bucket, ` + ohlcColumns + `
+		FROM (` + pointsQuery + `) points
+		GROUP BY bucket
+		ORDER BY bucket
+	`
+
+	rows, err := r.db.Query(ctx, query, pair.fiatBase, pair.fiatQuote, pair.base, pair.quote, from, to, pair.symbol, unit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get candles: %w", err)
+	}
+	defer rows.Close()
+
+	candles := make([]Candle, 0)
+	for rows.Next() {
+		var c Candle
+		if err := rows.Scan(&c.Time, &c.Open, &c.High, &c.Low, &c.Close, &c.Volume, &c.Executions, &c.Samples); err != nil {
+			return nil, fmt.Errorf("failed to scan candle: %w", err)
+		}
+		candles = append(candles, c)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating candles: %w", err)
+	}
+
+	return candles, nil
+}
+
+// GetSummary aggregates all of a pair's observations in [from, to) into a single candle,
+// returning nil if there are none
+func (r *Repository) GetSummary(ctx context.Context, pair pairQuery, from, to time.Time) (*Candle, error) {
+	query := `
+		SELECT ` + ohlcColumns + `
+		FROM (` + pointsQuery + `) points
+		HAVING COUNT(*) > 0
+	`
+
+	rows, err := r.db.Query(ctx, query, pair.fiatBase, pair.fiatQuote, pair.base, pair.quote, from, to, pair.symbol)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get market summary: %w", err)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+
+	c := Candle{Time: from}
+	if err := rows.Scan(&c.Open, &c.High, &c.Low, &c.Close, &c.Volume, &c.Executions, &c.Samples); err != nil {
+		return nil, fmt.Errorf("failed to scan market summary: %w", err)
+	}
+	return &c, nil
+}
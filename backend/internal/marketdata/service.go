@@ -0,0 +1,131 @@
+package marketdata
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Bwise1/interstellar/internal/exchange"
+	"github.com/Bwise1/interstellar/internal/fxrates"
+)
+
+var (
+	ErrInvalidPair     = errors.New("invalid pair")
+	ErrInvalidInterval = errors.New("interval must be one of 1m, 1h, 1d")
+	ErrInvalidRange    = errors.New("invalid time range")
+	ErrNoMarketData    = errors.New("no market data for pair")
+)
+
+const (
+	// defaultCandles is how many intervals are returned when no from is given
+	defaultCandles = 100
+	// maxCandles bounds the range a single request can cover
+	maxCandles = 1000
+	// statsWindow is the trailing window market statistics cover
+	statsWindow = 24 * time.Hour
+)
+
+// Service aggregates provider snapshots and executed prices into market data
+type Service struct {
+	repo *Repository
+}
+
+// NewService creates a new market data service
+func NewService(repo *Repository) *Service {
+	return &Service{repo: repo}
+}
+
+// pairQueryFor parses a pair such as USDx/cNGN or USD-NGN. Stablecoins are looked up under the
+// fiat currency they track in provider snapshots and as themselves in executions.
+func pairQueryFor(symbol string) (pairQuery, error) {
+	pair, err := exchange.ParsePair(symbol)
+	if err != nil {
+		return pairQuery{}, fmt.Errorf("%w: %s", ErrInvalidPair, err)
+	}
+	return pairQuery{
+		fiatBase:  fxrates.MapToRealCurrency(pair.Base),
+		fiatQuote: fxrates.MapToRealCurrency(pair.Quote),
+		base:      pair.Base,
+		quote:     pair.Quote,
+		symbol:    pair.Symbol(),
+	}, nil
+}
+
+// GetCandles returns OHLC candles for a pair. A zero to means now and a zero from means
+// defaultCandles intervals before to; the range may span at most maxCandles intervals.
+func (s *Service) GetCandles(ctx context.Context, symbol string, interval Interval, from, to time.Time) (*CandlesResponse, error) {
+	pair, err := pairQueryFor(symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	spec, ok := intervals[interval]
+	if !ok {
+		return nil, ErrInvalidInterval
+	}
+
+	if to.IsZero() {
+		to = time.Now()
+	}
+	if from.IsZero() {
+		from = to.Add(-defaultCandles * spec.width)
+	}
+	if !to.After(from) {
+		return nil, fmt.Errorf("%w: from must be before to", ErrInvalidRange)
+	}
+	if to.Sub(from) > maxCandles*spec.width {
+		return nil, fmt.Errorf("%w: at most %d %s candles per request", ErrInvalidRange, maxCandles, interval)
+	}
+
+	candles, err := s.repo.GetCandles(ctx, pair, spec.unit, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CandlesResponse{
+		Pair:     pair.symbol,
+		Interval: interval,
+		From:     from,
+		To:       to,
+		Candles:  candles,
+	}, nil
+}
+
+// GetStats returns a pair's price change, range and volume over the last 24 hours
+func (s *Service) GetStats(ctx context.Context, symbol string) (*Stats, error) {
+	pair, err := pairQueryFor(symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	to := time.Now()
+	from := to.Add(-statsWindow)
+
+	summary, err := s.repo.GetSummary(ctx, pair, from, to)
+	if err != nil {
+		return nil, err
+	}
+	if summary == nil {
+		return nil, fmt.Errorf("%w: %s in the last 24h", ErrNoMarketData, pair.symbol)
+	}
+
+	stats := &Stats{
+		Pair:       pair.symbol,
+		Open:       summary.Open,
+		Last:       summary.Close,
+		High:       summary.High,
+		Low:        summary.Low,
+		Change:     summary.Close - summary.Open,
+		Volume:     summary.Volume,
+		Executions: summary.Executions,
+		Samples:    summary.Samples,
+		From:       from,
+		To:         to,
+	}
+	if summary.Open != 0 {
+		stats.ChangePercent = stats.Change / summary.Open * 100
+	}
+
+	return stats, nil
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"io"
 	"net/http"
+	"regexp"
 	"strings"
 
 	"github.com/Bwise1/interstellar/internal/auditlogs"
@@ -35,7 +36,7 @@ func AuditMiddleware(auditService AuditLoggerService) func(next http.Handler) ht
 			}
 
 			// Get client IP
-			clientIP := getClientIP(r)
+			clientIP := ClientIP(r)
 
 			// Get user agent
 			userAgent := r.UserAgent()
@@ -99,21 +100,25 @@ func shouldLogBody(method string) bool {
 	return method == http.MethodPost || method == http.MethodPut || method == http.MethodPatch
 }
 
+// sensitiveFields matches JSON string fields whose values must never reach the audit log
+var sensitiveFields = regexp.MustCompile(`"(password|code|challenge_token|refresh_token)"\s*:\s*"(?:[^"\\]|\\.)*"`)
+
 // remove sensitive information from request body
 func sanitizeBody(body string) string {
-
-	sanitized := body
-
-	if strings.Contains(sanitized, "password") {
-
-		sanitized = strings.ReplaceAll(sanitized, "\"password\":", "\"password\":\"***REDACTED***\"")
-	}
-
-	return sanitized
+	return sensitiveFields.ReplaceAllString(body, `"$1":"***REDACTED***"`)
 }
 
 // determineOperation maps HTTP method and path to operation name
 func determineOperation(method, path string) string {
+	if strings.Contains(path, "/auth/logout") {
+		return "LOGOUT"
+	}
+	if strings.Contains(path, "/auth/refresh") {
+		return "REFRESH_TOKEN"
+	}
+	if strings.Contains(path, "/auth/login/2fa") {
+		return "LOGIN_2FA"
+	}
 	if strings.Contains(path, "/auth/login") {
 		return "LOGIN"
 	}
@@ -135,13 +140,16 @@ func determineOperation(method, path string) string {
 	if strings.Contains(path, "/wallets") && method == http.MethodGet {
 		return "VIEW_WALLET"
 	}
+	if strings.HasSuffix(path, "/roles") && method == http.MethodPut {
+		return "CHANGE_ROLES"
+	}
 
 	return method + " " + path
 }
 
-// getClientIP extracts the real client IP from the request
+// ClientIP extracts the real client IP from the request
 // Supports Cloudflare, standard proxies, and IPv6
-func getClientIP(r *http.Request) string {
+func ClientIP(r *http.Request) string {
 	// Priority 1: Cloudflare specific header (most reliable when behind Cloudflare)
 	cfConnectingIP := r.Header.Get("CF-Connecting-IP")
 	if cfConnectingIP != "" {
@@ -7,44 +7,59 @@ import (
 	"github.com/Bwise1/interstellar/internal/utils"
 )
 
-// AuthMiddleware validates JWT tokens and adds user info to context
-func AuthMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Get token from Authorization header
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
-			respondWithError(w, http.StatusUnauthorized, "Missing authorization header")
-			return
-		}
-
-		// Check if it's a Bearer token
-		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 || parts[0] != "Bearer" {
-			respondWithError(w, http.StatusUnauthorized, "Invalid authorization header format")
-			return
-		}
-
-		tokenString := parts[1]
-
-		// Validate token
-		claims, err := utils.ValidateToken(tokenString)
-		if err != nil {
-			if err == utils.ErrExpiredToken {
-				respondWithError(w, http.StatusUnauthorized, "Token has expired")
+// TokenDenylist reports access tokens revoked before they expire
+type TokenDenylist interface {
+	IsTokenRevoked(jti string) bool
+}
+
+// AuthMiddleware validates JWT tokens, rejects revoked ones and adds user info to context
+func AuthMiddleware(denylist TokenDenylist) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Get token from Authorization header
+			authHeader := r.Header.Get("Authorization")
+			if authHeader == "" {
+				respondWithError(w, http.StatusUnauthorized, "Missing authorization header")
+				return
+			}
+
+			// Check if it's a Bearer token
+			parts := strings.Split(authHeader, " ")
+			if len(parts) != 2 || parts[0] != "Bearer" {
+				respondWithError(w, http.StatusUnauthorized, "Invalid authorization header format")
+				return
+			}
+
+			tokenString := parts[1]
+
+			// Validate token
+			claims, err := utils.ValidateToken(tokenString)
+			if err != nil {
+				if err == utils.ErrExpiredToken {
+					respondWithError(w, http.StatusUnauthorized, "Token has expired")
+					return
+				}
+				respondWithError(w, http.StatusUnauthorized, "Invalid token")
 				return
 			}
-			respondWithError(w, http.StatusUnauthorized, "Invalid token")
-			return
-		}
-
-		// Add user info to context using utils constants
-		ctx := r.Context()
-		ctx = utils.SetUserIDInContext(ctx, claims.UserID)
-		ctx = utils.SetEmailInContext(ctx, claims.Email)
-
-		// Call next handler with updated context
-		next.ServeHTTP(w, r.WithContext(ctx))
-	})
+
+			// Logged-out sessions leave their access tokens on the denylist until they expire
+			if denylist.IsTokenRevoked(claims.ID) {
+				respondWithError(w, http.StatusUnauthorized, "Token has been revoked")
+				return
+			}
+
+			// Add user info to context using utils constants
+			ctx := r.Context()
+			ctx = utils.SetUserIDInContext(ctx, claims.UserID)
+			ctx = utils.SetEmailInContext(ctx, claims.Email)
+			ctx = utils.SetSessionIDInContext(ctx, claims.SessionID)
+			ctx = utils.SetRolesInContext(ctx, claims.Roles)
+
+			// Call next handler with updated context
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
 }
 
 // Helper function for error responses
@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/Bwise1/interstellar/internal/rbac"
+	"github.com/Bwise1/interstellar/internal/utils"
+)
+
+// RequirePermission only lets through authenticated users whose roles carry perm.
+// Must run after AuthMiddleware.
+func RequirePermission(perm rbac.Permission) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !rbac.Allows(utils.GetRolesFromContext(r.Context()), perm) {
+				respondWithError(w, http.StatusForbidden, "Missing permission: "+string(perm))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package orders
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/Bwise1/interstellar/internal/utils"
+	"github.com/Bwise1/interstellar/internal/wallets"
+	"github.com/Bwise1/interstellar/pkg/response"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// Handler handles HTTP requests for limit orders
+type Handler struct {
+	service *Service
+}
+
+// NewHandler creates a new limit order handler
+func NewHandler(service *Service) *Handler {
+	return &Handler{
+		service: service,
+	}
+}
+
+// POST /api/orders
+func (h *Handler) PlaceOrder(w http.ResponseWriter, r *http.Request) {
+	userID, ok := utils.GetUserIDFromContext(r.Context())
+	if !ok || userID == uuid.Nil {
+		response.Error(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req PlaceOrderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	order, err := h.service.PlaceOrder(r.Context(), userID, &req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	response.Success(w, http.StatusCreated, "Limit order placed successfully", order)
+}
+
+// GET /api/orders?status=OPEN
+func (h *Handler) GetOrders(w http.ResponseWriter, r *http.Request) {
+	userID, ok := utils.GetUserIDFromContext(r.Context())
+	if !ok || userID == uuid.Nil {
+		response.Error(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	status := OrderStatus(strings.ToUpper(r.URL.Query().Get("status")))
+	switch status {
+	case "", OrderStatusOpen, OrderStatusExecuting, OrderStatusFilled, OrderStatusCancelled, OrderStatusExpired:
+	default:
+		response.Error(w, http.StatusBadRequest, "Invalid status")
+		return
+	}
+
+	orders, err := h.service.GetOrders(r.Context(), userID, status)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to retrieve limit orders")
+		return
+	}
+
+	response.Success(w, http.StatusOK, "Limit orders retrieved successfully", orders)
+}
+
+// GET /api/orders/{id}
+func (h *Handler) GetOrder(w http.ResponseWriter, r *http.Request) {
+	userID, ok := utils.GetUserIDFromContext(r.Context())
+	if !ok || userID == uuid.Nil {
+		response.Error(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	orderID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid order ID")
+		return
+	}
+
+	order, err := h.service.GetOrder(r.Context(), userID, orderID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	response.Success(w, http.StatusOK, "Limit order retrieved successfully", order)
+}
+
+// POST /api/orders/{id}/cancel
+func (h *Handler) CancelOrder(w http.ResponseWriter, r *http.Request) {
+	userID, ok := utils.GetUserIDFromContext(r.Context())
+	if !ok || userID == uuid.Nil {
+		response.Error(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	orderID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid order ID")
+		return
+	}
+
+	order, err := h.service.CancelOrder(r.Context(), userID, orderID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	response.Success(w, http.StatusOK, "Limit order cancelled successfully", order)
+}
+
+// writeError maps limit order errors to HTTP responses
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrOrderNotFound):
+		response.Error(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, ErrInvalidOrder), errors.Is(err, wallets.ErrInsufficientFunds):
+		response.Error(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, wallets.ErrWalletFrozen), errors.Is(err, wallets.ErrWalletClosed):
+		response.Error(w, http.StatusForbidden, err.Error())
+	case errors.Is(err, ErrOrderClosed):
+		response.Error(w, http.StatusConflict, err.Error())
+	default:
+		response.Error(w, http.StatusInternalServerError, err.Error())
+	}
+}
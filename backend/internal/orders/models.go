@@ -0,0 +1,58 @@
+package orders
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OrderStatus represents the state of a limit order
+type OrderStatus string
+
+const (
+	OrderStatusOpen      OrderStatus = "OPEN"
+	OrderStatusExecuting OrderStatus = "EXECUTING" // claimed by the matcher while its swap runs
+	OrderStatusFilled    OrderStatus = "FILLED"
+	OrderStatusCancelled OrderStatus = "CANCELLED"
+	OrderStatusExpired   OrderStatus = "EXPIRED"
+)
+
+// TimeInForce controls how long an order stays open
+type TimeInForce string
+
+const (
+	TimeInForceGTC TimeInForce = "GTC" // good till cancelled
+	TimeInForceGTD TimeInForce = "GTD" // good till ExpiresAt
+)
+
+// Order swaps Amount of FromCurrency into ToCurrency once the rate reaches LimitRate.
+// The amount is reserved out of the wallet balance while the order is open.
+type Order struct {
+	ID            uuid.UUID   `json:"id"`
+	UserID        uuid.UUID   `json:"user_id"`
+	FromCurrency  string      `json:"from_currency"`
+	ToCurrency    string      `json:"to_currency"`
+	Amount        float64     `json:"amount"`
+	LimitRate     float64     `json:"limit_rate"` // minimum ToCurrency received per unit of FromCurrency
+	TimeInForce   TimeInForce `json:"time_in_force"`
+	ExpiresAt     *time.Time  `json:"expires_at,omitempty"`
+	Status        OrderStatus `json:"status"`
+	SwapTxID      *uuid.UUID  `json:"swap_transaction_id,omitempty"`
+	ExecutedRate  *float64    `json:"executed_rate,omitempty"`
+	ToAmount      *float64    `json:"to_amount,omitempty"`
+	FailureReason string      `json:"failure_reason,omitempty"` // last execution attempt that didn't go through
+	ExecutionID   *uuid.UUID  `json:"-"`                        // SWAP transaction ID reserved while EXECUTING
+	CreatedAt     time.Time   `json:"created_at"`
+	UpdatedAt     time.Time   `json:"updated_at"`
+	ClosedAt      *time.Time  `json:"closed_at,omitempty"`
+}
+
+// PlaceOrderRequest represents a request to place a limit order
+type PlaceOrderRequest struct {
+	FromCurrency string      `json:"from_currency"`
+	ToCurrency   string      `json:"to_currency"`
+	Amount       float64     `json:"amount"`
+	LimitRate    float64     `json:"limit_rate"`
+	TimeInForce  TimeInForce `json:"time_in_force"` // defaults to GTC
+	ExpiresAt    *time.Time  `json:"expires_at,omitempty"`
+}
@@ -0,0 +1,217 @@
+package orders
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Repository handles database operations for limit orders
+type Repository struct {
+	db *pgxpool.Pool
+}
+
+// NewRepository creates a new limit order repository
+func NewRepository(db *pgxpool.Pool) *Repository {
+	return &Repository{db: db}
+}
+
+const orderColumns = `
+	id, user_id, from_currency, to_currency, amount::float8, limit_rate::float8, time_in_force,
+	expires_at, status, swap_transaction_id, executed_rate::float8, to_amount::float8,
+	COALESCE(failure_reason, ''), execution_id, created_at, updated_at, closed_at
+`
+
+// scanOrder scans a single order row
+func scanOrder(row pgx.Row) (*Order, error) {
+	var o Order
+	err := row.Scan(
+		&o.ID,
+		&o.UserID,
+		&o.FromCurrency,
+		&o.ToCurrency,
+		&o.Amount,
+		&o.LimitRate,
+		&o.TimeInForce,
+		&o.ExpiresAt,
+		&o.Status,
+		&o.SwapTxID,
+		&o.ExecutedRate,
+		&o.ToAmount,
+		&o.FailureReason,
+		&o.ExecutionID,
+		&o.CreatedAt,
+		&o.UpdatedAt,
+		&o.ClosedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+// Create inserts a new order
+func (r *Repository) Create(ctx context.Context, o *Order) error {
+	query := `
+		INSERT INTO limit_orders (
+			id, user_id, from_currency, to_currency, amount, limit_rate,
+			time_in_force, expires_at, status, created_at, updated_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	`
+
+	_, err := r.db.Exec(
+		ctx,
+		query,
+		o.ID,
+		o.UserID,
+		o.FromCurrency,
+		o.ToCurrency,
+		o.Amount,
+		o.LimitRate,
+		o.TimeInForce,
+		o.ExpiresAt,
+		o.Status,
+		o.CreatedAt,
+		o.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create limit order: %w", err)
+	}
+
+	return nil
+}
+
+// GetByID retrieves an order, returning nil when it does not exist
+func (r *Repository) GetByID(ctx context.Context, id uuid.UUID) (*Order, error) {
+	query := `SELECT ` + orderColumns + ` FROM limit_orders WHERE id = $1`
+
+	order, err := scanOrder(r.db.QueryRow(ctx, query, id))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get limit order: %w", err)
+	}
+
+	return order, nil
+}
+
+// GetByUserID lists a user's orders, newest first, optionally filtered by status
+func (r *Repository) GetByUserID(ctx context.Context, userID uuid.UUID, status OrderStatus) ([]*Order, error) {
+	if status != "" {
+		query := `SELECT ` + orderColumns + ` FROM limit_orders WHERE user_id = $1 AND status = $2 ORDER BY created_at DESC`
+		return r.list(ctx, query, userID, status)
+	}
+	query := `SELECT ` + orderColumns + ` FROM limit_orders WHERE user_id = $1 ORDER BY created_at DESC`
+	return r.list(ctx, query, userID)
+}
+
+// GetOpen lists every open order for the matcher, oldest first
+func (r *Repository) GetOpen(ctx context.Context) ([]*Order, error) {
+	query := `SELECT ` + orderColumns + ` FROM limit_orders WHERE status = 'OPEN' ORDER BY created_at`
+	return r.list(ctx, query)
+}
+
+// GetExecuting lists orders the matcher claimed but never settled
+func (r *Repository) GetExecuting(ctx context.Context) ([]*Order, error) {
+	query := `SELECT ` + orderColumns + ` FROM limit_orders WHERE status = 'EXECUTING' ORDER BY created_at`
+	return r.list(ctx, query)
+}
+
+// list runs an order query and scans every row
+func (r *Repository) list(ctx context.Context, query string, args ...any) ([]*Order, error) {
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list limit orders: %w", err)
+	}
+	defer rows.Close()
+
+	orders := make([]*Order, 0)
+	for rows.Next() {
+		order, err := scanOrder(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan limit order: %w", err)
+		}
+		orders = append(orders, order)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating limit orders: %w", err)
+	}
+
+	return orders, nil
+}
+
+// SetStatus moves an order between states, returning false if it was not in the expected state.
+// closedAt is set for terminal states; reason records why an execution attempt did not go through.
+// Any execution ID is cleared.
+func (r *Repository) SetStatus(ctx context.Context, id uuid.UUID, from, to OrderStatus, closedAt *time.Time, reason string) (bool, error) {
+	query := `
+		UPDATE limit_orders
+		SET status = $1, closed_at = $2, failure_reason = NULLIF($3, ''), execution_id = NULL, updated_at = NOW()
+		WHERE id = $4 AND status = $5
+	`
+
+	result, err := r.db.Exec(ctx, query, to, closedAt, reason, id, from)
+	if err != nil {
+		return false, fmt.Errorf("failed to update limit order status: %w", err)
+	}
+
+	return result.RowsAffected() == 1, nil
+}
+
+// Claim moves an open order to EXECUTING under the ID its SWAP transaction will be recorded with,
+// returning false if the order is no longer open
+func (r *Repository) Claim(ctx context.Context, id, executionID uuid.UUID) (bool, error) {
+	query := `
+		UPDATE limit_orders
+		SET status = 'EXECUTING', execution_id = $1, failure_reason = NULL, updated_at = NOW()
+		WHERE id = $2 AND status = 'OPEN'
+	`
+
+	result, err := r.db.Exec(ctx, query, executionID, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to claim limit order: %w", err)
+	}
+
+	return result.RowsAffected() == 1, nil
+}
+
+// GetSwap looks up the SWAP transaction recorded under an order's execution ID, returning false
+// when the swap never happened
+func (r *Repository) GetSwap(ctx context.Context, executionID uuid.UUID) (rate, toAmount float64, found bool, err error) {
+	query := `
+		SELECT exchange_rate::float8, to_amount::float8
+		FROM transactions
+		WHERE id = $1 AND transaction_type = 'SWAP'
+	`
+
+	err = r.db.QueryRow(ctx, query, executionID).Scan(&rate, &toAmount)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return 0, 0, false, nil
+		}
+		return 0, 0, false, fmt.Errorf("failed to get limit order swap: %w", err)
+	}
+
+	return rate, toAmount, true, nil
+}
+
+// MarkFilled records an executing order's swap
+func (r *Repository) MarkFilled(ctx context.Context, id, swapTxID uuid.UUID, rate, toAmount float64, at time.Time) error {
+	query := `
+		UPDATE limit_orders
+		SET status = 'FILLED', swap_transaction_id = $1, executed_rate = $2, to_amount = $3,
+			failure_reason = NULL, closed_at = $4, updated_at = $4
+		WHERE id = $5 AND status = 'EXECUTING'
+	`
+
+	if _, err := r.db.Exec(ctx, query, swapTxID, rate, toAmount, at, id); err != nil {
+		return fmt.Errorf("failed to mark limit order filled: %w", err)
+	}
+	return nil
+}
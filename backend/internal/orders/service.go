@@ -0,0 +1,339 @@
+package orders
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/Bwise1/interstellar/internal/fxrates"
+	"github.com/Bwise1/interstellar/internal/transactions"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrOrderNotFound = errors.New("limit order not found")
+	ErrOrderClosed   = errors.New("limit order is no longer open")
+	ErrInvalidOrder  = errors.New("invalid limit order")
+)
+
+const (
+	expiryCheckInterval = time.Minute // how often the matcher looks for expired orders when rates are quiet
+
+	markFilledAttempts  = 5
+	markFilledRetryBase = 500 * time.Millisecond
+)
+
+// Ledger reserves and releases order funds and executes swaps
+type Ledger interface {
+	ProcessOrderMovement(ctx context.Context, userID uuid.UUID, txType transactions.TransactionType, currency string, amount float64) (*transactions.Transaction, error)
+	ProcessSwap(ctx context.Context, userID uuid.UUID, req *transactions.SwapRequest) (*transactions.Transaction, error)
+}
+
+// RateSource supplies tradable rates and signals when they change
+type RateSource interface {
+	GetRate(ctx context.Context, from, to string) (float64, error)
+	Subscribe() (<-chan struct{}, func())
+}
+
+// Service manages limit orders and matches them against published rates
+type Service struct {
+	repo   *Repository
+	ledger Ledger
+	rates  RateSource
+	wake   chan struct{} // nudges the matcher when an order is placed
+}
+
+// NewService creates a new limit order service
+func NewService(repo *Repository, ledger Ledger, rates RateSource) *Service {
+	return &Service{
+		repo:   repo,
+		ledger: ledger,
+		rates:  rates,
+		wake:   make(chan struct{}, 1),
+	}
+}
+
+// PlaceOrder reserves the order amount from the user's wallet and opens the order
+func (s *Service) PlaceOrder(ctx context.Context, userID uuid.UUID, req *PlaceOrderRequest) (*Order, error) {
+	if err := validateOrder(req); err != nil {
+		return nil, err
+	}
+	if _, err := s.rates.GetRate(ctx, req.FromCurrency, req.ToCurrency); err != nil && !errors.Is(err, fxrates.ErrMarketUnavailable) {
+		return nil, fmt.Errorf("%w: no rate available for %s/%s", ErrInvalidOrder, req.FromCurrency, req.ToCurrency)
+	}
+
+	if _, err := s.ledger.ProcessOrderMovement(ctx, userID, transactions.TransactionTypeOrderReserve, req.FromCurrency, req.Amount); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	order := &Order{
+		ID:           uuid.New(),
+		UserID:       userID,
+		FromCurrency: req.FromCurrency,
+		ToCurrency:   req.ToCurrency,
+		Amount:       req.Amount,
+		LimitRate:    req.LimitRate,
+		TimeInForce:  req.TimeInForce,
+		ExpiresAt:    req.ExpiresAt,
+		Status:       OrderStatusOpen,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+
+	if err := s.repo.Create(ctx, order); err != nil {
+		// Hand the reservation back; the order never existed
+		if _, releaseErr := s.ledger.ProcessOrderMovement(ctx, userID, transactions.TransactionTypeOrderRelease, req.FromCurrency, req.Amount); releaseErr != nil {
+			slog.Error("failed to release funds for unsaved limit order", "user_id", userID, "currency", req.FromCurrency, "amount", req.Amount, "error", releaseErr)
+		}
+		return nil, err
+	}
+
+	// The rate may already be good enough
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+
+	return order, nil
+}
+
+// validateOrder checks a placement request and fills in defaults
+func validateOrder(req *PlaceOrderRequest) error {
+	req.FromCurrency = strings.TrimSpace(req.FromCurrency)
+	req.ToCurrency = strings.TrimSpace(req.ToCurrency)
+	if req.TimeInForce == "" {
+		req.TimeInForce = TimeInForceGTC
+	}
+
+	switch {
+	case req.FromCurrency == "" || req.ToCurrency == "":
+		return fmt.Errorf("%w: from_currency and to_currency are required", ErrInvalidOrder)
+	case req.FromCurrency == req.ToCurrency:
+		return fmt.Errorf("%w: cannot swap same currency", ErrInvalidOrder)
+	case math.IsNaN(req.Amount) || req.Amount <= 0:
+		return fmt.Errorf("%w: amount must be greater than 0", ErrInvalidOrder)
+	case math.IsNaN(req.LimitRate) || math.IsInf(req.LimitRate, 0) || req.LimitRate <= 0:
+		return fmt.Errorf("%w: limit_rate must be greater than 0", ErrInvalidOrder)
+	}
+
+	switch req.TimeInForce {
+	case TimeInForceGTC:
+		if req.ExpiresAt != nil {
+			return fmt.Errorf("%w: expires_at requires time_in_force GTD", ErrInvalidOrder)
+		}
+	case TimeInForceGTD:
+		if req.ExpiresAt == nil || !req.ExpiresAt.After(time.Now()) {
+			return fmt.Errorf("%w: GTD orders need a future expires_at", ErrInvalidOrder)
+		}
+	default:
+		return fmt.Errorf("%w: time_in_force must be GTC or GTD", ErrInvalidOrder)
+	}
+	return nil
+}
+
+// GetOrders lists a user's orders, optionally filtered by status
+func (s *Service) GetOrders(ctx context.Context, userID uuid.UUID, status OrderStatus) ([]*Order, error) {
+	return s.repo.GetByUserID(ctx, userID, status)
+}
+
+// GetOrder returns one of a user's orders
+func (s *Service) GetOrder(ctx context.Context, userID, orderID uuid.UUID) (*Order, error) {
+	order, err := s.repo.GetByID(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if order == nil || order.UserID != userID {
+		return nil, ErrOrderNotFound
+	}
+	return order, nil
+}
+
+// CancelOrder closes an open order and returns its reserved funds
+func (s *Service) CancelOrder(ctx context.Context, userID, orderID uuid.UUID) (*Order, error) {
+	order, err := s.GetOrder(ctx, userID, orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.close(ctx, order, OrderStatusCancelled); err != nil {
+		return nil, err
+	}
+	return s.repo.GetByID(ctx, order.ID)
+}
+
+// close moves an open order to a terminal state and releases its funds. The status is claimed
+// first so the matcher can't execute the order while its funds are on their way back.
+func (s *Service) close(ctx context.Context, order *Order, status OrderStatus) error {
+	now := time.Now()
+	claimed, err := s.repo.SetStatus(ctx, order.ID, OrderStatusOpen, status, &now, "")
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		return ErrOrderClosed
+	}
+
+	if _, err := s.ledger.ProcessOrderMovement(ctx, order.UserID, transactions.TransactionTypeOrderRelease, order.FromCurrency, order.Amount); err != nil {
+		// Reopen so the funds stay accounted for by a live order
+		if _, reopenErr := s.repo.SetStatus(ctx, order.ID, status, OrderStatusOpen, nil, ""); reopenErr != nil {
+			slog.Error("failed to reopen limit order after release failure", "order_id", order.ID, "error", reopenErr)
+		}
+		return fmt.Errorf("failed to release order funds: %w", err)
+	}
+	return nil
+}
+
+// MatchOrders expires lapsed orders and executes those whose limit the current rate meets.
+// Each pair's rate is looked up once per run.
+func (s *Service) MatchOrders(ctx context.Context) error {
+	orders, err := s.repo.GetOpen(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	rates := make(map[string]float64)
+	for _, order := range orders {
+		if order.ExpiresAt != nil && !now.Before(*order.ExpiresAt) {
+			if err := s.close(ctx, order, OrderStatusExpired); err != nil && !errors.Is(err, ErrOrderClosed) {
+				slog.Error("failed to expire limit order", "order_id", order.ID, "error", err)
+			}
+			continue
+		}
+
+		pair := order.FromCurrency + "/" + order.ToCurrency
+		rate, seen := rates[pair]
+		if !seen {
+			// A halted or unavailable market simply leaves its orders open
+			rate, _ = s.rates.GetRate(ctx, order.FromCurrency, order.ToCurrency)
+			rates[pair] = rate
+		}
+		if rate <= 0 || rate < order.LimitRate {
+			continue
+		}
+
+		if err := s.execute(ctx, order); err != nil {
+			slog.Warn("limit order execution failed", "order_id", order.ID, "error", err)
+		}
+	}
+	return nil
+}
+
+// execute swaps an order's reserved funds, refusing any rate below its limit. The swap is
+// recorded under an execution ID saved on the order first, so ReconcileExecuting can tell whether
+// it went through if the order is left EXECUTING.
+func (s *Service) execute(ctx context.Context, order *Order) error {
+	executionID := uuid.New()
+	claimed, err := s.repo.Claim(ctx, order.ID, executionID)
+	if err != nil || !claimed {
+		return err
+	}
+
+	limit := order.LimitRate
+	tx, err := s.ledger.ProcessSwap(ctx, order.UserID, &transactions.SwapRequest{
+		FromCurrency:  order.FromCurrency,
+		ToCurrency:    order.ToCurrency,
+		Amount:        order.Amount,
+		MinRate:       &limit,
+		FromReserved:  true,
+		TransactionID: executionID,
+	})
+	if err != nil {
+		// The funds are still reserved; leave the order open for the next qualifying rate
+		if _, reopenErr := s.repo.SetStatus(ctx, order.ID, OrderStatusExecuting, OrderStatusOpen, nil, err.Error()); reopenErr != nil {
+			slog.Error("failed to reopen limit order", "order_id", order.ID, "error", reopenErr)
+		}
+		return err
+	}
+
+	return s.markFilled(ctx, order.ID, tx.ID, *tx.ExchangeRate, *tx.ToAmount)
+}
+
+// markFilled records a completed swap on its order. The swap can't be undone, so a failed update
+// is retried with backoff; an order still EXECUTING after that is settled by ReconcileExecuting.
+func (s *Service) markFilled(ctx context.Context, orderID, swapTxID uuid.UUID, rate, toAmount float64) error {
+	delay := markFilledRetryBase
+	for attempt := 1; ; attempt++ {
+		err := s.repo.MarkFilled(ctx, orderID, swapTxID, rate, toAmount, time.Now())
+		if err == nil {
+			return nil
+		}
+		if attempt >= markFilledAttempts {
+			return fmt.Errorf("swap %s completed but the order was not marked filled: %w", swapTxID, err)
+		}
+
+		slog.Warn("failed to mark limit order filled, retrying", "order_id", orderID, "attempt", attempt, "retry_in", delay, "error", err)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// ReconcileExecuting settles orders a crash left EXECUTING. An order whose swap was recorded is
+// marked filled; one whose swap never happened still has its funds reserved and is reopened.
+func (s *Service) ReconcileExecuting(ctx context.Context) error {
+	orders, err := s.repo.GetExecuting(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, order := range orders {
+		if order.ExecutionID != nil {
+			rate, toAmount, found, err := s.repo.GetSwap(ctx, *order.ExecutionID)
+			if err != nil {
+				return err
+			}
+			if found {
+				if err := s.markFilled(ctx, order.ID, *order.ExecutionID, rate, toAmount); err != nil {
+					return err
+				}
+				slog.Info("reconciled executed limit order", "order_id", order.ID, "swap_transaction_id", *order.ExecutionID)
+				continue
+			}
+		}
+
+		if _, err := s.repo.SetStatus(ctx, order.ID, OrderStatusExecuting, OrderStatusOpen, nil, "execution interrupted"); err != nil {
+			return err
+		}
+		slog.Info("reopened interrupted limit order", "order_id", order.ID)
+	}
+	return nil
+}
+
+// RunMatcher settles interrupted executions, then matches orders on startup, whenever rates
+// change or an order is placed, and periodically to expire orders, until ctx is cancelled
+func (s *Service) RunMatcher(ctx context.Context) {
+	// Only this goroutine executes orders, so any order still EXECUTING was interrupted
+	if err := s.ReconcileExecuting(ctx); err != nil {
+		slog.Error("failed to reconcile executing limit orders", "error", err)
+	}
+
+	updates, unsubscribe := s.rates.Subscribe()
+	defer unsubscribe()
+
+	ticker := time.NewTicker(expiryCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.MatchOrders(ctx); err != nil {
+			slog.Error("limit order matching failed", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-updates:
+		case <-s.wake:
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package rbac
+
+import "sort"
+
+// Roles. Every account has RoleUser, which carries no extra permissions; the others are granted.
+const (
+	RoleUser       = "user"
+	RoleSupport    = "support"
+	RoleCompliance = "compliance"
+	RoleAdmin      = "admin"
+)
+
+// Permission is an action guarded by RequirePermission
+type Permission string
+
+const (
+	PermAuditRead     Permission = "audit:read"     // read any user's audit logs
+	PermWalletsManage Permission = "wallets:manage" // freeze, unfreeze or close wallets
+	PermFXManage      Permission = "fx:manage"      // rate overrides, quarantine, refreshes and quota
+	PermRolesManage   Permission = "roles:manage"   // grant and revoke roles
+)
+
+// grants lists the permissions carried by each grantable role, in the order roles are shown
+var grants = []RoleInfo{
+	{Role: RoleSupport, Permissions: []Permission{PermAuditRead}},
+	{Role: RoleCompliance, Permissions: []Permission{PermAuditRead, PermWalletsManage}},
+	{Role: RoleAdmin, Permissions: []Permission{PermAuditRead, PermWalletsManage, PermFXManage, PermRolesManage}},
+}
+
+// RoleInfo describes a grantable role
+type RoleInfo struct {
+	Role        string       `json:"role"`
+	Permissions []Permission `json:"permissions"`
+}
+
+// Roles lists the grantable roles and their permissions
+func Roles() []RoleInfo {
+	return grants
+}
+
+// IsGrantable reports whether role can be granted to a user
+func IsGrantable(role string) bool {
+	return lookup(role) != nil
+}
+
+// Allows reports whether any of roles carries perm
+func Allows(roles []string, perm Permission) bool {
+	for _, role := range roles {
+		if info := lookup(role); info != nil {
+			for _, p := range info.Permissions {
+				if p == perm {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// Permissions returns every permission roles carry, sorted and without duplicates
+func Permissions(roles []string) []Permission {
+	seen := make(map[Permission]bool)
+	perms := []Permission{}
+	for _, role := range roles {
+		if info := lookup(role); info != nil {
+			for _, p := range info.Permissions {
+				if !seen[p] {
+					seen[p] = true
+					perms = append(perms, p)
+				}
+			}
+		}
+	}
+	sort.Slice(perms, func(i, j int) bool { return perms[i] < perms[j] })
+	return perms
+}
+
+func lookup(role string) *RoleInfo {
+	for i := range grants {
+		if grants[i].Role == role {
+			return &grants[i]
+		}
+	}
+	return nil
+}
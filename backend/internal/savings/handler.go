@@ -0,0 +1,129 @@
+package savings
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/Bwise1/interstellar/internal/utils"
+	"github.com/Bwise1/interstellar/pkg/response"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// Handler handles HTTP requests for savings pockets
+type Handler struct {
+	service *Service
+}
+
+// NewHandler creates a new savings handler
+func NewHandler(service *Service) *Handler {
+	return &Handler{
+		service: service,
+	}
+}
+
+// GET /api/savings/rates
+func (h *Handler) GetRates(w http.ResponseWriter, r *http.Request) {
+	response.Success(w, http.StatusOK, "Savings rates retrieved successfully", h.service.GetRates())
+}
+
+// POST /api/savings/pockets
+func (h *Handler) CreatePocket(w http.ResponseWriter, r *http.Request) {
+	userID, ok := utils.GetUserIDFromContext(r.Context())
+	if !ok || userID == uuid.Nil {
+		response.Error(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req CreatePocketRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	pocket, err := h.service.CreatePocket(r.Context(), userID, &req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	response.Success(w, http.StatusCreated, "Savings pocket created successfully", pocket)
+}
+
+// GET /api/savings/pockets
+func (h *Handler) GetPockets(w http.ResponseWriter, r *http.Request) {
+	userID, ok := utils.GetUserIDFromContext(r.Context())
+	if !ok || userID == uuid.Nil {
+		response.Error(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	pockets, err := h.service.GetPockets(r.Context(), userID)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to retrieve savings pockets")
+		return
+	}
+
+	response.Success(w, http.StatusOK, "Savings pockets retrieved successfully", pockets)
+}
+
+// GET /api/savings/pockets/{id}
+func (h *Handler) GetPocket(w http.ResponseWriter, r *http.Request) {
+	userID, ok := utils.GetUserIDFromContext(r.Context())
+	if !ok || userID == uuid.Nil {
+		response.Error(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	pocketID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid pocket ID")
+		return
+	}
+
+	pocket, err := h.service.GetPocket(r.Context(), userID, pocketID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	response.Success(w, http.StatusOK, "Savings pocket retrieved successfully", pocket)
+}
+
+// POST /api/savings/pockets/{id}/close
+func (h *Handler) ClosePocket(w http.ResponseWriter, r *http.Request) {
+	userID, ok := utils.GetUserIDFromContext(r.Context())
+	if !ok || userID == uuid.Nil {
+		response.Error(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	pocketID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid pocket ID")
+		return
+	}
+
+	pocket, err := h.service.ClosePocket(r.Context(), userID, pocketID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	response.Success(w, http.StatusOK, "Savings pocket closed successfully", pocket)
+}
+
+// writeError maps savings errors to HTTP responses
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrPocketNotFound):
+		response.Error(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, ErrUnsupportedCurrency), errors.Is(err, ErrInvalidAmount):
+		response.Error(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, ErrPocketClosed):
+		response.Error(w, http.StatusConflict, err.Error())
+	default:
+		response.Error(w, http.StatusInternalServerError, err.Error())
+	}
+}
@@ -0,0 +1,44 @@
+package savings
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PocketStatus represents the state of a savings pocket
+type PocketStatus string
+
+const (
+	PocketStatusOpen   PocketStatus = "OPEN"
+	PocketStatusClosed PocketStatus = "CLOSED"
+)
+
+// Pocket holds funds locked out of a wallet to earn interest
+type Pocket struct {
+	ID              uuid.UUID    `json:"id"`
+	UserID          uuid.UUID    `json:"user_id"`
+	WalletID        uuid.UUID    `json:"wallet_id"`
+	Currency        string       `json:"currency"`
+	Principal       float64      `json:"principal"`
+	APRBps          int64        `json:"apr_bps"` // annual rate in basis points, 400 = 4.00%
+	AccruedInterest float64      `json:"accrued_interest"`
+	AccruedDays     int64        `json:"accrued_days"`
+	Status          PocketStatus `json:"status"`
+	OpenedAt        time.Time    `json:"opened_at"`
+	LastAccruedAt   *time.Time   `json:"last_accrued_at,omitempty"`
+	ClosedAt        *time.Time   `json:"closed_at,omitempty"`
+}
+
+// CreatePocketRequest represents a request to lock funds into a pocket
+type CreatePocketRequest struct {
+	Currency string  `json:"currency"`
+	Amount   float64 `json:"amount"`
+}
+
+// RateResponse describes the APR offered for a currency
+type RateResponse struct {
+	Currency string  `json:"currency"`
+	APRBps   int64   `json:"apr_bps"`
+	APR      float64 `json:"apr"`
+}
@@ -0,0 +1,162 @@
+package savings
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Repository handles database operations for savings pockets
+type Repository struct {
+	db *pgxpool.Pool
+}
+
+// NewRepository creates a new savings repository
+func NewRepository(db *pgxpool.Pool) *Repository {
+	return &Repository{db: db}
+}
+
+const pocketColumns = `
+	id, user_id, wallet_id, currency, principal::float8, apr_bps,
+	accrued_interest::float8, accrued_days, status, opened_at, last_accrued_at, closed_at
+`
+
+// scanPocket scans a single pocket row
+func scanPocket(row pgx.Row) (*Pocket, error) {
+	var p Pocket
+	err := row.Scan(
+		&p.ID,
+		&p.UserID,
+		&p.WalletID,
+		&p.Currency,
+		&p.Principal,
+		&p.APRBps,
+		&p.AccruedInterest,
+		&p.AccruedDays,
+		&p.Status,
+		&p.OpenedAt,
+		&p.LastAccruedAt,
+		&p.ClosedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// Create inserts a new pocket
+func (r *Repository) Create(ctx context.Context, p *Pocket) error {
+	query := `
+		INSERT INTO savings_pockets (
+			id, user_id, wallet_id, currency, principal, apr_bps,
+			accrued_interest, accrued_days, status, opened_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`
+
+	_, err := r.db.Exec(
+		ctx,
+		query,
+		p.ID,
+		p.UserID,
+		p.WalletID,
+		p.Currency,
+		p.Principal,
+		p.APRBps,
+		p.AccruedInterest,
+		p.AccruedDays,
+		p.Status,
+		p.OpenedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create savings pocket: %w", err)
+	}
+
+	return nil
+}
+
+// GetByID retrieves a pocket, returning nil when it does not exist
+func (r *Repository) GetByID(ctx context.Context, id uuid.UUID) (*Pocket, error) {
+	query := `SELECT ` + pocketColumns + ` FROM savings_pockets WHERE id = $1`
+
+	pocket, err := scanPocket(r.db.QueryRow(ctx, query, id))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get savings pocket: %w", err)
+	}
+
+	return pocket, nil
+}
+
+// GetByUserID lists a user's pockets, newest first
+func (r *Repository) GetByUserID(ctx context.Context, userID uuid.UUID) ([]*Pocket, error) {
+	query := `SELECT ` + pocketColumns + ` FROM savings_pockets WHERE user_id = $1 ORDER BY opened_at DESC`
+	return r.list(ctx, query, userID)
+}
+
+// GetOpen lists every open pocket for the accrual job
+func (r *Repository) GetOpen(ctx context.Context) ([]*Pocket, error) {
+	query := `SELECT ` + pocketColumns + ` FROM savings_pockets WHERE status = 'OPEN' ORDER BY opened_at`
+	return r.list(ctx, query)
+}
+
+// list runs a pocket query and scans every row
+func (r *Repository) list(ctx context.Context, query string, args ...any) ([]*Pocket, error) {
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list savings pockets: %w", err)
+	}
+	defer rows.Close()
+
+	pockets := make([]*Pocket, 0)
+	for rows.Next() {
+		pocket, err := scanPocket(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan savings pocket: %w", err)
+		}
+		pockets = append(pockets, pocket)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating savings pockets: %w", err)
+	}
+
+	return pockets, nil
+}
+
+// RecordAccrual advances a pocket's accrual only if no other run has advanced it first
+func (r *Repository) RecordAccrual(ctx context.Context, id uuid.UUID, fromDays, toDays int64, accruedInterest float64, at time.Time) (bool, error) {
+	query := `
+		UPDATE savings_pockets
+		SET accrued_days = $1, accrued_interest = $2, last_accrued_at = $3
+		WHERE id = $4 AND accrued_days = $5 AND status = 'OPEN'
+	`
+
+	result, err := r.db.Exec(ctx, query, toDays, accruedInterest, at, id, fromDays)
+	if err != nil {
+		return false, fmt.Errorf("failed to record accrual: %w", err)
+	}
+
+	return result.RowsAffected() == 1, nil
+}
+
+// SetStatus moves a pocket between states, returning false if it was not in the expected state
+func (r *Repository) SetStatus(ctx context.Context, id uuid.UUID, from, to PocketStatus, closedAt *time.Time) (bool, error) {
+	query := `
+		UPDATE savings_pockets
+		SET status = $1, closed_at = $2
+		WHERE id = $3 AND status = $4
+	`
+
+	result, err := r.db.Exec(ctx, query, to, closedAt, id, from)
+	if err != nil {
+		return false, fmt.Errorf("failed to update savings pocket status: %w", err)
+	}
+
+	return result.RowsAffected() == 1, nil
+}
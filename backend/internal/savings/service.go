@@ -0,0 +1,301 @@
+package savings
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/Bwise1/interstellar/internal/transactions"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrPocketNotFound      = errors.New("savings pocket not found")
+	ErrPocketClosed        = errors.New("savings pocket is closed")
+	ErrUnsupportedCurrency = errors.New("savings not offered for this currency")
+	ErrInvalidAmount       = errors.New("invalid amount")
+)
+
+const (
+	// unitsPerAmount matches the NUMERIC(20, 8) precision used for balances
+	unitsPerAmount = 100_000_000
+	basisPoints    = 10_000
+	daysPerYear    = 365
+)
+
+// Clock supplies the current time so accrual can be driven by a fixed clock in tests
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+// SystemClock is the wall-clock Clock used in production
+var SystemClock Clock = systemClock{}
+
+// Ledger moves funds between a wallet balance and savings, recording a transaction for each movement
+type Ledger interface {
+	ProcessSavingsMovement(ctx context.Context, userID uuid.UUID, txType transactions.TransactionType, currency string, amount float64) (*transactions.Transaction, error)
+}
+
+// Service handles business logic for savings pockets
+type Service struct {
+	repo   *Repository
+	ledger Ledger
+	aprBps map[string]int64
+	clock  Clock
+}
+
+// NewService creates a new savings service. aprBps maps a currency to its APR in basis points.
+func NewService(repo *Repository, ledger Ledger, aprBps map[string]int64, clock Clock) *Service {
+	return &Service{
+		repo:   repo,
+		ledger: ledger,
+		aprBps: aprBps,
+		clock:  clock,
+	}
+}
+
+// GetRates lists the APR offered per currency
+func (s *Service) GetRates() []RateResponse {
+	rates := make([]RateResponse, 0, len(s.aprBps))
+	for currency, bps := range s.aprBps {
+		rates = append(rates, RateResponse{
+			Currency: currency,
+			APRBps:   bps,
+			APR:      float64(bps) / basisPoints,
+		})
+	}
+
+	sort.Slice(rates, func(i, j int) bool {
+		return rates[i].Currency < rates[j].Currency
+	})
+
+	return rates
+}
+
+// CreatePocket locks funds out of the user's wallet into a new pocket at the current APR
+func (s *Service) CreatePocket(ctx context.Context, userID uuid.UUID, req *CreatePocketRequest) (*Pocket, error) {
+	bps, ok := s.aprBps[req.Currency]
+	if !ok {
+		return nil, ErrUnsupportedCurrency
+	}
+	if req.Amount <= 0 {
+		return nil, ErrInvalidAmount
+	}
+
+	tx, err := s.ledger.ProcessSavingsMovement(ctx, userID, transactions.TransactionTypeSavingsLock, req.Currency, req.Amount)
+	if err != nil {
+		return nil, err
+	}
+
+	pocket := &Pocket{
+		ID:        uuid.New(),
+		UserID:    userID,
+		WalletID:  tx.WalletID,
+		Currency:  req.Currency,
+		Principal: req.Amount,
+		APRBps:    bps,
+		Status:    PocketStatusOpen,
+		OpenedAt:  s.clock.Now(),
+	}
+
+	if err := s.repo.Create(ctx, pocket); err != nil {
+		// Hand the locked funds back; the pocket never existed
+		if _, unlockErr := s.ledger.ProcessSavingsMovement(ctx, userID, transactions.TransactionTypeSavingsUnlock, req.Currency, req.Amount); unlockErr != nil {
+			slog.Error("failed to unlock funds for unsaved savings pocket", "user_id", userID, "currency", req.Currency, "amount", req.Amount, "error", unlockErr)
+		}
+		return nil, err
+	}
+
+	return pocket, nil
+}
+
+// GetPockets lists the user's pockets with interest accrued to date
+func (s *Service) GetPockets(ctx context.Context, userID uuid.UUID) ([]*Pocket, error) {
+	return s.repo.GetByUserID(ctx, userID)
+}
+
+// GetPocket retrieves one of the user's pockets
+func (s *Service) GetPocket(ctx context.Context, userID, pocketID uuid.UUID) (*Pocket, error) {
+	pocket, err := s.repo.GetByID(ctx, pocketID)
+	if err != nil {
+		return nil, err
+	}
+	if pocket == nil || pocket.UserID != userID {
+		return nil, ErrPocketNotFound
+	}
+	return pocket, nil
+}
+
+// ClosePocket credits any outstanding interest, then returns the principal to the wallet
+func (s *Service) ClosePocket(ctx context.Context, userID, pocketID uuid.UUID) (*Pocket, error) {
+	pocket, err := s.GetPocket(ctx, userID, pocketID)
+	if err != nil {
+		return nil, err
+	}
+	if pocket.Status != PocketStatusOpen {
+		return nil, ErrPocketClosed
+	}
+
+	if err := s.accruePocket(ctx, pocket); err != nil {
+		return nil, err
+	}
+
+	closedAt := s.clock.Now()
+	ok, err := s.repo.SetStatus(ctx, pocket.ID, PocketStatusOpen, PocketStatusClosed, &closedAt)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrPocketClosed
+	}
+
+	if _, err := s.ledger.ProcessSavingsMovement(ctx, userID, transactions.TransactionTypeSavingsUnlock, pocket.Currency, pocket.Principal); err != nil {
+		if _, revertErr := s.repo.SetStatus(ctx, pocket.ID, PocketStatusClosed, PocketStatusOpen, nil); revertErr != nil {
+			slog.Error("failed to reopen savings pocket", "pocket_id", pocket.ID, "error", revertErr)
+		}
+		return nil, err
+	}
+
+	pocket.Status = PocketStatusClosed
+	pocket.ClosedAt = &closedAt
+	return pocket, nil
+}
+
+// AccrueInterest credits every open pocket with the interest earned since its last accrual.
+// It is idempotent within a day, so it is safe to run more often than daily.
+func (s *Service) AccrueInterest(ctx context.Context) error {
+	pockets, err := s.repo.GetOpen(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, pocket := range pockets {
+		if err := s.accruePocket(ctx, pocket); err != nil {
+			slog.Error("failed to accrue savings interest", "pocket_id", pocket.ID, "error", err)
+		}
+	}
+
+	return nil
+}
+
+// RunAccrual runs AccrueInterest on each interval until ctx is cancelled
+func (s *Service) RunAccrual(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.AccrueInterest(ctx); err != nil {
+				slog.Error("savings accrual run failed", "error", err)
+			}
+		}
+	}
+}
+
+// accrual is the interest due on a pocket for the whole days elapsed since its last accrual
+type accrual struct {
+	at     time.Time
+	days   int64 // whole days accrued in total, including this accrual
+	earned int64 // total interest in 1e-8 units after days
+	credit int64 // interest in 1e-8 units to credit now
+}
+
+// accrualDue works out the interest owed on a pocket as of the clock's current time. It reports
+// false when no whole day has passed since the last accrual.
+func (s *Service) accrualDue(pocket *Pocket) (*accrual, bool) {
+	now := s.clock.Now()
+	days := daysElapsed(pocket.OpenedAt, now)
+	if days <= pocket.AccruedDays {
+		return nil, false
+	}
+
+	principal := toUnits(pocket.Principal)
+	earned := AccruedUnits(principal, pocket.APRBps, days)
+	return &accrual{
+		at:     now,
+		days:   days,
+		earned: earned,
+		credit: earned - AccruedUnits(principal, pocket.APRBps, pocket.AccruedDays),
+	}, true
+}
+
+// apply records an accrual on the pocket
+func (a *accrual) apply(pocket *Pocket) {
+	at := a.at
+	pocket.AccruedDays = a.days
+	pocket.AccruedInterest = fromUnits(a.earned)
+	pocket.LastAccruedAt = &at
+}
+
+// accruePocket credits the interest for the whole days elapsed since the pocket's last accrual
+func (s *Service) accruePocket(ctx context.Context, pocket *Pocket) error {
+	due, ok := s.accrualDue(pocket)
+	if !ok {
+		return nil
+	}
+
+	// Claim the accrual window first so concurrent runs cannot credit it twice
+	ok, err := s.repo.RecordAccrual(ctx, pocket.ID, pocket.AccruedDays, due.days, fromUnits(due.earned), due.at)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return nil
+	}
+
+	if due.credit > 0 {
+		_, err := s.ledger.ProcessSavingsMovement(ctx, pocket.UserID, transactions.TransactionTypeInterest, pocket.Currency, fromUnits(due.credit))
+		if err != nil {
+			previous := fromUnits(due.earned - due.credit)
+			if _, revertErr := s.repo.RecordAccrual(ctx, pocket.ID, due.days, pocket.AccruedDays, previous, due.at); revertErr != nil {
+				slog.Error("failed to revert savings accrual", "pocket_id", pocket.ID, "error", revertErr)
+			}
+			return fmt.Errorf("failed to credit interest: %w", err)
+		}
+	}
+
+	due.apply(pocket)
+	return nil
+}
+
+// AccruedUnits returns the simple interest earned on principal (in 1e-8 units) after the given
+// number of days, rounded down to a whole unit. Each day's credit is the difference between
+// consecutive totals, so the sum of daily credits never drifts from the exact total.
+func AccruedUnits(principal, aprBps, days int64) int64 {
+	interest := new(big.Int).Mul(big.NewInt(principal), big.NewInt(aprBps))
+	interest.Mul(interest, big.NewInt(days))
+	interest.Quo(interest, big.NewInt(basisPoints*daysPerYear))
+	return interest.Int64()
+}
+
+// daysElapsed counts the whole UTC calendar days between opening and now
+func daysElapsed(openedAt, now time.Time) int64 {
+	start := openedAt.UTC().Truncate(24 * time.Hour)
+	end := now.UTC().Truncate(24 * time.Hour)
+	if !end.After(start) {
+		return 0
+	}
+	return int64(end.Sub(start) / (24 * time.Hour))
+}
+
+// toUnits converts an amount to integer 1e-8 units
+func toUnits(amount float64) int64 {
+	return int64(math.Round(amount * unitsPerAmount))
+}
+
+// fromUnits converts integer 1e-8 units back to an amount
+func fromUnits(units int64) float64 {
+	return float64(units) / unitsPerAmount
+}
@@ -0,0 +1,117 @@
+package savings
+
+import (
+	"testing"
+	"time"
+)
+
+// fixedClock is a Clock the test moves by hand
+type fixedClock struct {
+	now time.Time
+}
+
+func (c *fixedClock) Now() time.Time { return c.now }
+
+func newTestPocket(openedAt time.Time, principal float64, aprBps int64) *Pocket {
+	return &Pocket{
+		Currency:  "USDx",
+		Principal: principal,
+		APRBps:    aprBps,
+		Status:    PocketStatusOpen,
+		OpenedAt:  openedAt,
+	}
+}
+
+func TestAccrualMultiDay(t *testing.T) {
+	opened := time.Date(2024, 1, 1, 15, 30, 0, 0, time.UTC)
+	clock := &fixedClock{now: opened}
+	s := &Service{clock: clock}
+	pocket := newTestPocket(opened, 3650, 400) // 0.4 a day at 4% APR
+
+	if _, ok := s.accrualDue(pocket); ok {
+		t.Fatal("accrual due on the day the pocket opened")
+	}
+
+	// Three calendar days later, even though less than 72h have passed
+	clock.now = time.Date(2024, 1, 4, 0, 0, 1, 0, time.UTC)
+	due, ok := s.accrualDue(pocket)
+	if !ok {
+		t.Fatal("expected accrual after three days")
+	}
+	if due.days != 3 {
+		t.Errorf("days = %d, want 3", due.days)
+	}
+	if want := toUnits(1.2); due.credit != want || due.earned != want {
+		t.Errorf("credit = %d, earned = %d, want %d", due.credit, due.earned, want)
+	}
+	due.apply(pocket)
+
+	clock.now = time.Date(2024, 1, 5, 9, 0, 0, 0, time.UTC)
+	due, ok = s.accrualDue(pocket)
+	if !ok {
+		t.Fatal("expected accrual on the next day")
+	}
+	if want := toUnits(0.4); due.credit != want {
+		t.Errorf("credit = %d, want %d", due.credit, want)
+	}
+	if want := toUnits(1.6); due.earned != want {
+		t.Errorf("earned = %d, want %d", due.earned, want)
+	}
+}
+
+func TestAccrualNotRepeatedWithinADay(t *testing.T) {
+	opened := time.Date(2024, 3, 10, 8, 0, 0, 0, time.UTC)
+	clock := &fixedClock{now: opened.Add(24 * time.Hour)}
+	s := &Service{clock: clock}
+	pocket := newTestPocket(opened, 1000, 500)
+
+	due, ok := s.accrualDue(pocket)
+	if !ok {
+		t.Fatal("expected accrual after one day")
+	}
+	due.apply(pocket)
+
+	for _, later := range []time.Duration{time.Minute, time.Hour, 15 * time.Hour} {
+		clock.now = opened.Add(24*time.Hour + later)
+		if due, ok := s.accrualDue(pocket); ok {
+			t.Errorf("accrued again %s later in the same day: %+v", later, due)
+		}
+	}
+}
+
+func TestAccrualRoundsDownToWholeUnits(t *testing.T) {
+	// 1 unit of principal at 3% earns 1e8*300/(10000*365) = 8219.178... units a day
+	if got := AccruedUnits(toUnits(1), 300, 1); got != 8219 {
+		t.Errorf("one day = %d units, want 8219", got)
+	}
+
+	// Principal too small to earn a whole unit in a day
+	if got := AccruedUnits(1, 400, 1); got != 0 {
+		t.Errorf("tiny principal earned %d units, want 0", got)
+	}
+
+	// Daily credits add up to the exact total, so rounding never drifts
+	opened := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := &fixedClock{}
+	s := &Service{clock: clock}
+	pocket := newTestPocket(opened, 123.45678901, 375)
+
+	var credited int64
+	for day := 1; day <= 400; day++ {
+		clock.now = opened.AddDate(0, 0, day)
+		due, ok := s.accrualDue(pocket)
+		if !ok {
+			t.Fatalf("day %d: expected accrual", day)
+		}
+		credited += due.credit
+		due.apply(pocket)
+	}
+
+	want := AccruedUnits(toUnits(123.45678901), 375, 400)
+	if credited != want {
+		t.Errorf("credited %d units over 400 days, want %d", credited, want)
+	}
+	if pocket.AccruedInterest != fromUnits(want) {
+		t.Errorf("accrued interest = %v, want %v", pocket.AccruedInterest, fromUnits(want))
+	}
+}
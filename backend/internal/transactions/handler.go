@@ -1,25 +1,40 @@
 package transactions
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 
+	"github.com/Bwise1/interstellar/internal/fxrates"
+	"github.com/Bwise1/interstellar/internal/users"
 	"github.com/Bwise1/interstellar/internal/utils"
+	"github.com/Bwise1/interstellar/internal/wallets"
 	"github.com/Bwise1/interstellar/pkg/response"
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 )
 
+// StepUpHeader carries the fresh TOTP code required for large transfers
+const StepUpHeader = "X-TOTP-Code"
+
+// StepUpVerifier checks a fresh second-factor code before a sensitive operation
+type StepUpVerifier interface {
+	VerifyStepUp(ctx context.Context, userID uuid.UUID, code string) error
+}
+
 // Handler handles HTTP requests for transactions
 type Handler struct {
 	service *Service
+	stepUp  StepUpVerifier
 }
 
 // NewHandler creates a new transaction handler
-func NewHandler(service *Service) *Handler {
+func NewHandler(service *Service, stepUp StepUpVerifier) *Handler {
 	return &Handler{
 		service: service,
+		stepUp:  stepUp,
 	}
 }
 
@@ -49,7 +64,7 @@ func (h *Handler) Deposit(w http.ResponseWriter, r *http.Request) {
 
 	tx, err := h.service.ProcessDeposit(r.Context(), userID, &req)
 	if err != nil {
-		response.Error(w, http.StatusInternalServerError, err.Error())
+		response.Error(w, statusForError(err), err.Error())
 		return
 	}
 
@@ -71,29 +86,63 @@ func (h *Handler) Swap(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Validate request
-	if req.FromCurrency == "" || req.ToCurrency == "" {
-		response.Error(w, http.StatusBadRequest, "FromCurrency and ToCurrency are required")
+	if msg := validateSwap(&req); msg != "" {
+		response.Error(w, http.StatusBadRequest, msg)
 		return
 	}
 
-	if req.FromCurrency == req.ToCurrency {
-		response.Error(w, http.StatusBadRequest, "Cannot swap same currency")
+	tx, err := h.service.ProcessSwap(r.Context(), userID, &req)
+	if err != nil {
+		response.Error(w, statusForError(err), err.Error())
 		return
 	}
 
-	if req.Amount <= 0 {
-		response.Error(w, http.StatusBadRequest, "Amount must be greater than 0")
+	response.Success(w, http.StatusCreated, "Swap successful", tx)
+}
+
+// POST /api/transactions/swap/preview
+func (h *Handler) SwapPreview(w http.ResponseWriter, r *http.Request) {
+	userID, _ := utils.GetUserIDFromContext(r.Context())
+	if userID == uuid.Nil {
+		response.Error(w, http.StatusUnauthorized, "Unauthorized")
 		return
 	}
 
-	tx, err := h.service.ProcessSwap(r.Context(), userID, &req)
+	var req SwapRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if msg := validateSwap(&req); msg != "" {
+		response.Error(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	preview, err := h.service.PreviewSwap(r.Context(), userID, &req)
 	if err != nil {
-		response.Error(w, http.StatusInternalServerError, err.Error())
+		response.Error(w, statusForError(err), err.Error())
 		return
 	}
 
-	response.Success(w, http.StatusCreated, "Swap successful", tx)
+	response.Success(w, http.StatusOK, "Swap preview generated", preview)
+}
+
+// validateSwap checks a swap request's shape, returning a message if it is malformed
+func validateSwap(req *SwapRequest) string {
+	if req.FromCurrency == "" || req.ToCurrency == "" {
+		return "FromCurrency and ToCurrency are required"
+	}
+	if req.FromCurrency == req.ToCurrency {
+		return "Cannot swap same currency"
+	}
+	if req.Amount <= 0 {
+		return "Amount must be greater than 0"
+	}
+	if req.MinRate != nil && *req.MinRate <= 0 {
+		return "Min rate must be greater than 0"
+	}
+	return ""
 }
 
 // GET /api/transactions?limit=10&offset=0
@@ -181,33 +230,96 @@ func (h *Handler) Transfer(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Validate request
-	if req.RecipientWalletAddress == "" {
-		response.Error(w, http.StatusBadRequest, "Recipient wallet address is required")
+	if msg := validateTransfer(&req); msg != "" {
+		response.Error(w, http.StatusBadRequest, msg)
 		return
 	}
 
-	if req.FromCurrency == "" {
-		response.Error(w, http.StatusBadRequest, "From currency is required")
+	// Large transfers need a fresh TOTP code
+	if h.service.RequiresStepUp(r.Context(), &req) {
+		if err := h.stepUp.VerifyStepUp(r.Context(), userID, r.Header.Get(StepUpHeader)); err != nil {
+			response.Error(w, statusForError(err), err.Error())
+			return
+		}
+	}
+
+	tx, err := h.service.ProcessTransfer(r.Context(), userID, &req)
+	if err != nil {
+		response.Error(w, statusForError(err), err.Error())
 		return
 	}
 
-	if req.Amount <= 0 {
-		response.Error(w, http.StatusBadRequest, "Amount must be greater than 0")
+	response.Success(w, http.StatusCreated, "Transfer successful", tx)
+}
+
+// POST /api/transactions/transfer/preview
+func (h *Handler) TransferPreview(w http.ResponseWriter, r *http.Request) {
+	userID, _ := utils.GetUserIDFromContext(r.Context())
+	if userID == uuid.Nil {
+		response.Error(w, http.StatusUnauthorized, "Unauthorized")
 		return
 	}
 
-	// If to_currency is specified, validate it's different from from_currency
-	if req.ToCurrency != nil && *req.ToCurrency != "" && *req.ToCurrency == req.FromCurrency {
-		response.Error(w, http.StatusBadRequest, "To currency must be different from from currency for conversion")
+	var req TransferRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request body")
 		return
 	}
 
-	tx, err := h.service.ProcessTransfer(r.Context(), userID, &req)
+	if msg := validateTransfer(&req); msg != "" {
+		response.Error(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	preview, err := h.service.PreviewTransfer(r.Context(), userID, &req)
 	if err != nil {
-		response.Error(w, http.StatusInternalServerError, err.Error())
+		response.Error(w, statusForError(err), err.Error())
 		return
 	}
 
-	response.Success(w, http.StatusCreated, "Transfer successful", tx)
+	response.Success(w, http.StatusOK, "Transfer preview generated", preview)
+}
+
+// validateTransfer checks a transfer request's shape, returning a message if it is malformed
+func validateTransfer(req *TransferRequest) string {
+	if req.RecipientWalletAddress == "" {
+		return "Recipient wallet address is required"
+	}
+	if req.FromCurrency == "" {
+		return "From currency is required"
+	}
+	if req.Amount <= 0 {
+		return "Amount must be greater than 0"
+	}
+	// If to_currency is specified, it must differ from from_currency
+	if req.ToCurrency != nil && *req.ToCurrency != "" && *req.ToCurrency == req.FromCurrency {
+		return "To currency must be different from from currency for conversion"
+	}
+	return ""
+}
+
+// statusForError maps service errors to HTTP status codes
+func statusForError(err error) int {
+	if errors.Is(err, wallets.ErrWalletFrozen) || errors.Is(err, wallets.ErrWalletClosed) {
+		return http.StatusForbidden
+	}
+	if errors.Is(err, wallets.ErrInsufficientFunds) {
+		return http.StatusBadRequest
+	}
+	if errors.Is(err, fxrates.ErrMarketUnavailable) {
+		return http.StatusServiceUnavailable
+	}
+	if errors.Is(err, ErrRateBelowLimit) {
+		return http.StatusConflict
+	}
+	if errors.Is(err, users.ErrTwoFactorRequired) || errors.Is(err, users.ErrStepUpCodeRequired) {
+		return http.StatusForbidden
+	}
+	if errors.Is(err, users.ErrInvalidCode) {
+		return http.StatusUnauthorized
+	}
+	if errors.Is(err, users.ErrTooManyCodeAttempts) {
+		return http.StatusTooManyRequests
+	}
+	return http.StatusInternalServerError
 }
@@ -14,6 +14,15 @@ const (
 	TransactionTypeSwap     TransactionType = "SWAP"
 	TransactionTypeTransfer TransactionType = "TRANSFER"
 	TransactionTypeWithdraw TransactionType = "WITHDRAW"
+
+	TransactionTypeSavingsLock   TransactionType = "SAVINGS_LOCK"   // wallet -> savings pocket
+	TransactionTypeSavingsUnlock TransactionType = "SAVINGS_UNLOCK" // savings pocket -> wallet
+	TransactionTypeInterest      TransactionType = "INTEREST"       // savings interest credited to wallet
+
+	TransactionTypeOrderReserve TransactionType = "ORDER_RESERVE" // wallet -> funds held by an open limit order
+	TransactionTypeOrderRelease TransactionType = "ORDER_RELEASE" // held funds -> wallet, or into the order's swap or trade
+
+	TransactionTypeTrade TransactionType = "TRADE" // order book fill settled against another user's order
 )
 
 // TransactionStatus represents the status of a transaction
@@ -53,6 +62,28 @@ type SwapRequest struct {
 	FromCurrency string  `json:"from_currency" validate:"required"`
 	ToCurrency   string  `json:"to_currency" validate:"required"`
 	Amount       float64 `json:"amount" validate:"required,gt=0"`
+
+	// MinRate rejects the swap with ErrRateBelowLimit if the rate has moved below it
+	MinRate *float64 `json:"min_rate,omitempty"`
+
+	// FromReserved is set by limit orders whose Amount is already held as an ORDER_RESERVE;
+	// the swap releases the reservation instead of debiting the balance
+	FromReserved bool `json:"-"`
+
+	// TransactionID, when set, is the ID the SWAP transaction is recorded under, so a limit order
+	// can find its swap after a crash
+	TransactionID uuid.UUID `json:"-"`
+}
+
+// TradeLeg is one user's side of an order book fill. The user pays GiveAmount out of funds
+// their order already reserved and receives GetAmount from the counterparty.
+type TradeLeg struct {
+	UserID       uuid.UUID
+	GiveCurrency string
+	GiveAmount   float64
+	Released     float64 // reservation consumed by the fill; anything above GiveAmount returns to the wallet
+	GetCurrency  string
+	GetAmount    float64
 }
 
 // TransferRequest represents a request to transfer funds
@@ -62,3 +93,32 @@ type TransferRequest struct {
 	Amount                 float64 `json:"amount" validate:"required,gt=0"`
 	ToCurrency             *string `json:"to_currency,omitempty"`
 }
+
+// Violation is a rule that would block a previewed swap or transfer
+type Violation struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+
+	err error // what execution would fail with
+}
+
+// Preview is a dry run of a swap or transfer. ToAmount is FromAmount less Fee, at ExchangeRate
+// less Spread; it and the rate are omitted when no rate is available.
+type Preview struct {
+	TransactionType        TransactionType `json:"transaction_type"`
+	RecipientWalletAddress string          `json:"recipient_wallet_address,omitempty"`
+	FromCurrency           string          `json:"from_currency"`
+	FromAmount             float64         `json:"from_amount"`
+	ToCurrency             string          `json:"to_currency"`
+	ToAmount               *float64        `json:"to_amount,omitempty"`
+	ExchangeRate           *float64        `json:"exchange_rate,omitempty"`
+	Fee                    float64         `json:"fee"`    // in FromCurrency; none is charged yet
+	Spread                 float64         `json:"spread"` // taken off the market rate; none is applied yet
+	FromBalanceBefore      float64         `json:"from_balance_before"`
+	FromBalanceAfter       float64         `json:"from_balance_after"`
+	Allowed                bool            `json:"allowed"`
+	Violations             []Violation     `json:"violations"`
+
+	// StepUpRequired means executing the transfer needs a fresh TOTP code in the X-TOTP-Code header
+	StepUpRequired bool `json:"step_up_required,omitempty"`
+}
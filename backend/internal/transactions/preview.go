@@ -0,0 +1,219 @@
+package transactions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Bwise1/interstellar/internal/fxrates"
+	"github.com/Bwise1/interstellar/internal/wallets"
+	"github.com/google/uuid"
+)
+
+// Violation codes reported by previews
+const (
+	ViolationWalletFrozen      = "WALLET_FROZEN"
+	ViolationWalletClosed      = "WALLET_CLOSED"
+	ViolationRecipientFrozen   = "RECIPIENT_WALLET_FROZEN"
+	ViolationRecipientClosed   = "RECIPIENT_WALLET_CLOSED"
+	ViolationRecipientNotFound = "RECIPIENT_NOT_FOUND"
+	ViolationSelfTransfer      = "SELF_TRANSFER"
+	ViolationInsufficientFunds = "INSUFFICIENT_FUNDS"
+	ViolationMarketUnavailable = "MARKET_UNAVAILABLE"
+	ViolationRateUnavailable   = "RATE_UNAVAILABLE"
+	ViolationRateBelowMinimum  = "RATE_BELOW_MINIMUM"
+)
+
+// plan is a validated and priced swap or transfer. Executing and previewing share it, so a
+// preview reports exactly what execution would do and every rule that would stop it.
+type plan struct {
+	wallet     *wallets.Wallet // debited
+	recipient  *wallets.Wallet // credited; the same wallet for a swap, nil if not found
+	toCurrency string
+	rate       *float64 // nil for a same-currency transfer or when no rate is available
+	toAmount   float64
+	violations []Violation
+}
+
+// block records a rule that stops the plan from executing
+func (p *plan) block(code string, err error) {
+	p.violations = append(p.violations, Violation{Code: code, Message: err.Error(), err: err})
+}
+
+// err is the error execution fails with: the first violation, matching the order checks run in
+func (p *plan) err() error {
+	if len(p.violations) == 0 {
+		return nil
+	}
+	return p.violations[0].err
+}
+
+// checkWallet blocks the plan if a wallet's lifecycle state forbids the movement
+func (p *plan) checkWallet(err error, frozenCode, closedCode string) {
+	if err == nil {
+		return
+	}
+	if errors.Is(err, wallets.ErrWalletFrozen) {
+		p.block(frozenCode, err)
+		return
+	}
+	p.block(closedCode, err)
+}
+
+// checkBalance blocks the plan if the wallet can't cover the debit
+func (p *plan) checkBalance(currency string, amount float64) {
+	if balance := p.wallet.GetBalance(currency); balance < amount {
+		p.block(ViolationInsufficientFunds, fmt.Errorf("insufficient balance: have %f, need %f", balance, amount))
+	}
+}
+
+// price fetches the rate the movement would execute at and the amount it would deliver
+func (s *Service) price(ctx context.Context, p *plan, from string, amount float64) {
+	rate, err := s.fxService.GetRate(ctx, from, p.toCurrency)
+	if err != nil {
+		code := ViolationRateUnavailable
+		if errors.Is(err, fxrates.ErrMarketUnavailable) {
+			code = ViolationMarketUnavailable
+		}
+		p.block(code, fmt.Errorf("failed to get exchange rate: %w", err))
+		return
+	}
+	if rate <= 0 {
+		p.block(ViolationRateUnavailable, fmt.Errorf("invalid exchange rate: %f", rate))
+		return
+	}
+
+	p.rate = &rate
+	p.toAmount = amount * rate
+}
+
+// planSwap runs a swap's validation and pricing without touching balances
+func (s *Service) planSwap(ctx context.Context, userID uuid.UUID, req *SwapRequest) (*plan, error) {
+	wallet, err := s.walletRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get wallet: %w", err)
+	}
+	if wallet == nil {
+		return nil, fmt.Errorf("wallet not found for user")
+	}
+
+	p := &plan{wallet: wallet, recipient: wallet, toCurrency: req.ToCurrency}
+
+	// A swap debits and credits the same wallet
+	if err := wallet.CanDebit(); err != nil {
+		p.checkWallet(err, ViolationWalletFrozen, ViolationWalletClosed)
+	} else {
+		p.checkWallet(wallet.CanCredit(), ViolationWalletFrozen, ViolationWalletClosed)
+	}
+
+	// Reserved funds are already out of the balance
+	if !req.FromReserved {
+		p.checkBalance(req.FromCurrency, req.Amount)
+	}
+
+	// The FX service resolves stablecoin codes
+	s.price(ctx, p, req.FromCurrency, req.Amount)
+
+	if p.rate != nil && req.MinRate != nil && *p.rate < *req.MinRate {
+		p.block(ViolationRateBelowMinimum, fmt.Errorf("%w: rate %f, minimum %f", ErrRateBelowLimit, *p.rate, *req.MinRate))
+	}
+
+	return p, nil
+}
+
+// planTransfer runs a transfer's validation and pricing without touching balances
+func (s *Service) planTransfer(ctx context.Context, senderUserID uuid.UUID, req *TransferRequest) (*plan, error) {
+	senderWallet, err := s.walletRepo.GetByUserID(ctx, senderUserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sender wallet: %w", err)
+	}
+	if senderWallet == nil {
+		return nil, fmt.Errorf("sender wallet not found")
+	}
+
+	recipientWallet, err := s.walletRepo.GetByAddress(ctx, req.RecipientWalletAddress)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get recipient wallet: %w", err)
+	}
+
+	// Default to the source currency if no target is given
+	toCurrency := req.FromCurrency
+	if req.ToCurrency != nil && *req.ToCurrency != "" {
+		toCurrency = *req.ToCurrency
+	}
+
+	p := &plan{wallet: senderWallet, recipient: recipientWallet, toCurrency: toCurrency}
+
+	switch {
+	case recipientWallet == nil:
+		p.block(ViolationRecipientNotFound, errors.New("recipient wallet not found"))
+	case senderWallet.ID == recipientWallet.ID:
+		p.block(ViolationSelfTransfer, errors.New("cannot transfer to your own wallet"))
+	}
+
+	// Enforce wallet lifecycle state on both sides
+	p.checkWallet(senderWallet.CanDebit(), ViolationWalletFrozen, ViolationWalletClosed)
+	if recipientWallet != nil {
+		if err := recipientWallet.CanCredit(); err != nil {
+			p.checkWallet(fmt.Errorf("recipient %w", err), ViolationRecipientFrozen, ViolationRecipientClosed)
+		}
+	}
+
+	p.checkBalance(req.FromCurrency, req.Amount)
+
+	if req.FromCurrency != toCurrency {
+		s.price(ctx, p, req.FromCurrency, req.Amount)
+	} else {
+		p.toAmount = req.Amount
+	}
+
+	return p, nil
+}
+
+// preview describes what executing the plan would do
+func (p *plan) preview(txType TransactionType, fromCurrency string, amount float64) *Preview {
+	before := p.wallet.GetBalance(fromCurrency)
+	preview := &Preview{
+		TransactionType:   txType,
+		FromCurrency:      fromCurrency,
+		FromAmount:        amount,
+		ToCurrency:        p.toCurrency,
+		ExchangeRate:      p.rate,
+		Fee:               0,
+		Spread:            0,
+		FromBalanceBefore: before,
+		FromBalanceAfter:  before - amount,
+		Allowed:           len(p.violations) == 0,
+		Violations:        p.violations,
+	}
+	if p.rate != nil || fromCurrency == p.toCurrency {
+		toAmount := p.toAmount
+		preview.ToAmount = &toAmount
+	}
+	if p.violations == nil {
+		preview.Violations = []Violation{}
+	}
+	return preview
+}
+
+// PreviewSwap runs a swap's full validation and pricing without executing it
+func (s *Service) PreviewSwap(ctx context.Context, userID uuid.UUID, req *SwapRequest) (*Preview, error) {
+	p, err := s.planSwap(ctx, userID, req)
+	if err != nil {
+		return nil, err
+	}
+	return p.preview(TransactionTypeSwap, req.FromCurrency, req.Amount), nil
+}
+
+// PreviewTransfer runs a transfer's full validation and pricing without executing it
+func (s *Service) PreviewTransfer(ctx context.Context, senderUserID uuid.UUID, req *TransferRequest) (*Preview, error) {
+	p, err := s.planTransfer(ctx, senderUserID, req)
+	if err != nil {
+		return nil, err
+	}
+
+	preview := p.preview(TransactionTypeTransfer, req.FromCurrency, req.Amount)
+	preview.RecipientWalletAddress = req.RecipientWalletAddress
+	preview.StepUpRequired = s.RequiresStepUp(ctx, req)
+	return preview, nil
+}
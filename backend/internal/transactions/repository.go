@@ -5,6 +5,8 @@ import (
 	"fmt"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -16,8 +18,22 @@ func NewRepository(db *pgxpool.Pool) *Repository {
 	return &Repository{db: db}
 }
 
+// execer runs a statement on the pool or inside a database transaction
+type execer interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
 // Create
 func (r *Repository) Create(ctx context.Context, tx *Transaction) error {
+	return insertTransaction(ctx, r.db, tx)
+}
+
+// CreateTx records a transaction as part of a database transaction
+func (r *Repository) CreateTx(ctx context.Context, dbTx pgx.Tx, tx *Transaction) error {
+	return insertTransaction(ctx, dbTx, tx)
+}
+
+func insertTransaction(ctx context.Context, db execer, tx *Transaction) error {
 	query := `
 		INSERT INTO transactions (
 			id, transaction_type, status, wallet_id, user_id,
@@ -26,7 +42,7 @@ func (r *Repository) Create(ctx context.Context, tx *Transaction) error {
 		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
 	`
 
-	_, err := r.db.Exec(
+	_, err := db.Exec(
 		ctx,
 		query,
 		tx.ID,
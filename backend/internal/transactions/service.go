@@ -2,57 +2,71 @@ package transactions
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/Bwise1/interstellar/internal/wallets"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 )
 
+// ErrRateBelowLimit is returned when a swap's rate is worse than the caller's MinRate
+var ErrRateBelowLimit = errors.New("exchange rate is below the requested minimum")
+
 // WalletRepository defines the interface for wallet operations
 type WalletRepository interface {
 	GetByUserID(ctx context.Context, userID uuid.UUID) (*wallets.Wallet, error)
 	GetByAddress(ctx context.Context, address string) (*wallets.Wallet, error)
-	UpdateBalances(ctx context.Context, wallet *wallets.Wallet) error
+	AdjustBalancesWith(ctx context.Context, record func(tx pgx.Tx) error, adjustments ...wallets.BalanceAdjustment) error
 }
 
 // FXRateService defines the interface for FX rate operations
 type FXRateService interface {
-	GetRate(from, to string) (float64, error)
+	GetRate(ctx context.Context, from, to string) (float64, error)
 }
 
-// mapToRealCurrency maps stablecoin codes to their real currency equivalents
-func mapToRealCurrency(currency string) string {
-	mapping := map[string]string{
-		"cNGN": "NGN",
-		"cXAF": "XAF",
-		"USDx": "USD",
-		"EURx": "EUR",
-		"cGHS": "GHS",
-		"cKES": "KES",
-	}
-	if realCurrency, exists := mapping[currency]; exists {
-		return realCurrency
-	}
-	return currency // Return as-is if not in mapping
-}
+// stepUpCurrency is the currency transfers are valued in against the step-up threshold
+const stepUpCurrency = "USD"
 
 // Service handles business logic for transactions
 type Service struct {
 	repo       *Repository
 	walletRepo WalletRepository
 	fxService  FXRateService
+
+	// stepUpAmount is the USD value above which a transfer needs a fresh TOTP code; 0 disables it
+	stepUpAmount float64
 }
 
 // NewService creates a new transaction service
-func NewService(repo *Repository, walletRepo WalletRepository, fxService FXRateService) *Service {
+func NewService(repo *Repository, walletRepo WalletRepository, fxService FXRateService, stepUpAmount float64) *Service {
 	return &Service{
-		repo:       repo,
-		walletRepo: walletRepo,
-		fxService:  fxService,
+		repo:         repo,
+		walletRepo:   walletRepo,
+		fxService:    fxService,
+		stepUpAmount: stepUpAmount,
 	}
 }
 
+// RequiresStepUp reports whether a transfer is large enough to need a fresh TOTP code. If the
+// transfer can't be valued it is treated as large.
+func (s *Service) RequiresStepUp(ctx context.Context, req *TransferRequest) bool {
+	if s.stepUpAmount <= 0 {
+		return false
+	}
+
+	value := req.Amount
+	if req.FromCurrency != stepUpCurrency {
+		rate, err := s.fxService.GetRate(ctx, req.FromCurrency, stepUpCurrency)
+		if err != nil || rate <= 0 {
+			return true
+		}
+		value *= rate
+	}
+	return value > s.stepUpAmount
+}
+
 // ProcessDeposit
 func (s *Service) ProcessDeposit(ctx context.Context, userID uuid.UUID, req *DepositRequest) (*Transaction, error) {
 	wallet, err := s.walletRepo.GetByUserID(ctx, userID)
@@ -63,13 +77,8 @@ func (s *Service) ProcessDeposit(ctx context.Context, userID uuid.UUID, req *Dep
 		return nil, fmt.Errorf("wallet not found for user")
 	}
 
-	currentBalance := wallet.GetBalance(req.Currency)
-	newBalance := currentBalance + req.Amount
-	wallet.SetBalance(req.Currency, newBalance)
-	wallet.SetUpdatedAt(time.Now())
-
-	if err := s.walletRepo.UpdateBalances(ctx, wallet); err != nil {
-		return nil, fmt.Errorf("failed to update wallet balance: %w", err)
+	if err := wallet.CanCredit(); err != nil {
+		return nil, err
 	}
 
 	tx := &Transaction{
@@ -84,8 +93,10 @@ func (s *Service) ProcessDeposit(ctx context.Context, userID uuid.UUID, req *Dep
 		UpdatedAt:       time.Now(),
 	}
 
-	if err := s.repo.Create(ctx, tx); err != nil {
-		return nil, fmt.Errorf("failed to create transaction record: %w", err)
+	if err := s.applyAndRecord(ctx, []wallets.BalanceAdjustment{
+		{WalletID: wallet.ID, Currency: req.Currency, Delta: req.Amount},
+	}, tx); err != nil {
+		return nil, err
 	}
 
 	return tx, nil
@@ -93,55 +104,55 @@ func (s *Service) ProcessDeposit(ctx context.Context, userID uuid.UUID, req *Dep
 
 // ProcessSwap handles swapping between currencies
 func (s *Service) ProcessSwap(ctx context.Context, userID uuid.UUID, req *SwapRequest) (*Transaction, error) {
-	// Get user's wallet
-	wallet, err := s.walletRepo.GetByUserID(ctx, userID)
+	// Validate and price the swap; any violation blocks it
+	p, err := s.planSwap(ctx, userID, req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get wallet: %w", err)
-	}
-	if wallet == nil {
-		return nil, fmt.Errorf("wallet not found for user")
+		return nil, err
 	}
-
-	// Check if user has sufficient balance
-	currentBalance := wallet.GetBalance(req.FromCurrency)
-	if currentBalance < req.Amount {
-		return nil, fmt.Errorf("insufficient balance: have %f, need %f", currentBalance, req.Amount)
+	if err := p.err(); err != nil {
+		return nil, err
 	}
-
-	// Map stablecoin codes to real currency codes for FX service
-	fromCurrency := mapToRealCurrency(req.FromCurrency)
-	toCurrency := mapToRealCurrency(req.ToCurrency)
-
-	// Get exchange rate between currencies
-	rate, err := s.fxService.GetRate(fromCurrency, toCurrency)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get exchange rate: %w", err)
+	wallet := p.wallet
+	rate := *p.rate
+	convertedAmount := p.toAmount
+
+	// Update balances atomically with the records; the database rejects an overdraft. Reserved
+	// funds are released straight into the swap, so the from balance is left as it is.
+	adjustments := []wallets.BalanceAdjustment{
+		{WalletID: wallet.ID, Currency: req.ToCurrency, Delta: convertedAmount},
 	}
-
-	if rate <= 0 {
-		return nil, fmt.Errorf("invalid exchange rate: %f", rate)
+	if !req.FromReserved {
+		adjustments = append(adjustments, wallets.BalanceAdjustment{WalletID: wallet.ID, Currency: req.FromCurrency, Delta: -req.Amount})
 	}
 
-	// Calculate converted amount
-	convertedAmount := req.Amount * rate
-
-	// Update balances
-	wallet.SetBalance(req.FromCurrency, currentBalance-req.Amount)
-	wallet.SetBalance(req.ToCurrency, wallet.GetBalance(req.ToCurrency)+convertedAmount)
-	wallet.SetUpdatedAt(time.Now())
-
-	// Save updated wallet
-	if err := s.walletRepo.UpdateBalances(ctx, wallet); err != nil {
-		return nil, fmt.Errorf("failed to update wallet balance: %w", err)
+	// Record the release first so balance history nets the reservation out before the swap debit
+	var records []*Transaction
+	if req.FromReserved {
+		records = append(records, &Transaction{
+			ID:              uuid.New(),
+			TransactionType: TransactionTypeOrderRelease,
+			Status:          TransactionStatusCompleted,
+			WalletID:        wallet.ID,
+			UserID:          userID,
+			FromCurrency:    req.FromCurrency,
+			FromAmount:      req.Amount,
+			CreatedAt:       time.Now(),
+			UpdatedAt:       time.Now(),
+		})
 	}
 
 	// Create transaction record
-	toCurrency = req.ToCurrency
+	toCurrency := req.ToCurrency
 	toAmount := convertedAmount
 	exchangeRate := rate
 
+	txID := req.TransactionID
+	if txID == uuid.Nil {
+		txID = uuid.New()
+	}
+
 	tx := &Transaction{
-		ID:              uuid.New(),
+		ID:              txID,
 		TransactionType: TransactionTypeSwap,
 		Status:          TransactionStatusCompleted,
 		WalletID:        wallet.ID,
@@ -155,109 +166,229 @@ func (s *Service) ProcessSwap(ctx context.Context, userID uuid.UUID, req *SwapRe
 		UpdatedAt:       time.Now(),
 	}
 
-	if err := s.repo.Create(ctx, tx); err != nil {
-		return nil, fmt.Errorf("failed to create transaction record: %w", err)
+	// A SWAP row exists exactly when the balances moved, which limit orders rely on to reconcile
+	if err := s.applyAndRecord(ctx, adjustments, append(records, tx)...); err != nil {
+		return nil, err
 	}
 
 	return tx, nil
 }
 
-// GetTransactionsByWallet
-func (s *Service) GetTransactionsByWallet(ctx context.Context, walletID uuid.UUID, limit, offset int) ([]*Transaction, error) {
-	return s.repo.GetByWalletID(ctx, walletID, limit, offset)
+// applyAndRecord moves balances and writes the movement's transaction records in one database
+// transaction, so a balance never changes without its ledger rows
+func (s *Service) applyAndRecord(ctx context.Context, adjustments []wallets.BalanceAdjustment, records ...*Transaction) error {
+	err := s.walletRepo.AdjustBalancesWith(ctx, func(dbTx pgx.Tx) error {
+		for _, record := range records {
+			if err := s.repo.CreateTx(ctx, dbTx, record); err != nil {
+				return fmt.Errorf("failed to create transaction record: %w", err)
+			}
+		}
+		return nil
+	}, adjustments...)
+	if err != nil {
+		return fmt.Errorf("failed to update wallet balance: %w", err)
+	}
+	return nil
 }
 
-// GetTransactionsByUser
-func (s *Service) GetTransactionsByUser(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*Transaction, error) {
-	return s.repo.GetByUserID(ctx, userID, limit, offset)
+// ProcessSavingsMovement moves funds between a user's wallet balance and their savings.
+// SAVINGS_LOCK debits the wallet; SAVINGS_UNLOCK and INTEREST credit it.
+func (s *Service) ProcessSavingsMovement(ctx context.Context, userID uuid.UUID, txType TransactionType, currency string, amount float64) (*Transaction, error) {
+	switch txType {
+	case TransactionTypeSavingsLock, TransactionTypeSavingsUnlock, TransactionTypeInterest:
+		return s.processMovement(ctx, userID, txType, currency, amount)
+	default:
+		return nil, fmt.Errorf("unsupported savings transaction type: %s", txType)
+	}
 }
 
-// GetTransaction
-func (s *Service) GetTransaction(ctx context.Context, id uuid.UUID) (*Transaction, error) {
-	return s.repo.GetByID(ctx, id)
+// ProcessOrderMovement reserves funds for a limit order (ORDER_RESERVE debits the wallet) or
+// returns them when the order is cancelled or expires (ORDER_RELEASE credits it)
+func (s *Service) ProcessOrderMovement(ctx context.Context, userID uuid.UUID, txType TransactionType, currency string, amount float64) (*Transaction, error) {
+	switch txType {
+	case TransactionTypeOrderReserve, TransactionTypeOrderRelease:
+		return s.processMovement(ctx, userID, txType, currency, amount)
+	default:
+		return nil, fmt.Errorf("unsupported order transaction type: %s", txType)
+	}
 }
 
-// ProcessTransfer handles transferring funds between wallets
-func (s *Service) ProcessTransfer(ctx context.Context, senderUserID uuid.UUID, req *TransferRequest) (*Transaction, error) {
-	// Get sender's wallet
-	senderWallet, err := s.walletRepo.GetByUserID(ctx, senderUserID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get sender wallet: %w", err)
-	}
-	if senderWallet == nil {
-		return nil, fmt.Errorf("sender wallet not found")
+// processMovement moves funds between a wallet's balance and funds held elsewhere on its behalf
+func (s *Service) processMovement(ctx context.Context, userID uuid.UUID, txType TransactionType, currency string, amount float64) (*Transaction, error) {
+	if amount <= 0 {
+		return nil, fmt.Errorf("invalid amount: %f", amount)
 	}
 
-	// Get recipient's wallet by address
-	recipientWallet, err := s.walletRepo.GetByAddress(ctx, req.RecipientWalletAddress)
+	wallet, err := s.walletRepo.GetByUserID(ctx, userID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get recipient wallet: %w", err)
+		return nil, fmt.Errorf("failed to get wallet: %w", err)
 	}
-	if recipientWallet == nil {
-		return nil, fmt.Errorf("recipient wallet not found")
+	if wallet == nil {
+		return nil, fmt.Errorf("wallet not found for user")
 	}
 
-	// Prevent sending to self
-	if senderWallet.ID == recipientWallet.ID {
-		return nil, fmt.Errorf("cannot transfer to your own wallet")
+	delta := amount
+
+	switch txType {
+	case TransactionTypeSavingsLock, TransactionTypeOrderReserve:
+		if err := wallet.CanDebit(); err != nil {
+			return nil, err
+		}
+		if currentBalance := wallet.GetBalance(currency); currentBalance < amount {
+			return nil, fmt.Errorf("insufficient balance: have %f, need %f", currentBalance, amount)
+		}
+		delta = -amount
+	case TransactionTypeSavingsUnlock, TransactionTypeInterest, TransactionTypeOrderRelease:
+		if err := wallet.CanCredit(); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported transaction type: %s", txType)
 	}
 
-	// Check if sender has sufficient balance
-	senderBalance := senderWallet.GetBalance(req.FromCurrency)
-	if senderBalance < req.Amount {
-		return nil, fmt.Errorf("insufficient balance: have %f, need %f", senderBalance, req.Amount)
+	tx := &Transaction{
+		ID:              uuid.New(),
+		TransactionType: txType,
+		Status:          TransactionStatusCompleted,
+		WalletID:        wallet.ID,
+		UserID:          userID,
+		FromCurrency:    currency,
+		FromAmount:      amount,
+		CreatedAt:       time.Now(),
+		UpdatedAt:       time.Now(),
 	}
 
-	// Determine target currency (default to same as source if not specified)
-	toCurrency := req.FromCurrency
-	if req.ToCurrency != nil && *req.ToCurrency != "" {
-		toCurrency = *req.ToCurrency
+	if err := s.applyAndRecord(ctx, []wallets.BalanceAdjustment{
+		{WalletID: wallet.ID, Currency: currency, Delta: delta},
+	}, tx); err != nil {
+		return nil, err
 	}
 
-	var receivedAmount float64
-	var exchangeRate *float64
+	return tx, nil
+}
 
-	// If currencies are different, perform conversion
-	if req.FromCurrency != toCurrency {
-		// Map stablecoin codes to real currency codes for FX service
-		fromReal := mapToRealCurrency(req.FromCurrency)
-		toReal := mapToRealCurrency(toCurrency)
+// ProcessTrade settles an order book fill between two users. Both sides pay out of funds their
+// orders already reserved, so the wallets are only credited: with what they bought and with any
+// reservation the fill didn't use. Each side gets an ORDER_RELEASE for the reservation it
+// consumed followed by a TRADE, so balance history nets out like a reserved swap. The balance
+// moves, those records and whatever record writes are committed in one database transaction.
+func (s *Service) ProcessTrade(ctx context.Context, buyer, seller TradeLeg, record func(tx pgx.Tx) error) ([]*Transaction, error) {
+	legs := []TradeLeg{buyer, seller}
+	walletsByLeg := make([]*wallets.Wallet, len(legs))
+	var adjustments []wallets.BalanceAdjustment
+
+	for i, leg := range legs {
+		if leg.GiveAmount <= 0 || leg.GetAmount <= 0 || leg.Released < leg.GiveAmount {
+			return nil, fmt.Errorf("invalid trade leg for user %s", leg.UserID)
+		}
 
-		// Get exchange rate
-		rate, err := s.fxService.GetRate(fromReal, toReal)
+		wallet, err := s.walletRepo.GetByUserID(ctx, leg.UserID)
 		if err != nil {
-			return nil, fmt.Errorf("failed to get exchange rate: %w", err)
+			return nil, fmt.Errorf("failed to get wallet: %w", err)
 		}
+		if wallet == nil {
+			return nil, fmt.Errorf("wallet not found for user")
+		}
+		// Each side pays from its reservation and is paid by the other, so its wallet must allow both
+		if err := wallet.CanDebit(); err != nil {
+			return nil, err
+		}
+		if err := wallet.CanCredit(); err != nil {
+			return nil, err
+		}
+		walletsByLeg[i] = wallet
 
-		if rate <= 0 {
-			return nil, fmt.Errorf("invalid exchange rate: %f", rate)
+		adjustments = append(adjustments, wallets.BalanceAdjustment{WalletID: wallet.ID, Currency: leg.GetCurrency, Delta: leg.GetAmount})
+		if unused := leg.Released - leg.GiveAmount; unused > 0 {
+			adjustments = append(adjustments, wallets.BalanceAdjustment{WalletID: wallet.ID, Currency: leg.GiveCurrency, Delta: unused})
 		}
+	}
 
-		receivedAmount = req.Amount * rate
-		exchangeRate = &rate
-	} else {
-		// Same currency, no conversion needed
-		receivedAmount = req.Amount
+	var records, trades []*Transaction
+	for i, leg := range legs {
+		wallet := walletsByLeg[i]
+		counterparty := walletsByLeg[len(legs)-1-i].ID
+
+		toCurrency := leg.GetCurrency
+		toAmount := leg.GetAmount
+		exchangeRate := leg.GetAmount / leg.GiveAmount
+
+		trade := &Transaction{
+			ID:                uuid.New(),
+			TransactionType:   TransactionTypeTrade,
+			Status:            TransactionStatusCompleted,
+			WalletID:          wallet.ID,
+			UserID:            leg.UserID,
+			RecipientWalletID: &counterparty,
+			FromCurrency:      leg.GiveCurrency,
+			FromAmount:        leg.GiveAmount,
+			ToCurrency:        &toCurrency,
+			ToAmount:          &toAmount,
+			ExchangeRate:      &exchangeRate,
+			CreatedAt:         time.Now(),
+			UpdatedAt:         time.Now(),
+		}
+		records = append(records, &Transaction{
+			ID:              uuid.New(),
+			TransactionType: TransactionTypeOrderRelease,
+			Status:          TransactionStatusCompleted,
+			WalletID:        wallet.ID,
+			UserID:          leg.UserID,
+			FromCurrency:    leg.GiveCurrency,
+			FromAmount:      leg.Released,
+			CreatedAt:       time.Now(),
+			UpdatedAt:       time.Now(),
+		}, trade)
+		trades = append(trades, trade)
 	}
 
-	// Update sender's balance
-	senderWallet.SetBalance(req.FromCurrency, senderBalance-req.Amount)
-	senderWallet.SetUpdatedAt(time.Now())
+	err := s.walletRepo.AdjustBalancesWith(ctx, func(tx pgx.Tx) error {
+		for _, r := range records {
+			if err := s.repo.CreateTx(ctx, tx, r); err != nil {
+				return fmt.Errorf("failed to create transaction record: %w", err)
+			}
+		}
+		if record != nil {
+			return record(tx)
+		}
+		return nil
+	}, adjustments...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to settle trade: %w", err)
+	}
 
-	// Update recipient's balance
-	recipientBalance := recipientWallet.GetBalance(toCurrency)
-	recipientWallet.SetBalance(toCurrency, recipientBalance+receivedAmount)
-	recipientWallet.SetUpdatedAt(time.Now())
+	return trades, nil
+}
 
-	// Save both wallets
-	if err := s.walletRepo.UpdateBalances(ctx, senderWallet); err != nil {
-		return nil, fmt.Errorf("failed to update sender wallet: %w", err)
-	}
+// GetTransactionsByWallet
+func (s *Service) GetTransactionsByWallet(ctx context.Context, walletID uuid.UUID, limit, offset int) ([]*Transaction, error) {
+	return s.repo.GetByWalletID(ctx, walletID, limit, offset)
+}
+
+// GetTransactionsByUser
+func (s *Service) GetTransactionsByUser(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*Transaction, error) {
+	return s.repo.GetByUserID(ctx, userID, limit, offset)
+}
+
+// GetTransaction
+func (s *Service) GetTransaction(ctx context.Context, id uuid.UUID) (*Transaction, error) {
+	return s.repo.GetByID(ctx, id)
+}
 
-	if err := s.walletRepo.UpdateBalances(ctx, recipientWallet); err != nil {
-		// TODO: Rollback sender wallet update
-		return nil, fmt.Errorf("failed to update recipient wallet: %w", err)
+// ProcessTransfer handles transferring funds between wallets
+func (s *Service) ProcessTransfer(ctx context.Context, senderUserID uuid.UUID, req *TransferRequest) (*Transaction, error) {
+	// Validate and price the transfer; any violation blocks it
+	p, err := s.planTransfer(ctx, senderUserID, req)
+	if err != nil {
+		return nil, err
 	}
+	if err := p.err(); err != nil {
+		return nil, err
+	}
+	senderWallet, recipientWallet := p.wallet, p.recipient
+	toCurrency := p.toCurrency
+	receivedAmount := p.toAmount
+	exchangeRate := p.rate
 
 	// Create transaction record
 	toCurrencyPtr := &toCurrency
@@ -279,10 +410,56 @@ func (s *Service) ProcessTransfer(ctx context.Context, senderUserID uuid.UUID, r
 		UpdatedAt:         time.Now(),
 	}
 
-	if err := s.repo.Create(ctx, tx); err != nil {
-		// TODO: Rollback wallet updates
-		return nil, fmt.Errorf("failed to create transaction record: %w", err)
+	// Debit sender, credit recipient and record the transfer in one atomic update
+	if err := s.applyAndRecord(ctx, []wallets.BalanceAdjustment{
+		{WalletID: senderWallet.ID, Currency: req.FromCurrency, Delta: -req.Amount},
+		{WalletID: recipientWallet.ID, Currency: toCurrency, Delta: receivedAmount},
+	}, tx); err != nil {
+		return nil, err
 	}
 
 	return tx, nil
 }
+
+// SweepWallet moves every non-zero balance from one wallet into another as same-currency transfers,
+// all in one database transaction. It deliberately skips the source wallet's lifecycle checks so
+// frozen wallets can be emptied before closing.
+func (s *Service) SweepWallet(ctx context.Context, from, to *wallets.Wallet) error {
+	var adjustments []wallets.BalanceAdjustment
+	var records []*Transaction
+	for currency, amount := range from.GetBalances() {
+		if amount <= 0 {
+			continue
+		}
+
+		adjustments = append(adjustments,
+			wallets.BalanceAdjustment{WalletID: from.ID, Currency: currency, Delta: -amount},
+			wallets.BalanceAdjustment{WalletID: to.ID, Currency: currency, Delta: amount},
+		)
+
+		toCurrency := currency
+		toAmount := amount
+		records = append(records, &Transaction{
+			ID:                uuid.New(),
+			TransactionType:   TransactionTypeTransfer,
+			Status:            TransactionStatusCompleted,
+			WalletID:          from.ID,
+			UserID:            from.UserID,
+			RecipientWalletID: &to.ID,
+			FromCurrency:      currency,
+			FromAmount:        amount,
+			ToCurrency:        &toCurrency,
+			ToAmount:          &toAmount,
+			CreatedAt:         time.Now(),
+			UpdatedAt:         time.Now(),
+		})
+	}
+	if len(adjustments) == 0 {
+		return nil
+	}
+
+	if err := s.applyAndRecord(ctx, adjustments, records...); err != nil {
+		return fmt.Errorf("failed to sweep balances: %w", err)
+	}
+	return nil
+}
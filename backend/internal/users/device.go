@@ -0,0 +1,53 @@
+package users
+
+import "strings"
+
+// describeDevice turns a user agent into a short label such as "Chrome on macOS"
+func describeDevice(userAgent string) string {
+	if userAgent == "" {
+		return "Unknown device"
+	}
+
+	client := firstMatch(userAgent, [][2]string{
+		{"Edg/", "Edge"},
+		{"OPR/", "Opera"},
+		{"Firefox/", "Firefox"},
+		{"Chrome/", "Chrome"},
+		{"Safari/", "Safari"},
+		{"okhttp/", "Android app"},
+		{"CFNetwork/", "iOS app"},
+		{"Dart/", "Mobile app"},
+		{"PostmanRuntime/", "Postman"},
+		{"curl/", "curl"},
+	})
+	os := firstMatch(userAgent, [][2]string{
+		{"iPhone", "iOS"},
+		{"iPad", "iPadOS"},
+		{"Android", "Android"},
+		{"Windows", "Windows"},
+		{"Mac OS X", "macOS"},
+		{"CrOS", "ChromeOS"},
+		{"Linux", "Linux"},
+	})
+
+	switch {
+	case client != "" && os != "":
+		return client + " on " + os
+	case client != "":
+		return client
+	case os != "":
+		return os + " device"
+	default:
+		return "Unknown device"
+	}
+}
+
+// firstMatch returns the label of the first marker found in s
+func firstMatch(s string, markers [][2]string) string {
+	for _, m := range markers {
+		if strings.Contains(s, m[0]) {
+			return m[1]
+		}
+	}
+	return ""
+}
@@ -3,11 +3,15 @@ package users
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 
+	"github.com/Bwise1/interstellar/internal/middleware"
+	"github.com/Bwise1/interstellar/internal/rbac"
 	"github.com/Bwise1/interstellar/internal/utils"
 	"github.com/Bwise1/interstellar/pkg/response"
+	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 )
 
@@ -15,7 +19,6 @@ import (
 type Handler struct {
 	service       *Service
 	walletService WalletService
-	auditPassword string
 }
 
 // WalletService interface for wallet operations
@@ -24,11 +27,10 @@ type WalletService interface {
 }
 
 // NewHandler creates a new user handler
-func NewHandler(service *Service, walletService WalletService, auditPassword string) *Handler {
+func NewHandler(service *Service, walletService WalletService) *Handler {
 	return &Handler{
 		service:       service,
 		walletService: walletService,
-		auditPassword: auditPassword,
 	}
 }
 
@@ -69,7 +71,7 @@ func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, token, err := h.service.Login(r.Context(), &req)
+	loginResponse, err := h.service.Login(r.Context(), &req, clientInfo(r))
 	if err != nil {
 		if err == ErrInvalidCredentials {
 			response.Error(w, http.StatusUnauthorized, err.Error())
@@ -79,44 +81,350 @@ func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	loginResponse := LoginResponse{
-		Token: token,
-		User:  user.ToUserResponse(),
+	if loginResponse.TwoFactorRequired {
+		response.Success(w, http.StatusOK, "Two-factor code required", loginResponse)
+		return
 	}
 
 	response.Success(w, http.StatusOK, "Login successful", loginResponse)
 }
 
-// VerifyPassword verifies the audit logs access password
-// POST /api/users/verify-password
-func (h *Handler) VerifyPassword(w http.ResponseWriter, r *http.Request) {
-	// Get user ID from JWT context to ensure user is authenticated
+// Refresh exchanges a refresh token for a new access and refresh token
+// POST /api/auth/refresh
+func (h *Handler) Refresh(w http.ResponseWriter, r *http.Request) {
+	var req RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if req.RefreshToken == "" {
+		response.Error(w, http.StatusBadRequest, "Refresh token is required")
+		return
+	}
+
+	loginResponse, err := h.service.Refresh(r.Context(), req.RefreshToken, clientInfo(r))
+	if err != nil {
+		writeSessionError(w, err)
+		return
+	}
+
+	response.Success(w, http.StatusOK, "Token refreshed", loginResponse)
+}
+
+// Logout revokes the session the request's access token belongs to
+// POST /api/auth/logout
+func (h *Handler) Logout(w http.ResponseWriter, r *http.Request) {
+	userID, ok := utils.GetUserIDFromContext(r.Context())
+	if !ok || userID == uuid.Nil {
+		response.Error(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	sessionID, _ := utils.GetSessionIDFromContext(r.Context())
+
+	if err := h.service.Logout(r.Context(), userID, sessionID); err != nil {
+		writeSessionError(w, err)
+		return
+	}
+
+	response.Success(w, http.StatusOK, "Logged out", nil)
+}
+
+// LogoutAll revokes every session the user has, on every device
+// POST /api/auth/logout-all
+func (h *Handler) LogoutAll(w http.ResponseWriter, r *http.Request) {
+	userID, ok := utils.GetUserIDFromContext(r.Context())
+	if !ok || userID == uuid.Nil {
+		response.Error(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	if err := h.service.LogoutAll(r.Context(), userID); err != nil {
+		writeSessionError(w, err)
+		return
+	}
+
+	response.Success(w, http.StatusOK, "Logged out of all sessions", nil)
+}
+
+// GetSessions lists the user's active sessions
+// GET /api/users/sessions
+func (h *Handler) GetSessions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := utils.GetUserIDFromContext(r.Context())
+	if !ok || userID == uuid.Nil {
+		response.Error(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	currentID, _ := utils.GetSessionIDFromContext(r.Context())
+
+	sessions, err := h.service.GetSessions(r.Context(), userID, currentID)
+	if err != nil {
+		writeSessionError(w, err)
+		return
+	}
+
+	response.Success(w, http.StatusOK, "Sessions retrieved successfully", sessions)
+}
+
+// RevokeSession signs one of the user's sessions out
+// DELETE /api/users/sessions/{id}
+func (h *Handler) RevokeSession(w http.ResponseWriter, r *http.Request) {
 	userID, ok := utils.GetUserIDFromContext(r.Context())
 	if !ok || userID == uuid.Nil {
 		response.Error(w, http.StatusUnauthorized, "Unauthorized")
 		return
 	}
 
-	var req struct {
-		Password string `json:"password"`
+	sessionID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid session ID")
+		return
 	}
+
+	if err := h.service.RevokeSession(r.Context(), userID, sessionID); err != nil {
+		writeSessionError(w, err)
+		return
+	}
+
+	response.Success(w, http.StatusOK, "Session revoked", nil)
+}
+
+// clientInfo records where a login or refresh request came from
+func clientInfo(r *http.Request) *ClientInfo {
+	return &ClientInfo{
+		IPAddress: middleware.ClientIP(r),
+		UserAgent: r.UserAgent(),
+	}
+}
+
+// writeSessionError maps session errors to HTTP responses
+func writeSessionError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidRefreshToken), errors.Is(err, ErrRefreshTokenReused):
+		response.Error(w, http.StatusUnauthorized, err.Error())
+	case errors.Is(err, ErrSessionNotFound):
+		response.Error(w, http.StatusNotFound, err.Error())
+	default:
+		response.Error(w, http.StatusInternalServerError, "Failed to process session request")
+	}
+}
+
+// LoginTwoFactor completes a login with a TOTP or recovery code
+// POST /api/auth/login/2fa
+func (h *Handler) LoginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var req TwoFactorLoginRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		response.Error(w, http.StatusBadRequest, "Invalid request payload")
 		return
 	}
 
-	if req.Password == "" {
-		response.Error(w, http.StatusBadRequest, "Password is required")
+	if req.ChallengeToken == "" || req.Code == "" {
+		response.Error(w, http.StatusBadRequest, "Challenge token and code are required")
 		return
 	}
 
-	// Verify against generic audit password from env
-	if req.Password != h.auditPassword {
-		response.Error(w, http.StatusUnauthorized, "Invalid password")
+	loginResponse, err := h.service.LoginTwoFactor(r.Context(), &req, clientInfo(r))
+	if err != nil {
+		writeTwoFactorError(w, err)
 		return
 	}
 
-	response.Success(w, http.StatusOK, "Password verified successfully", map[string]bool{"verified": true})
+	response.Success(w, http.StatusOK, "Login successful", loginResponse)
+}
+
+// GetTwoFactorStatus reports whether 2FA is enabled
+// GET /api/users/2fa
+func (h *Handler) GetTwoFactorStatus(w http.ResponseWriter, r *http.Request) {
+	userID, ok := utils.GetUserIDFromContext(r.Context())
+	if !ok || userID == uuid.Nil {
+		response.Error(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	status, err := h.service.GetTwoFactorStatus(r.Context(), userID)
+	if err != nil {
+		writeTwoFactorError(w, err)
+		return
+	}
+
+	response.Success(w, http.StatusOK, "Two-factor status retrieved successfully", status)
+}
+
+// SetupTwoFactor starts 2FA enrolment and returns the secret and provisioning URI
+// POST /api/users/2fa/setup
+func (h *Handler) SetupTwoFactor(w http.ResponseWriter, r *http.Request) {
+	userID, ok := utils.GetUserIDFromContext(r.Context())
+	if !ok || userID == uuid.Nil {
+		response.Error(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	setup, err := h.service.SetupTwoFactor(r.Context(), userID)
+	if err != nil {
+		writeTwoFactorError(w, err)
+		return
+	}
+
+	response.Success(w, http.StatusOK, "Scan the provisioning URI, then confirm a code to enable two-factor authentication", setup)
+}
+
+// EnableTwoFactor confirms enrolment with a code and returns recovery codes
+// POST /api/users/2fa/enable
+func (h *Handler) EnableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	userID, req, ok := decodeCodeRequest(w, r)
+	if !ok {
+		return
+	}
+
+	codes, err := h.service.EnableTwoFactor(r.Context(), userID, req.Code)
+	if err != nil {
+		writeTwoFactorError(w, err)
+		return
+	}
+
+	response.Success(w, http.StatusOK, "Two-factor authentication enabled", codes)
+}
+
+// DisableTwoFactor turns 2FA off with a TOTP or recovery code
+// POST /api/users/2fa/disable
+func (h *Handler) DisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	userID, req, ok := decodeCodeRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.service.DisableTwoFactor(r.Context(), userID, req.Code); err != nil {
+		writeTwoFactorError(w, err)
+		return
+	}
+
+	response.Success(w, http.StatusOK, "Two-factor authentication disabled", nil)
+}
+
+// RegenerateRecoveryCodes replaces recovery codes after checking a TOTP code
+// POST /api/users/2fa/recovery-codes
+func (h *Handler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	userID, req, ok := decodeCodeRequest(w, r)
+	if !ok {
+		return
+	}
+
+	codes, err := h.service.RegenerateRecoveryCodes(r.Context(), userID, req.Code)
+	if err != nil {
+		writeTwoFactorError(w, err)
+		return
+	}
+
+	response.Success(w, http.StatusOK, "Recovery codes regenerated", codes)
+}
+
+// decodeCodeRequest reads the caller and a code from a 2FA request, writing the error if either is missing
+func decodeCodeRequest(w http.ResponseWriter, r *http.Request) (uuid.UUID, *TwoFactorCodeRequest, bool) {
+	userID, ok := utils.GetUserIDFromContext(r.Context())
+	if !ok || userID == uuid.Nil {
+		response.Error(w, http.StatusUnauthorized, "Unauthorized")
+		return uuid.Nil, nil, false
+	}
+
+	var req TwoFactorCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return uuid.Nil, nil, false
+	}
+	if req.Code == "" {
+		response.Error(w, http.StatusBadRequest, "Code is required")
+		return uuid.Nil, nil, false
+	}
+
+	return userID, &req, true
+}
+
+// writeTwoFactorError maps 2FA errors to HTTP responses
+func writeTwoFactorError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrUserNotFound):
+		response.Error(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, ErrInvalidCode), errors.Is(err, ErrInvalidChallenge):
+		response.Error(w, http.StatusUnauthorized, err.Error())
+	case errors.Is(err, ErrTooManyCodeAttempts):
+		response.Error(w, http.StatusTooManyRequests, err.Error())
+	case errors.Is(err, ErrTwoFactorEnabled), errors.Is(err, ErrTwoFactorNotEnabled), errors.Is(err, ErrTwoFactorNotSetUp):
+		response.Error(w, http.StatusConflict, err.Error())
+	default:
+		response.Error(w, http.StatusInternalServerError, "Failed to process two-factor request")
+	}
+}
+
+// GetRoleDefinitions lists the grantable roles and their permissions
+// GET /api/admin/roles
+func (h *Handler) GetRoleDefinitions(w http.ResponseWriter, r *http.Request) {
+	response.Success(w, http.StatusOK, "Roles retrieved", rbac.Roles())
+}
+
+// GetUserRoles lists the roles granted to a user
+// GET /api/admin/users/{id}/roles
+func (h *Handler) GetUserRoles(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	roles, err := h.service.GetRoles(r.Context(), userID)
+	if err != nil {
+		writeRoleError(w, err)
+		return
+	}
+
+	response.Success(w, http.StatusOK, "Roles retrieved", roles)
+}
+
+// SetUserRoles replaces the roles granted to a user
+// PUT /api/admin/users/{id}/roles
+func (h *Handler) SetUserRoles(w http.ResponseWriter, r *http.Request) {
+	actorID, ok := utils.GetUserIDFromContext(r.Context())
+	if !ok || actorID == uuid.Nil {
+		response.Error(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	userID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	var req RolesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if req.Roles == nil {
+		response.Error(w, http.StatusBadRequest, "Roles are required")
+		return
+	}
+
+	roles, err := h.service.SetRoles(r.Context(), actorID, userID, req.Roles)
+	if err != nil {
+		writeRoleError(w, err)
+		return
+	}
+
+	response.Success(w, http.StatusOK, "Roles updated", roles)
+}
+
+// writeRoleError maps role management errors to HTTP responses
+func writeRoleError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrUserNotFound):
+		response.Error(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, ErrInvalidRole):
+		response.Error(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, ErrLastAdmin):
+		response.Error(w, http.StatusConflict, err.Error())
+	default:
+		response.Error(w, http.StatusInternalServerError, "Failed to process role request")
+	}
 }
 
 // GetProfile gets the current user's profile
@@ -184,4 +492,3 @@ func (h *Handler) VerifyPassword(w http.ResponseWriter, r *http.Request) {
 
 // 	respondWithJSON(w, http.StatusOK, map[string]string{"message": "Account deleted successfully"})
 // }
-
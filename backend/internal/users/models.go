@@ -3,6 +3,7 @@ package users
 import (
 	"time"
 
+	"github.com/Bwise1/interstellar/internal/rbac"
 	"github.com/google/uuid"
 )
 
@@ -14,6 +15,14 @@ type User struct {
 	CreatedAt time.Time  `json:"created_at"`
 	UpdatedAt time.Time  `json:"updated_at"`
 	DeletedAt *time.Time `json:"deleted_at"`
+
+	// TOTPSecret is set by 2FA setup and only used once TOTPEnabled is confirmed with a code
+	TOTPSecret   *string `json:"-"`
+	TOTPEnabled  bool    `json:"two_factor_enabled"`
+	TOTPLastStep int64   `json:"-"` // last time step accepted, so codes can't be replayed
+
+	// Roles granted on top of the user role every account has
+	Roles []string `json:"roles"`
 }
 
 type RegisterRequest struct {
@@ -27,23 +36,157 @@ type LoginRequest struct {
 	Password string `json:"password" validate:"required"`
 }
 
+// LoginResponse carries either tokens or, for users with 2FA, a challenge to redeem with a code.
+// Token is a short-lived access token; RefreshToken is exchanged for new tokens before ExpiresAt.
 type LoginResponse struct {
-	Token string        `json:"token"`
-	User  *UserResponse `json:"user"`
+	Token             string        `json:"token,omitempty"`
+	RefreshToken      string        `json:"refresh_token,omitempty"`
+	ExpiresAt         *time.Time    `json:"expires_at,omitempty"`
+	User              *UserResponse `json:"user,omitempty"`
+	TwoFactorRequired bool          `json:"two_factor_required,omitempty"`
+	ChallengeToken    string        `json:"challenge_token,omitempty"`
+}
+
+// RefreshRequest exchanges a refresh token for a new access and refresh token
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+// Session is one login: a family of rotating refresh tokens and the access token last issued from it
+type Session struct {
+	ID              uuid.UUID  `json:"id"`
+	UserID          uuid.UUID  `json:"user_id"`
+	AccessTokenID   string     `json:"-"`
+	AccessExpiresAt time.Time  `json:"-"`
+	UserAgent       string     `json:"user_agent"`
+	IPAddress       string     `json:"ip_address"` // as of the last login or refresh
+	CreatedAt       time.Time  `json:"created_at"`
+	LastSeenAt      time.Time  `json:"last_seen_at"`
+	RevokedAt       *time.Time `json:"revoked_at,omitempty"`
+	RevokedReason   *string    `json:"revoked_reason,omitempty"`
+}
+
+// ClientInfo identifies where a login or refresh came from
+type ClientInfo struct {
+	IPAddress string
+	UserAgent string
+}
+
+// SessionResponse describes an active session for the sessions list
+type SessionResponse struct {
+	ID         uuid.UUID `json:"id"`
+	Device     string    `json:"device"`
+	UserAgent  string    `json:"user_agent"`
+	IPAddress  string    `json:"ip_address"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	Current    bool      `json:"current"` // the session the request was made with
+}
+
+func (s *Session) ToSessionResponse(currentID uuid.UUID) *SessionResponse {
+	return &SessionResponse{
+		ID:         s.ID,
+		Device:     describeDevice(s.UserAgent),
+		UserAgent:  s.UserAgent,
+		IPAddress:  s.IPAddress,
+		CreatedAt:  s.CreatedAt,
+		LastSeenAt: s.LastSeenAt,
+		Current:    s.ID == currentID,
+	}
+}
+
+// RefreshToken is one link in a session's rotation chain; only its hash is stored
+type RefreshToken struct {
+	ID        uuid.UUID
+	SessionID uuid.UUID
+	TokenHash string
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// RevokedToken is an access token denied until it would have expired anyway
+type RevokedToken struct {
+	ID        string
+	ExpiresAt time.Time
+}
+
+// TwoFactorLoginRequest completes a login with a TOTP or recovery code
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challenge_token" validate:"required"`
+	Code           string `json:"code" validate:"required"`
+}
+
+// TwoFactorCodeRequest confirms a 2FA change with a TOTP (or, where allowed, recovery) code
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+// TwoFactorStatus reports whether 2FA is on and how many recovery codes are left
+type TwoFactorStatus struct {
+	Enabled                bool `json:"enabled"`
+	RecoveryCodesRemaining int  `json:"recovery_codes_remaining"`
+}
+
+// TwoFactorSetup is the secret to load into an authenticator app; ProvisioningURI is what the QR code encodes
+type TwoFactorSetup struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+// RecoveryCodesResponse lists freshly generated recovery codes; they are only shown once
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// RolesRequest replaces the roles granted to a user
+type RolesRequest struct {
+	Roles []string `json:"roles"`
+}
+
+// RolesResponse lists the roles granted to a user and the permissions they carry
+type RolesResponse struct {
+	UserID      uuid.UUID         `json:"user_id"`
+	Email       string            `json:"email"`
+	Roles       []string          `json:"roles"`
+	Permissions []rbac.Permission `json:"permissions"`
 }
 
 type UserResponse struct {
-	ID        uuid.UUID `json:"id"`
-	Name      string    `json:"name"`
-	Email     string    `json:"email"`
-	CreatedAt time.Time `json:"created_at"`
+	ID               uuid.UUID         `json:"id"`
+	Name             string            `json:"name"`
+	Email            string            `json:"email"`
+	TwoFactorEnabled bool              `json:"two_factor_enabled"`
+	Roles            []string          `json:"roles"`
+	Permissions      []rbac.Permission `json:"permissions"`
+	CreatedAt        time.Time         `json:"created_at"`
 }
 
 func (u *User) ToUserResponse() *UserResponse {
 	return &UserResponse{
-		ID:        u.ID,
-		Name:      u.Name,
-		Email:     u.Email,
-		CreatedAt: u.CreatedAt,
+		ID:               u.ID,
+		Name:             u.Name,
+		Email:            u.Email,
+		TwoFactorEnabled: u.TOTPEnabled,
+		Roles:            u.roles(),
+		Permissions:      rbac.Permissions(u.Roles),
+		CreatedAt:        u.CreatedAt,
+	}
+}
+
+func (u *User) ToRolesResponse() *RolesResponse {
+	return &RolesResponse{
+		UserID:      u.ID,
+		Email:       u.Email,
+		Roles:       u.roles(),
+		Permissions: rbac.Permissions(u.Roles),
+	}
+}
+
+// roles lists the user's granted roles, never nil so it encodes as an empty list
+func (u *User) roles() []string {
+	if u.Roles == nil {
+		return []string{}
 	}
+	return u.Roles
 }
@@ -0,0 +1,33 @@
+package users
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// LoginNotification tells a user their account was signed in from a device it hasn't used before
+type LoginNotification struct {
+	UserID    uuid.UUID
+	Email     string
+	SessionID uuid.UUID
+	Device    string
+	IPAddress string
+	At        time.Time
+}
+
+// Notifier delivers security notifications to a user
+type Notifier interface {
+	NotifyNewDevice(ctx context.Context, n *LoginNotification) error
+}
+
+// LogNotifier writes notifications to the application log. It stands in until an email or push
+// channel is configured.
+type LogNotifier struct{}
+
+func (LogNotifier) NotifyNewDevice(ctx context.Context, n *LoginNotification) error {
+	slog.Info("new device login", "user_id", n.UserID, "session_id", n.SessionID, "device", n.Device, "ip", n.IPAddress)
+	return nil
+}
@@ -2,7 +2,9 @@ package users
 
 import (
 	"context"
+	"time"
 
+	"github.com/Bwise1/interstellar/internal/rbac"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -46,7 +48,9 @@ func (r *Repository) CreateUser(ctx context.Context, user *User) error {
 
 func (r *Repository) GetByID(ctx context.Context, id uuid.UUID) (*User, error) {
 	query := `
-		SELECT id, name, email, password, created_at, updated_at, deleted_at
+		SELECT id, name, email, password, created_at, updated_at, deleted_at,
+			totp_secret, totp_enabled, totp_last_step,
+			ARRAY(SELECT role FROM user_roles WHERE user_id = users.id ORDER BY role)
 		FROM users
 		WHERE id = $1 AND deleted_at IS NULL
 	`
@@ -60,6 +64,10 @@ func (r *Repository) GetByID(ctx context.Context, id uuid.UUID) (*User, error) {
 		&user.CreatedAt,
 		&user.UpdatedAt,
 		&user.DeletedAt,
+		&user.TOTPSecret,
+		&user.TOTPEnabled,
+		&user.TOTPLastStep,
+		&user.Roles,
 	)
 
 	if err != nil {
@@ -74,7 +82,9 @@ func (r *Repository) GetByID(ctx context.Context, id uuid.UUID) (*User, error) {
 
 func (r *Repository) GetByEmail(ctx context.Context, email string) (*User, error) {
 	query := `
-		SELECT id, name, email, password, created_at, updated_at, deleted_at
+		SELECT id, name, email, password, created_at, updated_at, deleted_at,
+			totp_secret, totp_enabled, totp_last_step,
+			ARRAY(SELECT role FROM user_roles WHERE user_id = users.id ORDER BY role)
 		FROM users
 		WHERE email = $1 AND deleted_at IS NULL
 	`
@@ -88,6 +98,10 @@ func (r *Repository) GetByEmail(ctx context.Context, email string) (*User, error
 		&user.CreatedAt,
 		&user.UpdatedAt,
 		&user.DeletedAt,
+		&user.TOTPSecret,
+		&user.TOTPEnabled,
+		&user.TOTPLastStep,
+		&user.Roles,
 	)
 	if err != nil {
 		if err == pgx.ErrNoRows {
@@ -136,3 +150,470 @@ func (r *Repository) EmailExists(ctx context.Context, email string) (bool, error
 
 	return exists, nil
 }
+
+// SetTOTPSecret stores a new, not yet confirmed TOTP secret. It returns false if 2FA is already enabled.
+func (r *Repository) SetTOTPSecret(ctx context.Context, userID uuid.UUID, secret string) (bool, error) {
+	query := `
+		UPDATE users
+		SET totp_secret = $2, updated_at = NOW()
+		WHERE id = $1 AND NOT totp_enabled AND deleted_at IS NULL
+	`
+	result, err := r.db.Exec(ctx, query, userID, secret)
+	if err != nil {
+		return false, err
+	}
+	return result.RowsAffected() == 1, nil
+}
+
+// EnableTOTP turns 2FA on, records the confirming time step and replaces the recovery codes.
+// It returns false if no setup is pending.
+func (r *Repository) EnableTOTP(ctx context.Context, userID uuid.UUID, step int64, codeHashes []string) (bool, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	result, err := tx.Exec(ctx, `
+		UPDATE users
+		SET totp_enabled = TRUE, totp_last_step = $2, updated_at = NOW()
+		WHERE id = $1 AND totp_secret IS NOT NULL AND NOT totp_enabled
+	`, userID, step)
+	if err != nil {
+		return false, err
+	}
+	if result.RowsAffected() == 0 {
+		return false, nil
+	}
+
+	if err := replaceRecoveryCodes(ctx, tx, userID, codeHashes); err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit(ctx)
+}
+
+// DisableTOTP turns 2FA off and drops the secret and recovery codes
+func (r *Repository) DisableTOTP(ctx context.Context, userID uuid.UUID) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE users
+		SET totp_secret = NULL, totp_enabled = FALSE, totp_last_step = 0, updated_at = NOW()
+		WHERE id = $1
+	`, userID); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM user_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// ReplaceRecoveryCodes invalidates a user's recovery codes and stores new ones
+func (r *Repository) ReplaceRecoveryCodes(ctx context.Context, userID uuid.UUID, codeHashes []string) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := replaceRecoveryCodes(ctx, tx, userID, codeHashes); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func replaceRecoveryCodes(ctx context.Context, tx pgx.Tx, userID uuid.UUID, codeHashes []string) error {
+	if _, err := tx.Exec(ctx, `DELETE FROM user_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+
+	for _, hash := range codeHashes {
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO user_recovery_codes (id, user_id, code_hash)
+			VALUES ($1, $2, $3)
+		`, uuid.New(), userID, hash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UseTOTPStep records a time step as used. It returns false if that step or a later one was
+// already used, so concurrent requests can't both redeem the same code.
+func (r *Repository) UseTOTPStep(ctx context.Context, userID uuid.UUID, step int64) (bool, error) {
+	query := `
+		UPDATE users
+		SET totp_last_step = $2
+		WHERE id = $1 AND totp_enabled AND totp_last_step < $2
+	`
+	result, err := r.db.Exec(ctx, query, userID, step)
+	if err != nil {
+		return false, err
+	}
+	return result.RowsAffected() == 1, nil
+}
+
+// UseRecoveryCode marks an unused recovery code as used. It returns false if there is none.
+func (r *Repository) UseRecoveryCode(ctx context.Context, userID uuid.UUID, codeHash string) (bool, error) {
+	query := `
+		UPDATE user_recovery_codes
+		SET used_at = NOW()
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+	`
+	result, err := r.db.Exec(ctx, query, userID, codeHash)
+	if err != nil {
+		return false, err
+	}
+	return result.RowsAffected() > 0, nil
+}
+
+// CountRecoveryCodes returns how many unused recovery codes a user has
+func (r *Repository) CountRecoveryCodes(ctx context.Context, userID uuid.UUID) (int, error) {
+	query := `
+		SELECT COUNT(*) FROM user_recovery_codes WHERE user_id = $1 AND used_at IS NULL
+	`
+	var count int
+	if err := r.db.QueryRow(ctx, query, userID).Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// CreateSession stores a new login session with its first refresh token
+func (r *Repository) CreateSession(ctx context.Context, session *Session, refresh *RefreshToken) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO user_sessions (id, user_id, access_jti, access_expires_at, user_agent, ip_address, created_at, last_seen_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`, session.ID, session.UserID, session.AccessTokenID, session.AccessExpiresAt,
+		session.UserAgent, session.IPAddress, session.CreatedAt, session.LastSeenAt); err != nil {
+		return err
+	}
+
+	if err := insertRefreshToken(ctx, tx, refresh); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func insertRefreshToken(ctx context.Context, tx pgx.Tx, refresh *RefreshToken) error {
+	_, err := tx.Exec(ctx, `
+		INSERT INTO refresh_tokens (id, session_id, token_hash, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`, refresh.ID, refresh.SessionID, refresh.TokenHash, refresh.ExpiresAt, refresh.CreatedAt)
+	return err
+}
+
+// GetRefreshToken finds a refresh token by hash, used or not
+func (r *Repository) GetRefreshToken(ctx context.Context, tokenHash string) (*RefreshToken, error) {
+	query := `
+		SELECT id, session_id, token_hash, expires_at, used_at, created_at
+		FROM refresh_tokens
+		WHERE token_hash = $1
+	`
+
+	var t RefreshToken
+	err := r.db.QueryRow(ctx, query, tokenHash).Scan(&t.ID, &t.SessionID, &t.TokenHash, &t.ExpiresAt, &t.UsedAt, &t.CreatedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &t, nil
+}
+
+const sessionColumns = `id, user_id, access_jti, access_expires_at, user_agent, ip_address,
+	created_at, last_seen_at, revoked_at, revoked_reason`
+
+func scanSession(row pgx.Row) (*Session, error) {
+	var s Session
+	err := row.Scan(
+		&s.ID, &s.UserID, &s.AccessTokenID, &s.AccessExpiresAt, &s.UserAgent, &s.IPAddress,
+		&s.CreatedAt, &s.LastSeenAt, &s.RevokedAt, &s.RevokedReason,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// GetSession retrieves a session by ID, including revoked ones
+func (r *Repository) GetSession(ctx context.Context, id uuid.UUID) (*Session, error) {
+	query := `SELECT ` + sessionColumns + ` FROM user_sessions WHERE id = $1`
+
+	s, err := scanSession(r.db.QueryRow(ctx, query, id))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return s, nil
+}
+
+// GetActiveSessions lists a user's sessions that haven't been revoked, most recently seen first
+func (r *Repository) GetActiveSessions(ctx context.Context, userID uuid.UUID) ([]*Session, error) {
+	query := `
+		SELECT ` + sessionColumns + `
+		FROM user_sessions
+		WHERE user_id = $1 AND revoked_at IS NULL
+		ORDER BY last_seen_at DESC
+	`
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []*Session
+	for rows.Next() {
+		s, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
+
+// CountSessions returns how many sessions a user has ever had, and how many of them came from userAgent
+func (r *Repository) CountSessions(ctx context.Context, userID uuid.UUID, userAgent string) (total, fromDevice int, err error) {
+	query := `
+		SELECT COUNT(*), COUNT(*) FILTER (WHERE user_agent = $2)
+		FROM user_sessions
+		WHERE user_id = $1
+	`
+	err = r.db.QueryRow(ctx, query, userID, userAgent).Scan(&total, &fromDevice)
+	return total, fromDevice, err
+}
+
+// RotateRefreshToken spends a refresh token, stores its successor and records the session's new
+// access token, denying the one it replaces. It returns false, changing nothing, if the token was
+// already spent or the session revoked.
+func (r *Repository) RotateRefreshToken(ctx context.Context, usedID uuid.UUID, next *RefreshToken, access *RevokedToken, client *ClientInfo, now time.Time) (*RevokedToken, bool, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	defer tx.Rollback(ctx)
+
+	result, err := tx.Exec(ctx, `
+		UPDATE refresh_tokens SET used_at = $2 WHERE id = $1 AND used_at IS NULL
+	`, usedID, now)
+	if err != nil {
+		return nil, false, err
+	}
+	if result.RowsAffected() == 0 {
+		return nil, false, nil
+	}
+
+	var previous RevokedToken
+	err = tx.QueryRow(ctx, `
+		UPDATE user_sessions s
+		SET access_jti = $2, access_expires_at = $3, last_seen_at = $4, ip_address = $5, user_agent = $6
+		FROM (SELECT access_jti, access_expires_at FROM user_sessions WHERE id = $1 FOR UPDATE) old
+		WHERE s.id = $1 AND s.revoked_at IS NULL
+		RETURNING old.access_jti, old.access_expires_at
+	`, next.SessionID, access.ID, access.ExpiresAt, now, client.IPAddress, client.UserAgent).Scan(&previous.ID, &previous.ExpiresAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+
+	if err := insertRefreshToken(ctx, tx, next); err != nil {
+		return nil, false, err
+	}
+	if err := denyTokens(ctx, tx, []RevokedToken{previous}, now); err != nil {
+		return nil, false, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, false, err
+	}
+	return &previous, true, nil
+}
+
+// RevokeSession revokes one of a user's sessions and denies its access token. It returns the
+// denied tokens, or ErrSessionNotFound if the user has no such active session.
+func (r *Repository) RevokeSession(ctx context.Context, userID, sessionID uuid.UUID, reason string, now time.Time) ([]RevokedToken, error) {
+	tokens, err := r.revokeSessions(ctx, userID, &sessionID, reason, now)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, ErrSessionNotFound
+	}
+	return tokens, nil
+}
+
+// RevokeUserSessions revokes every active session a user has and denies their access tokens
+func (r *Repository) RevokeUserSessions(ctx context.Context, userID uuid.UUID, reason string, now time.Time) ([]RevokedToken, error) {
+	return r.revokeSessions(ctx, userID, nil, reason, now)
+}
+
+// revokeSessions revokes a user's active sessions (just sessionID if given) and denies their access tokens
+func (r *Repository) revokeSessions(ctx context.Context, userID uuid.UUID, sessionID *uuid.UUID, reason string, now time.Time) ([]RevokedToken, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `
+		UPDATE user_sessions
+		SET revoked_at = $3, revoked_reason = $4
+		WHERE user_id = $1 AND ($2::uuid IS NULL OR id = $2) AND revoked_at IS NULL
+		RETURNING access_jti, access_expires_at
+	`, userID, sessionID, now, reason)
+	if err != nil {
+		return nil, err
+	}
+	tokens, err := pgx.CollectRows(rows, scanRevokedToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := denyTokens(ctx, tx, tokens, now); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+func scanRevokedToken(row pgx.CollectableRow) (RevokedToken, error) {
+	var t RevokedToken
+	err := row.Scan(&t.ID, &t.ExpiresAt)
+	return t, err
+}
+
+// denyTokens adds access tokens that haven't expired yet to the denylist
+func denyTokens(ctx context.Context, tx pgx.Tx, tokens []RevokedToken, now time.Time) error {
+	for _, t := range tokens {
+		if !t.ExpiresAt.After(now) {
+			continue
+		}
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO revoked_tokens (jti, expires_at, revoked_at)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (jti) DO NOTHING
+		`, t.ID, t.ExpiresAt, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetRevokedTokens returns denied access tokens that haven't expired yet
+func (r *Repository) GetRevokedTokens(ctx context.Context, now time.Time) ([]RevokedToken, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT jti, expires_at FROM revoked_tokens WHERE expires_at > $1
+	`, now)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, scanRevokedToken)
+}
+
+// DeleteExpiredRevokedTokens drops denylist entries for tokens that have expired on their own
+func (r *Repository) DeleteExpiredRevokedTokens(ctx context.Context, now time.Time) error {
+	_, err := r.db.Exec(ctx, `DELETE FROM revoked_tokens WHERE expires_at <= $1`, now)
+	return err
+}
+
+// SetRoles replaces the roles granted to a user and denies the access tokens of their active
+// sessions, so the change applies from their next refresh. It fails with ErrLastAdmin, changing
+// nothing, if it would leave no admins.
+func (r *Repository) SetRoles(ctx context.Context, userID uuid.UUID, roles []string, grantedBy uuid.UUID, now time.Time) ([]RevokedToken, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	// Lock the admin grants so concurrent changes can't each remove a different last admin
+	if _, err := tx.Exec(ctx, `SELECT 1 FROM user_roles WHERE role = $1 FOR UPDATE`, rbac.RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(ctx, `
+		DELETE FROM user_roles WHERE user_id = $1 AND NOT (role = ANY($2))
+	`, userID, roles); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO user_roles (user_id, role, granted_by, granted_at)
+		SELECT $1, role, $3, $4 FROM unnest($2::text[]) AS role
+		ON CONFLICT (user_id, role) DO NOTHING
+	`, userID, roles, grantedBy, now); err != nil {
+		return nil, err
+	}
+
+	var admins int
+	if err := tx.QueryRow(ctx, `SELECT COUNT(*) FROM user_roles WHERE role = $1`, rbac.RoleAdmin).Scan(&admins); err != nil {
+		return nil, err
+	}
+	if admins == 0 {
+		return nil, ErrLastAdmin
+	}
+
+	rows, err := tx.Query(ctx, `
+		SELECT access_jti, access_expires_at
+		FROM user_sessions
+		WHERE user_id = $1 AND revoked_at IS NULL
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	tokens, err := pgx.CollectRows(rows, scanRevokedToken)
+	if err != nil {
+		return nil, err
+	}
+	if err := denyTokens(ctx, tx, tokens, now); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// GrantRoleByEmail grants a role to the user with exactly this email. It returns how many users
+// have the email and grants nothing unless that is one.
+func (r *Repository) GrantRoleByEmail(ctx context.Context, email, role string, now time.Time) (int, error) {
+	var matches int
+	err := r.db.QueryRow(ctx, `
+		WITH target AS (
+			SELECT id FROM users WHERE email = $1 AND deleted_at IS NULL
+		), granted AS (
+			INSERT INTO user_roles (user_id, role, granted_at)
+			SELECT id, $2, $3 FROM target
+			WHERE (SELECT COUNT(*) FROM target) = 1
+			ON CONFLICT (user_id, role) DO NOTHING
+		)
+		SELECT COUNT(*) FROM target
+	`, email, role, now).Scan(&matches)
+	return matches, err
+}
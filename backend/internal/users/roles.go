@@ -0,0 +1,97 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Bwise1/interstellar/internal/rbac"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInvalidRole    = errors.New("invalid role")
+	ErrLastAdmin      = errors.New("cannot remove the last admin")
+	ErrAmbiguousEmail = errors.New("more than one user has this email")
+)
+
+// GetRoles lists the roles granted to a user
+func (s *Service) GetRoles(ctx context.Context, userID uuid.UUID) (*RolesResponse, error) {
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return user.ToRolesResponse(), nil
+}
+
+// SetRoles replaces the roles granted to a user. The user's current access tokens are revoked so
+// their next refresh picks up the change.
+func (s *Service) SetRoles(ctx context.Context, actorID, userID uuid.UUID, roles []string) (*RolesResponse, error) {
+	granted, err := normalizeRoles(roles)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens, err := s.repo.SetRoles(ctx, userID, granted, actorID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	s.denylist.add(tokens...)
+
+	slog.Info("user roles changed", "user_id", userID, "changed_by", actorID, "from", user.Roles, "to", granted)
+
+	user.Roles = granted
+	return user.ToRolesResponse(), nil
+}
+
+// BootstrapAdmins grants the admin role to existing users with the given emails, so a fresh
+// deployment has someone able to manage roles. Emails with no account yet are skipped; an email
+// shared by several accounts is refused rather than granting admin to all of them.
+func (s *Service) BootstrapAdmins(ctx context.Context, emails []string) error {
+	for _, email := range emails {
+		email = strings.TrimSpace(email)
+		if email == "" {
+			continue
+		}
+
+		matches, err := s.repo.GrantRoleByEmail(ctx, email, rbac.RoleAdmin, time.Now())
+		if err != nil {
+			return err
+		}
+		switch {
+		case matches == 0:
+			slog.Warn("no user found for admin email", "email", email)
+		case matches > 1:
+			return fmt.Errorf("%w: %s", ErrAmbiguousEmail, email)
+		}
+	}
+	return nil
+}
+
+// normalizeRoles validates requested roles, dropping duplicates and the implicit user role
+func normalizeRoles(roles []string) ([]string, error) {
+	seen := make(map[string]bool, len(roles))
+	granted := []string{}
+	for _, role := range roles {
+		role = strings.ToLower(strings.TrimSpace(role))
+		if role == rbac.RoleUser || seen[role] {
+			continue
+		}
+		if !rbac.IsGrantable(role) {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidRole, role)
+		}
+		seen[role] = true
+		granted = append(granted, role)
+	}
+	sort.Strings(granted)
+	return granted, nil
+}
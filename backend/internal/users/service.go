@@ -3,6 +3,7 @@ package users
 import (
 	"context"
 	"errors"
+	"strings"
 	"time"
 
 	"github.com/Bwise1/interstellar/internal/utils"
@@ -14,17 +15,36 @@ var (
 	ErrUserNotFound       = errors.New("user not found")
 	ErrInvalidCredentials = errors.New("invalid credentials")
 	ErrEmailExists        = errors.New("email already exists")
+
+	ErrTwoFactorEnabled    = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorNotEnabled = errors.New("two-factor authentication is not enabled")
+	ErrTwoFactorNotSetUp   = errors.New("two-factor setup has not been started")
+	ErrInvalidCode         = errors.New("invalid two-factor code")
+	ErrTooManyCodeAttempts = errors.New("too many invalid two-factor codes, try again later")
+	ErrInvalidChallenge    = errors.New("login challenge is invalid or has expired")
+	ErrTwoFactorRequired   = errors.New("two-factor authentication must be enabled for this operation")
+	ErrStepUpCodeRequired  = errors.New("a current two-factor code is required for this operation")
 )
 
 // Service handles business logic for users
 type Service struct {
-	repo *Repository
+	repo       *Repository
+	totpIssuer string        // shown as the account's issuer in authenticator apps
+	refreshTTL time.Duration // how long an unused refresh token stays valid
+	notifier   Notifier
+	codes      *codeLimiter
+	denylist   *denylist
 }
 
 // NewService creates a new user service
-func NewService(repo *Repository) *Service {
+func NewService(repo *Repository, totpIssuer string, refreshTTL time.Duration, notifier Notifier) *Service {
 	return &Service{
-		repo: repo,
+		repo:       repo,
+		totpIssuer: totpIssuer,
+		refreshTTL: refreshTTL,
+		notifier:   notifier,
+		codes:      newCodeLimiter(),
+		denylist:   newDenylist(),
 	}
 }
 
@@ -62,34 +82,263 @@ func (s *Service) Register(ctx context.Context, req *RegisterRequest) (*User, er
 	return user, nil
 }
 
-// Login authenticates a user and returns user with JWT token
-func (s *Service) Login(ctx context.Context, req *LoginRequest) (*User, string, error) {
+// Login authenticates a user by password. Users with 2FA get a challenge to complete with
+// LoginTwoFactor instead of a token.
+func (s *Service) Login(ctx context.Context, req *LoginRequest, client *ClientInfo) (*LoginResponse, error) {
 	// Get user by email
 	user, err := s.repo.GetByEmail(ctx, req.Email)
 	if err != nil {
-		return nil, "", ErrInvalidCredentials
+		return nil, ErrInvalidCredentials
 	}
 	if user == nil {
-		return nil, "", ErrInvalidCredentials
+		return nil, ErrInvalidCredentials
 	}
 
 	// Compare password
 	if err := s.comparePassword(user.Password, req.Password); err != nil {
-		return nil, "", ErrInvalidCredentials
+		return nil, ErrInvalidCredentials
+	}
+
+	if user.TOTPEnabled {
+		challenge, err := utils.GenerateChallengeToken(user.ID, user.Email)
+		if err != nil {
+			return nil, err
+		}
+		return &LoginResponse{TwoFactorRequired: true, ChallengeToken: challenge}, nil
+	}
+
+	return s.startSession(ctx, user, client)
+}
+
+// LoginTwoFactor completes a two-step login with a TOTP or recovery code
+func (s *Service) LoginTwoFactor(ctx context.Context, req *TwoFactorLoginRequest, client *ClientInfo) (*LoginResponse, error) {
+	claims, err := utils.ValidateChallengeToken(req.ChallengeToken)
+	if err != nil {
+		return nil, ErrInvalidChallenge
+	}
+
+	user, err := s.repo.GetByID(ctx, claims.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || !user.TOTPEnabled {
+		return nil, ErrInvalidChallenge
+	}
+
+	if err := s.verifyCode(ctx, user, req.Code, true); err != nil {
+		return nil, err
+	}
+
+	return s.startSession(ctx, user, client)
+}
+
+// GetTwoFactorStatus reports whether a user has 2FA and how many recovery codes are left
+func (s *Service) GetTwoFactorStatus(ctx context.Context, userID uuid.UUID) (*TwoFactorStatus, error) {
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &TwoFactorStatus{Enabled: user.TOTPEnabled}
+	if user.TOTPEnabled {
+		if status.RecoveryCodesRemaining, err = s.repo.CountRecoveryCodes(ctx, userID); err != nil {
+			return nil, err
+		}
+	}
+	return status, nil
+}
+
+// SetupTwoFactor generates a TOTP secret for the user to add to an authenticator app. 2FA
+// stays off until EnableTwoFactor confirms a code from it; calling again replaces the secret.
+func (s *Service) SetupTwoFactor(ctx context.Context, userID uuid.UUID) (*TwoFactorSetup, error) {
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabled {
+		return nil, ErrTwoFactorEnabled
+	}
+
+	secret, err := newTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	ok, err := s.repo.SetTOTPSecret(ctx, userID, secret)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrTwoFactorEnabled
+	}
+
+	return &TwoFactorSetup{
+		Secret:          secret,
+		ProvisioningURI: provisioningURI(s.totpIssuer, user.Email, secret),
+	}, nil
+}
+
+// EnableTwoFactor turns 2FA on once the user proves their app produces codes for the pending
+// secret, and returns the recovery codes
+func (s *Service) EnableTwoFactor(ctx context.Context, userID uuid.UUID, code string) (*RecoveryCodesResponse, error) {
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabled {
+		return nil, ErrTwoFactorEnabled
+	}
+	if user.TOTPSecret == nil {
+		return nil, ErrTwoFactorNotSetUp
+	}
+
+	now := time.Now()
+	if !s.codes.allow(userID, now) {
+		return nil, ErrTooManyCodeAttempts
+	}
+	step, ok := matchTOTP(*user.TOTPSecret, strings.TrimSpace(code), now, 0)
+	if !ok {
+		s.codes.fail(userID, now)
+		return nil, ErrInvalidCode
+	}
+	s.codes.reset(userID)
+
+	codes, hashes, err := s.newRecoveryCodes()
+	if err != nil {
+		return nil, err
 	}
 
-	// Generate JWT token
-	token, err := s.generateToken(user)
+	enabled, err := s.repo.EnableTOTP(ctx, userID, step, hashes)
 	if err != nil {
-		return nil, "", err
+		return nil, err
+	}
+	if !enabled {
+		return nil, ErrTwoFactorNotSetUp
+	}
+
+	return &RecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+// DisableTwoFactor turns 2FA off after checking a TOTP or recovery code
+func (s *Service) DisableTwoFactor(ctx context.Context, userID uuid.UUID, code string) error {
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !user.TOTPEnabled {
+		return ErrTwoFactorNotEnabled
+	}
+
+	if err := s.verifyCode(ctx, user, code, true); err != nil {
+		return err
+	}
+
+	return s.repo.DisableTOTP(ctx, userID)
+}
+
+// RegenerateRecoveryCodes replaces a user's recovery codes after checking a TOTP code
+func (s *Service) RegenerateRecoveryCodes(ctx context.Context, userID uuid.UUID, code string) (*RecoveryCodesResponse, error) {
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.TOTPEnabled {
+		return nil, ErrTwoFactorNotEnabled
+	}
+
+	if err := s.verifyCode(ctx, user, code, false); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := s.newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+		return nil, err
+	}
+
+	return &RecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+// VerifyStepUp checks a fresh TOTP code before a sensitive operation. Recovery codes are not
+// accepted, and users without 2FA can't pass.
+func (s *Service) VerifyStepUp(ctx context.Context, userID uuid.UUID, code string) error {
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !user.TOTPEnabled {
+		return ErrTwoFactorRequired
+	}
+	if strings.TrimSpace(code) == "" {
+		return ErrStepUpCodeRequired
 	}
 
-	return user, token, nil
+	return s.verifyCode(ctx, user, code, false)
 }
 
-// generateToken creates a JWT token for a user
-func (s *Service) generateToken(user *User) (string, error) {
-	return utils.GenerateToken(user.ID, user.Email)
+// verifyCode checks a TOTP code, or a recovery code if allowed, and consumes it
+func (s *Service) verifyCode(ctx context.Context, user *User, code string, allowRecovery bool) error {
+	now := time.Now()
+	if !s.codes.allow(user.ID, now) {
+		return ErrTooManyCodeAttempts
+	}
+
+	code = strings.TrimSpace(code)
+
+	if user.TOTPSecret != nil {
+		if step, ok := matchTOTP(*user.TOTPSecret, code, now, user.TOTPLastStep); ok {
+			used, err := s.repo.UseTOTPStep(ctx, user.ID, step)
+			if err != nil {
+				return err
+			}
+			if used {
+				s.codes.reset(user.ID)
+				return nil
+			}
+		}
+	}
+
+	if allowRecovery && len(code) != totpDigits {
+		used, err := s.repo.UseRecoveryCode(ctx, user.ID, hashRecoveryCode(code))
+		if err != nil {
+			return err
+		}
+		if used {
+			s.codes.reset(user.ID)
+			return nil
+		}
+	}
+
+	s.codes.fail(user.ID, now)
+	return ErrInvalidCode
+}
+
+// newRecoveryCodes generates recovery codes and the hashes to store for them
+func (s *Service) newRecoveryCodes() ([]string, []string, error) {
+	codes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = hashRecoveryCode(code)
+	}
+	return codes, hashes, nil
+}
+
+// getUser loads a user, returning ErrUserNotFound if there is none
+func (s *Service) getUser(ctx context.Context, userID uuid.UUID) (*User, error) {
+	user, err := s.repo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	return user, nil
 }
 
 // // GetByID retrieves a user by ID
@@ -0,0 +1,318 @@
+package users
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/Bwise1/interstellar/internal/utils"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("refresh token is invalid or has expired")
+	ErrRefreshTokenReused  = errors.New("refresh token was already used; the session has been revoked")
+	ErrSessionNotFound     = errors.New("session not found")
+)
+
+// Reasons recorded when a session is revoked
+const (
+	RevokedLogout     = "logout"
+	RevokedLogoutAll  = "logout_all"
+	RevokedByUser     = "revoked_by_user" // ended from the sessions list
+	RevokedTokenReuse = "refresh_token_reuse"
+)
+
+const (
+	refreshTokenBytes = 32
+
+	// denylistSyncPeriod bounds how long a revocation made on another instance takes to apply here
+	denylistSyncPeriod = 30 * time.Second
+)
+
+// startSession opens a login session and issues its first access and refresh tokens
+func (s *Service) startSession(ctx context.Context, user *User, client *ClientInfo) (*LoginResponse, error) {
+	now := time.Now()
+	session := &Session{
+		ID:         uuid.New(),
+		UserID:     user.ID,
+		UserAgent:  client.UserAgent,
+		IPAddress:  client.IPAddress,
+		CreatedAt:  now,
+		LastSeenAt: now,
+	}
+
+	// A device is known by its user agent; the first ever login isn't a new device worth flagging
+	total, fromDevice, err := s.repo.CountSessions(ctx, user.ID, client.UserAgent)
+	if err != nil {
+		return nil, err
+	}
+	newDevice := total > 0 && fromDevice == 0
+
+	access, err := utils.GenerateToken(user.ID, user.Email, session.ID, user.Roles)
+	if err != nil {
+		return nil, err
+	}
+	session.AccessTokenID = access.ID
+	session.AccessExpiresAt = access.ExpiresAt
+
+	refresh, refreshToken, err := s.newRefreshToken(session.ID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.CreateSession(ctx, session, refresh); err != nil {
+		return nil, err
+	}
+
+	if newDevice {
+		if err := s.notifier.NotifyNewDevice(ctx, &LoginNotification{
+			UserID:    user.ID,
+			Email:     user.Email,
+			SessionID: session.ID,
+			Device:    describeDevice(client.UserAgent),
+			IPAddress: client.IPAddress,
+			At:        now,
+		}); err != nil {
+			slog.Warn("failed to send new device notification", "user_id", user.ID, "error", err)
+		}
+	}
+
+	return &LoginResponse{
+		Token:        access.Token,
+		RefreshToken: refreshToken,
+		ExpiresAt:    &access.ExpiresAt,
+		User:         user.ToUserResponse(),
+	}, nil
+}
+
+// Refresh exchanges a refresh token for new tokens. Each refresh token works once; presenting
+// a spent one means it was copied, so the whole session is revoked.
+func (s *Service) Refresh(ctx context.Context, refreshToken string, client *ClientInfo) (*LoginResponse, error) {
+	token, err := s.repo.GetRefreshToken(ctx, hashRefreshToken(refreshToken))
+	if err != nil {
+		return nil, err
+	}
+	if token == nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	session, err := s.repo.GetSession(ctx, token.SessionID)
+	if err != nil {
+		return nil, err
+	}
+	if session == nil || session.RevokedAt != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	if token.UsedAt != nil {
+		return nil, s.revokeReusedSession(ctx, session)
+	}
+
+	now := time.Now()
+	if !now.Before(token.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	user, err := s.repo.GetByID(ctx, session.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	access, err := utils.GenerateToken(user.ID, user.Email, session.ID, user.Roles)
+	if err != nil {
+		return nil, err
+	}
+	next, nextToken, err := s.newRefreshToken(session.ID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	replaced, ok, err := s.repo.RotateRefreshToken(ctx, token.ID, next, &RevokedToken{ID: access.ID, ExpiresAt: access.ExpiresAt}, client, now)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		// Another request spent the token between the read and the rotation
+		return nil, s.revokeReusedSession(ctx, session)
+	}
+	s.denylist.add(*replaced)
+
+	return &LoginResponse{
+		Token:        access.Token,
+		RefreshToken: nextToken,
+		ExpiresAt:    &access.ExpiresAt,
+		User:         user.ToUserResponse(),
+	}, nil
+}
+
+// revokeReusedSession kills a session whose refresh token was presented twice
+func (s *Service) revokeReusedSession(ctx context.Context, session *Session) error {
+	slog.Warn("refresh token reuse detected; revoking session", "user_id", session.UserID, "session_id", session.ID)
+
+	tokens, err := s.repo.RevokeSession(ctx, session.UserID, session.ID, RevokedTokenReuse, time.Now())
+	if err != nil && !errors.Is(err, ErrSessionNotFound) {
+		return err
+	}
+	s.denylist.add(tokens...)
+	return ErrRefreshTokenReused
+}
+
+// Logout revokes one of a user's sessions and its access token
+func (s *Service) Logout(ctx context.Context, userID, sessionID uuid.UUID) error {
+	tokens, err := s.repo.RevokeSession(ctx, userID, sessionID, RevokedLogout, time.Now())
+	if err != nil {
+		return err
+	}
+	s.denylist.add(tokens...)
+	return nil
+}
+
+// GetSessions lists a user's active sessions, flagging currentID as the one in use
+func (s *Service) GetSessions(ctx context.Context, userID, currentID uuid.UUID) ([]*SessionResponse, error) {
+	sessions, err := s.repo.GetActiveSessions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]*SessionResponse, len(sessions))
+	for i, session := range sessions {
+		responses[i] = session.ToSessionResponse(currentID)
+	}
+	return responses, nil
+}
+
+// RevokeSession ends one of a user's sessions, signing that device out
+func (s *Service) RevokeSession(ctx context.Context, userID, sessionID uuid.UUID) error {
+	tokens, err := s.repo.RevokeSession(ctx, userID, sessionID, RevokedByUser, time.Now())
+	if err != nil {
+		return err
+	}
+	s.denylist.add(tokens...)
+	return nil
+}
+
+// LogoutAll revokes every session a user has
+func (s *Service) LogoutAll(ctx context.Context, userID uuid.UUID) error {
+	tokens, err := s.repo.RevokeUserSessions(ctx, userID, RevokedLogoutAll, time.Now())
+	if err != nil {
+		return err
+	}
+	s.denylist.add(tokens...)
+	return nil
+}
+
+// IsTokenRevoked reports whether an access token has been revoked before expiring
+func (s *Service) IsTokenRevoked(jti string) bool {
+	return s.denylist.contains(jti, time.Now())
+}
+
+// LoadDenylist loads revoked access tokens that haven't expired yet
+func (s *Service) LoadDenylist(ctx context.Context) error {
+	now := time.Now()
+	tokens, err := s.repo.GetRevokedTokens(ctx, now)
+	if err != nil {
+		return err
+	}
+	s.denylist.replace(tokens, now)
+	return nil
+}
+
+// RunDenylistSync reloads the denylist periodically, picking up revocations made by other
+// instances and pruning expired entries, until ctx is cancelled
+func (s *Service) RunDenylistSync(ctx context.Context) {
+	ticker := time.NewTicker(denylistSyncPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.repo.DeleteExpiredRevokedTokens(ctx, time.Now()); err != nil {
+				slog.Warn("failed to prune revoked tokens", "error", err)
+			}
+			if err := s.LoadDenylist(ctx); err != nil {
+				slog.Warn("failed to sync revoked tokens", "error", err)
+			}
+		}
+	}
+}
+
+// newRefreshToken generates a refresh token for a session, returning the record to store and
+// the token to hand to the client
+func (s *Service) newRefreshToken(sessionID uuid.UUID, now time.Time) (*RefreshToken, string, error) {
+	raw := make([]byte, refreshTokenBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+
+	return &RefreshToken{
+		ID:        uuid.New(),
+		SessionID: sessionID,
+		TokenHash: hashRefreshToken(token),
+		ExpiresAt: now.Add(s.refreshTTL),
+		CreatedAt: now,
+	}, token, nil
+}
+
+// hashRefreshToken hashes a refresh token for storage; tokens are random, so a fast hash is enough
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// denylist holds revoked access tokens in memory until they expire
+type denylist struct {
+	mu     sync.RWMutex
+	tokens map[string]time.Time
+}
+
+func newDenylist() *denylist {
+	return &denylist{tokens: make(map[string]time.Time)}
+}
+
+func (d *denylist) add(tokens ...RevokedToken) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, t := range tokens {
+		d.tokens[t.ID] = t.ExpiresAt
+	}
+}
+
+func (d *denylist) contains(jti string, now time.Time) bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	expiresAt, ok := d.tokens[jti]
+	return ok && now.Before(expiresAt)
+}
+
+// replace swaps in a fresh copy of the denylist, keeping unexpired local entries in case they
+// were added after the copy was read
+func (d *denylist) replace(tokens []RevokedToken, now time.Time) {
+	next := make(map[string]time.Time, len(tokens))
+	for _, t := range tokens {
+		next[t.ID] = t.ExpiresAt
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for jti, expiresAt := range d.tokens {
+		if now.Before(expiresAt) {
+			if _, ok := next[jti]; !ok {
+				next[jti] = expiresAt
+			}
+		}
+	}
+	d.tokens = next
+}
@@ -0,0 +1,37 @@
+package users
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDenylistReplaceKeepsUnexpiredLocalEntries(t *testing.T) {
+	now := time.Now()
+	d := newDenylist()
+	d.add(
+		RevokedToken{ID: "local", ExpiresAt: now.Add(time.Minute)},
+		RevokedToken{ID: "expired", ExpiresAt: now.Add(-time.Second)},
+		RevokedToken{ID: "shared", ExpiresAt: now.Add(time.Minute)},
+	)
+
+	d.replace([]RevokedToken{
+		{ID: "remote", ExpiresAt: now.Add(time.Minute)},
+		{ID: "shared", ExpiresAt: now.Add(time.Hour)},
+	}, now)
+
+	for _, jti := range []string{"local", "remote", "shared"} {
+		if !d.contains(jti, now) {
+			t.Errorf("%s missing after replace", jti)
+		}
+	}
+	if _, ok := d.tokens["expired"]; ok {
+		t.Error("expired local entry kept after replace")
+	}
+	// The loaded copy wins for tokens both sides know about
+	if got := d.tokens["shared"]; !got.Equal(now.Add(time.Hour)) {
+		t.Errorf("shared expiry = %v, want the loaded %v", got, now.Add(time.Hour))
+	}
+	if d.contains("remote", now.Add(2*time.Minute)) {
+		t.Error("token still denied after it expired")
+	}
+}
@@ -0,0 +1,179 @@
+package users
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TOTP parameters (RFC 6238 defaults, which every authenticator app supports)
+const (
+	totpDigits      = 6
+	totpPeriod      = 30 // seconds per time step
+	totpSkew        = 1  // steps accepted either side of now, for clock drift
+	totpSecretBytes = 20
+
+	recoveryCodeCount = 10
+
+	// maxCodeFailures wrong codes within codeFailureWindow lock a user out of 2FA checks
+	maxCodeFailures   = 5
+	codeFailureWindow = 5 * time.Minute
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// newTOTPSecret generates a random base32 TOTP secret
+func newTOTPSecret() (string, error) {
+	secret := make([]byte, totpSecretBytes)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return totpEncoding.EncodeToString(secret), nil
+}
+
+// totpCode computes the code for a base32 secret at a time step
+func totpCode(secret string, step int64) (string, error) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", err
+	}
+
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	// Dynamic truncation (RFC 4226 section 5.3)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < totpDigits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", totpDigits, value%mod), nil
+}
+
+// matchTOTP returns the time step a code is valid for at now, ignoring steps at or before
+// lastStep so a code can't be used twice
+func matchTOTP(secret, code string, now time.Time, lastStep int64) (int64, bool) {
+	if len(code) != totpDigits {
+		return 0, false
+	}
+
+	current := now.Unix() / totpPeriod
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		if step <= lastStep {
+			continue
+		}
+		expected, err := totpCode(secret, step)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// provisioningURI builds the otpauth:// URI authenticator apps read from a QR code
+func provisioningURI(issuer, account, secret string) string {
+	params := url.Values{}
+	params.Set("secret", secret)
+	params.Set("issuer", issuer)
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", fmt.Sprint(totpDigits))
+	params.Set("period", fmt.Sprint(totpPeriod))
+
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(account)
+
+	// Authenticator apps expect %20 rather than + for spaces
+	return "otpauth://totp/" + label + "?" + strings.ReplaceAll(params.Encode(), "+", "%20")
+}
+
+// newRecoveryCodes generates single-use recovery codes formatted as xxxxx-xxxxx
+func newRecoveryCodes() ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		raw := make([]byte, 7)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, err
+		}
+		encoded := strings.ToLower(totpEncoding.EncodeToString(raw))[:10]
+		codes[i] = encoded[:5] + "-" + encoded[5:]
+	}
+	return codes, nil
+}
+
+// hashRecoveryCode hashes a recovery code for storage, ignoring case and separators.
+// Codes carry 50 random bits, so a fast hash is enough.
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+// codeLimiter counts wrong second-factor codes per user so they can't be brute-forced
+type codeLimiter struct {
+	mu       sync.Mutex
+	failures map[uuid.UUID]*codeFailures
+}
+
+type codeFailures struct {
+	count int
+	since time.Time
+}
+
+func newCodeLimiter() *codeLimiter {
+	return &codeLimiter{failures: make(map[uuid.UUID]*codeFailures)}
+}
+
+// allow reports whether the user may try another code
+func (l *codeLimiter) allow(userID uuid.UUID, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	f, ok := l.failures[userID]
+	if !ok {
+		return true
+	}
+	if now.Sub(f.since) >= codeFailureWindow {
+		delete(l.failures, userID)
+		return true
+	}
+	return f.count < maxCodeFailures
+}
+
+// fail records a wrong code
+func (l *codeLimiter) fail(userID uuid.UUID, now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	f, ok := l.failures[userID]
+	if !ok || now.Sub(f.since) >= codeFailureWindow {
+		f = &codeFailures{since: now}
+		l.failures[userID] = f
+	}
+	f.count++
+}
+
+// reset clears a user's failures after a correct code
+func (l *codeLimiter) reset(userID uuid.UUID) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.failures, userID)
+}
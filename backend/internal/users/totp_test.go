@@ -0,0 +1,122 @@
+package users
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// rfcSecret is the RFC 6238 SHA1 test key "12345678901234567890" in base32
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCodeRFC6238Vectors(t *testing.T) {
+	// The RFC lists 8-digit codes; a 6-digit code is their last six digits
+	vectors := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, v := range vectors {
+		code, err := totpCode(rfcSecret, v.unix/totpPeriod)
+		if err != nil {
+			t.Fatalf("totpCode at %d: %v", v.unix, err)
+		}
+		if code != v.code {
+			t.Errorf("code at %d = %s, want %s", v.unix, code, v.code)
+		}
+	}
+
+	// Secrets are accepted in lower case too
+	if code, err := totpCode("gezdgnbvgy3tqojqgezdgnbvgy3tqojq", 59/totpPeriod); err != nil || code != "287082" {
+		t.Errorf("lower-case secret gave %q, %v; want 287082", code, err)
+	}
+}
+
+func TestMatchTOTP(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	current := now.Unix() / totpPeriod
+	codeAt := func(step int64) string {
+		code, err := totpCode(rfcSecret, step)
+		if err != nil {
+			t.Fatalf("totpCode: %v", err)
+		}
+		return code
+	}
+
+	tests := []struct {
+		name     string
+		code     string
+		lastStep int64
+		wantStep int64
+		wantOK   bool
+	}{
+		{"current step", codeAt(current), 0, current, true},
+		{"previous step within skew", codeAt(current - 1), 0, current - 1, true},
+		{"next step within skew", codeAt(current + 1), 0, current + 1, true},
+		{"outside skew", codeAt(current - 2), 0, 0, false},
+		{"already used step", codeAt(current), current, 0, false},
+		{"later step after a used one", codeAt(current + 1), current, current + 1, true},
+		{"too short", codeAt(current)[:totpDigits-1], 0, 0, false},
+		{"too long", codeAt(current) + "0", 0, 0, false},
+		{"wrong code", "000000", 0, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := matchTOTP(rfcSecret, tt.code, now, tt.lastStep)
+			if ok != tt.wantOK || step != tt.wantStep {
+				t.Errorf("matchTOTP = %d, %v; want %d, %v", step, ok, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestHashRecoveryCodeNormalizes(t *testing.T) {
+	want := hashRecoveryCode("abcde-fghij")
+	for _, code := range []string{"ABCDE-FGHIJ", "abcdefghij", "abcde fghij", " AbCdE-fGhIj "} {
+		if got := hashRecoveryCode(code); got != want {
+			t.Errorf("hash of %q differs from abcde-fghij", code)
+		}
+	}
+	if hashRecoveryCode("abcde-fghik") == want {
+		t.Error("different codes hashed the same")
+	}
+}
+
+func TestCodeLimiterLockout(t *testing.T) {
+	limiter := newCodeLimiter()
+	user, other := uuid.New(), uuid.New()
+	start := time.Now()
+
+	for i := 0; i < maxCodeFailures; i++ {
+		if !limiter.allow(user, start) {
+			t.Fatalf("locked out after %d failures, want %d allowed", i, maxCodeFailures)
+		}
+		limiter.fail(user, start)
+	}
+	if limiter.allow(user, start.Add(codeFailureWindow-time.Second)) {
+		t.Error("allowed another code within the window after the maximum failures")
+	}
+	if !limiter.allow(other, start) {
+		t.Error("another user's failures locked this user out")
+	}
+	if !limiter.allow(user, start.Add(codeFailureWindow)) {
+		t.Error("still locked out once the window passed")
+	}
+
+	// A correct code clears earlier failures
+	for i := 0; i < maxCodeFailures-1; i++ {
+		limiter.fail(other, start)
+	}
+	limiter.reset(other)
+	limiter.fail(other, start)
+	if !limiter.allow(other, start) {
+		t.Error("failures before a reset still counted")
+	}
+}
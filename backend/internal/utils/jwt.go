@@ -16,40 +16,83 @@ var (
 )
 
 var (
-	jwtSecret          string
-	jwtExpirationHours int = 24 * 7 // 7 days default
+	jwtSecret      string
+	accessTokenTTL = 15 * time.Minute
 )
 
-// JWTClaims represents the claims in the JWT token
+// challengeTTL bounds how long a password-verified login may wait for its second factor
+const challengeTTL = 5 * time.Minute
+
+// purposeTwoFactor marks a token that only proves the password step of a two-step login
+const purposeTwoFactor = "2fa"
+
+// JWTClaims represents the claims in the JWT token. The registered ID claim (jti) names the
+// token so it can be revoked.
 type JWTClaims struct {
-	UserID uuid.UUID `json:"user_id"`
-	Email  string    `json:"email"`
+	UserID    uuid.UUID `json:"user_id"`
+	Email     string    `json:"email"`
+	SessionID uuid.UUID `json:"sid,omitempty"`     // login session an access token belongs to
+	Roles     []string  `json:"roles,omitempty"`   // granted roles as of when the token was issued
+	Purpose   string    `json:"purpose,omitempty"` // empty for access tokens
 	jwt.RegisteredClaims
 }
 
-// InitJWT initializes the JWT utility with secret and expiration
-func InitJWT(secret string, expirationHours int) {
+// AccessToken is a signed access token with the claims needed to revoke it
+type AccessToken struct {
+	Token     string
+	ID        string
+	ExpiresAt time.Time
+}
+
+// InitJWT initializes the JWT utility with secret and access token lifetime
+func InitJWT(secret string, ttl time.Duration) {
 	jwtSecret = secret
-	if expirationHours > 0 {
-		jwtExpirationHours = expirationHours
+	if ttl > 0 {
+		accessTokenTTL = ttl
 	}
 }
 
-// GenerateToken creates a new JWT token for a user
-func GenerateToken(userID uuid.UUID, email string) (string, error) {
-	if jwtSecret == "" {
-		return "", ErrJWTNotInit
+// GenerateToken creates a short-lived access token for a user's login session, carrying the
+// user's roles so permission checks don't need a lookup
+func GenerateToken(userID uuid.UUID, email string, sessionID uuid.UUID, roles []string) (*AccessToken, error) {
+	claims := newClaims(userID, email, "", accessTokenTTL)
+	claims.SessionID = sessionID
+	claims.Roles = roles
+
+	token, err := sign(claims)
+	if err != nil {
+		return nil, err
 	}
+	return &AccessToken{Token: token, ID: claims.ID, ExpiresAt: claims.ExpiresAt.Time}, nil
+}
+
+// GenerateChallengeToken creates a short-lived token for a user who has passed the password
+// step of login and still owes a second factor. It is not accepted as an access token.
+func GenerateChallengeToken(userID uuid.UUID, email string) (string, error) {
+	return sign(newClaims(userID, email, purposeTwoFactor, challengeTTL))
+}
 
-	claims := JWTClaims{
-		UserID: userID,
-		Email:  email,
+// newClaims builds claims for a user's token that expires after ttl
+func newClaims(userID uuid.UUID, email, purpose string, ttl time.Duration) *JWTClaims {
+	now := time.Now()
+	return &JWTClaims{
+		UserID:  userID,
+		Email:   email,
+		Purpose: purpose,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * time.Duration(jwtExpirationHours))),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
+			ID:        uuid.NewString(),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
 		},
 	}
+}
+
+// sign signs claims with the JWT secret
+func sign(claims *JWTClaims) (string, error) {
+	if jwtSecret == "" {
+		return "", ErrJWTNotInit
+	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	tokenString, err := token.SignedString([]byte(jwtSecret))
@@ -60,8 +103,26 @@ func GenerateToken(userID uuid.UUID, email string) (string, error) {
 	return tokenString, nil
 }
 
-// ValidateToken validates a JWT token and returns the claims
+// ValidateToken validates an access token and returns the claims. Tokens issued before
+// sessions existed carry no session or ID and are rejected.
 func ValidateToken(tokenString string) (*JWTClaims, error) {
+	claims, err := parseToken(tokenString, "")
+	if err != nil {
+		return nil, err
+	}
+	if claims.ID == "" || claims.SessionID == uuid.Nil {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// ValidateChallengeToken validates a token issued by GenerateChallengeToken
+func ValidateChallengeToken(tokenString string) (*JWTClaims, error) {
+	return parseToken(tokenString, purposeTwoFactor)
+}
+
+// parseToken validates a JWT token issued for purpose and returns the claims
+func parseToken(tokenString, purpose string) (*JWTClaims, error) {
 	if jwtSecret == "" {
 		return nil, ErrJWTNotInit
 	}
@@ -75,11 +136,14 @@ func ValidateToken(tokenString string) (*JWTClaims, error) {
 	})
 
 	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, ErrExpiredToken
+		}
 		return nil, err
 	}
 
 	claims, ok := token.Claims.(*JWTClaims)
-	if !ok || !token.Valid {
+	if !ok || !token.Valid || claims.Purpose != purpose {
 		return nil, ErrInvalidToken
 	}
 
@@ -95,8 +159,10 @@ func ValidateToken(tokenString string) (*JWTClaims, error) {
 type contextKey string
 
 const (
-	UserIDKey contextKey = "user_id"
-	EmailKey  contextKey = "email"
+	UserIDKey    contextKey = "user_id"
+	EmailKey     contextKey = "email"
+	SessionIDKey contextKey = "session_id"
+	RolesKey     contextKey = "roles"
 )
 
 // SetUserIDInContext adds user ID to the context
@@ -120,3 +186,25 @@ func GetEmailFromContext(ctx context.Context) (string, bool) {
 	email, ok := ctx.Value(EmailKey).(string)
 	return email, ok
 }
+
+// SetSessionIDInContext adds the login session ID to the context
+func SetSessionIDInContext(ctx context.Context, sessionID uuid.UUID) context.Context {
+	return context.WithValue(ctx, SessionIDKey, sessionID)
+}
+
+// GetSessionIDFromContext retrieves the login session ID from the request context
+func GetSessionIDFromContext(ctx context.Context) (uuid.UUID, bool) {
+	sessionID, ok := ctx.Value(SessionIDKey).(uuid.UUID)
+	return sessionID, ok
+}
+
+// SetRolesInContext adds the user's granted roles to the context
+func SetRolesInContext(ctx context.Context, roles []string) context.Context {
+	return context.WithValue(ctx, RolesKey, roles)
+}
+
+// GetRolesFromContext retrieves the user's granted roles from the request context
+func GetRolesFromContext(ctx context.Context) []string {
+	roles, _ := ctx.Value(RolesKey).([]string)
+	return roles
+}
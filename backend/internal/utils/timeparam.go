@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"errors"
+	"net/url"
+	"time"
+)
+
+var (
+	ErrInvalidFromTime = errors.New("invalid from timestamp")
+	ErrInvalidToTime   = errors.New("invalid to timestamp")
+)
+
+// defaultRangeDays is how far back a time range reaches when the caller gives no from
+const defaultRangeDays = 30
+
+// ParseTimeParam accepts RFC3339 timestamps or plain dates, which mean the end of that day
+func ParseTimeParam(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	day, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return day.AddDate(0, 0, 1).Add(-time.Microsecond), nil
+}
+
+// ParseTimeRange reads the from and to query parameters, defaulting to the last 30 days. It
+// doesn't check the order of the two; callers enforce their own limits on the range.
+func ParseTimeRange(query url.Values) (from, to time.Time, err error) {
+	to = time.Now()
+	from = to.AddDate(0, 0, -defaultRangeDays)
+
+	if toStr := query.Get("to"); toStr != "" {
+		if to, err = ParseTimeParam(toStr); err != nil {
+			return time.Time{}, time.Time{}, ErrInvalidToTime
+		}
+	}
+
+	if fromStr := query.Get("from"); fromStr != "" {
+		if from, err = ParseTimeParam(fromStr); err != nil {
+			return time.Time{}, time.Time{}, ErrInvalidFromTime
+		}
+	}
+
+	return from, to, nil
+}
@@ -1,8 +1,11 @@
 package wallets
 
 import (
+	"encoding/json"
+	"errors"
 	"net/http"
 
+	"github.com/Bwise1/interstellar/internal/fxrates"
 	"github.com/Bwise1/interstellar/internal/utils"
 	"github.com/Bwise1/interstellar/pkg/response"
 	"github.com/go-chi/chi/v5"
@@ -21,7 +24,7 @@ func NewHandler(service *Service) *Handler {
 	}
 }
 
-// GET /api/wallets
+// GET /api/wallets?valuation=USD
 func (h *Handler) GetWallet(w http.ResponseWriter, r *http.Request) {
 
 	userID, ok := utils.GetUserIDFromContext(r.Context())
@@ -30,6 +33,17 @@ func (h *Handler) GetWallet(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if reportingCurrency := r.URL.Query().Get("valuation"); reportingCurrency != "" {
+		walletResponse, err := h.service.GetWalletWithValuation(r.Context(), userID, reportingCurrency)
+		if err != nil {
+			h.writeValuationError(w, err)
+			return
+		}
+
+		response.Success(w, http.StatusOK, "Wallet retrieved successfully", walletResponse)
+		return
+	}
+
 	wallet, err := h.service.GetWalletByUserID(r.Context(), userID)
 	if err != nil {
 		if err == ErrWalletNotFound {
@@ -98,7 +112,7 @@ func (h *Handler) GetBalance(w http.ResponseWriter, r *http.Request) {
 	response.Success(w, http.StatusOK, "Balance retrieved successfully", balanceResponse)
 }
 
-// GET /api/wallets/balances
+// GET /api/wallets/balances?at=2025-06-30
 func (h *Handler) GetAllBalances(w http.ResponseWriter, r *http.Request) {
 
 	userID, ok := utils.GetUserIDFromContext(r.Context())
@@ -107,6 +121,27 @@ func (h *Handler) GetAllBalances(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if atStr := r.URL.Query().Get("at"); atStr != "" {
+		at, err := utils.ParseTimeParam(atStr)
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, "Invalid at timestamp")
+			return
+		}
+
+		balancesAt, err := h.service.GetBalancesAt(r.Context(), userID, at)
+		if err != nil {
+			if err == ErrWalletNotFound {
+				response.Error(w, http.StatusNotFound, "Wallet not found")
+				return
+			}
+			response.Error(w, http.StatusInternalServerError, "Failed to retrieve balances")
+			return
+		}
+
+		response.Success(w, http.StatusOK, "Balances retrieved successfully", balancesAt)
+		return
+	}
+
 	balances, err := h.service.GetAllBalances(r.Context(), userID)
 	if err != nil {
 		if err == ErrWalletNotFound {
@@ -119,3 +154,107 @@ func (h *Handler) GetAllBalances(w http.ResponseWriter, r *http.Request) {
 
 	response.Success(w, http.StatusOK, "Balances retrieved successfully", balances)
 }
+
+// GET /api/wallets/valuation?currency=USD
+func (h *Handler) GetValuation(w http.ResponseWriter, r *http.Request) {
+	userID, ok := utils.GetUserIDFromContext(r.Context())
+	if !ok {
+		response.Error(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	reportingCurrency := r.URL.Query().Get("currency")
+	if reportingCurrency == "" {
+		reportingCurrency = "USD"
+	}
+
+	valuation, err := h.service.GetValuation(r.Context(), userID, reportingCurrency)
+	if err != nil {
+		h.writeValuationError(w, err)
+		return
+	}
+
+	response.Success(w, http.StatusOK, "Valuation retrieved successfully", valuation)
+}
+
+// GET /api/wallets/balances/history?from=2025-06-01&to=2025-06-30
+func (h *Handler) GetBalanceHistory(w http.ResponseWriter, r *http.Request) {
+	userID, ok := utils.GetUserIDFromContext(r.Context())
+	if !ok {
+		response.Error(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	from, to, err := utils.ParseTimeRange(r.URL.Query())
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	history, err := h.service.GetBalanceHistory(r.Context(), userID, from, to)
+	if err != nil {
+		switch err {
+		case ErrInvalidTimeRange:
+			response.Error(w, http.StatusBadRequest, "from must be before to and span at most a year")
+		case ErrWalletNotFound:
+			response.Error(w, http.StatusNotFound, "Wallet not found")
+		default:
+			response.Error(w, http.StatusInternalServerError, "Failed to retrieve balance history")
+		}
+		return
+	}
+
+	response.Success(w, http.StatusOK, "Balance history retrieved successfully", history)
+}
+
+// PUT /api/admin/wallets/{id}/status
+func (h *Handler) ChangeStatus(w http.ResponseWriter, r *http.Request) {
+	actorID, ok := utils.GetUserIDFromContext(r.Context())
+	if !ok {
+		response.Error(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	walletID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid wallet ID")
+		return
+	}
+
+	var req ChangeStatusRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	wallet, err := h.service.ChangeStatus(r.Context(), actorID, walletID, &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrWalletNotFound):
+			response.Error(w, http.StatusNotFound, "Wallet not found")
+		case errors.Is(err, ErrInvalidReason), errors.Is(err, ErrInvalidTransition):
+			response.Error(w, http.StatusBadRequest, err.Error())
+		case errors.Is(err, ErrWalletHasFunds), errors.Is(err, ErrWalletHasHoldings), errors.Is(err, ErrWalletFrozen), errors.Is(err, ErrWalletClosed):
+			response.Error(w, http.StatusConflict, err.Error())
+		default:
+			response.Error(w, http.StatusInternalServerError, "Failed to change wallet status")
+		}
+		return
+	}
+
+	response.Success(w, http.StatusOK, "Wallet status updated successfully", wallet.ToResponse())
+}
+
+// writeValuationError maps valuation errors to HTTP responses
+func (h *Handler) writeValuationError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrWalletNotFound):
+		response.Error(w, http.StatusNotFound, "Wallet not found")
+	case errors.Is(err, fxrates.ErrUnsupportedCurrency):
+		response.Error(w, http.StatusBadRequest, "Unsupported reporting currency")
+	case errors.Is(err, ErrRateUnavailable):
+		response.Error(w, http.StatusBadGateway, err.Error())
+	default:
+		response.Error(w, http.StatusInternalServerError, "Failed to value wallet")
+	}
+}
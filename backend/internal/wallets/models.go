@@ -7,24 +7,92 @@ import (
 	"github.com/google/uuid"
 )
 
+// WalletStatus represents the lifecycle state of a wallet
+type WalletStatus string
+
+const (
+	WalletStatusActive      WalletStatus = "ACTIVE"
+	WalletStatusFrozenDebit WalletStatus = "FROZEN_DEBIT" // credits allowed, debits blocked
+	WalletStatusFrozenAll   WalletStatus = "FROZEN_ALL"   // all movements blocked
+	WalletStatusClosed      WalletStatus = "CLOSED"       // terminal
+)
+
+// StatusReason is the reason code recorded with a wallet status change
+type StatusReason string
+
+const (
+	StatusReasonComplianceReview StatusReason = "COMPLIANCE_REVIEW"
+	StatusReasonFraudSuspected   StatusReason = "FRAUD_SUSPECTED"
+	StatusReasonLegalOrder       StatusReason = "LEGAL_ORDER"
+	StatusReasonCustomerRequest  StatusReason = "CUSTOMER_REQUEST"
+	StatusReasonReviewCleared    StatusReason = "REVIEW_CLEARED"
+)
+
+// validStatusReasons lists the accepted reason codes
+var validStatusReasons = map[StatusReason]bool{
+	StatusReasonComplianceReview: true,
+	StatusReasonFraudSuspected:   true,
+	StatusReasonLegalOrder:       true,
+	StatusReasonCustomerRequest:  true,
+	StatusReasonReviewCleared:    true,
+}
+
 // Wallet
 type Wallet struct {
-	ID            uuid.UUID          `json:"id"`
-	UserID        uuid.UUID          `json:"user_id"`
-	WalletAddress string             `json:"wallet_address"`
-	Balances      map[string]float64 `json:"balances"` // {"cNGN": 5000, "USDx": 100}
-	CreatedAt     time.Time          `json:"created_at"`
-	UpdatedAt     time.Time          `json:"updated_at"`
+	ID              uuid.UUID          `json:"id"`
+	UserID          uuid.UUID          `json:"user_id"`
+	WalletAddress   string             `json:"wallet_address"`
+	Balances        map[string]float64 `json:"balances"` // {"cNGN": 5000, "USDx": 100}
+	Status          WalletStatus       `json:"status"`
+	StatusReason    StatusReason       `json:"status_reason,omitempty"`
+	StatusChangedAt *time.Time         `json:"status_changed_at,omitempty"`
+	CreatedAt       time.Time          `json:"created_at"`
+	UpdatedAt       time.Time          `json:"updated_at"`
 }
 
 // WalletResponse
 type WalletResponse struct {
-	ID            uuid.UUID          `json:"id"`
-	UserID        uuid.UUID          `json:"user_id"`
-	WalletAddress string             `json:"wallet_address"`
-	Balances      map[string]float64 `json:"balances"`
-	TotalUSD      float64            `json:"total_usd,omitempty"`
-	CreatedAt     time.Time          `json:"created_at"`
+	ID              uuid.UUID          `json:"id"`
+	UserID          uuid.UUID          `json:"user_id"`
+	WalletAddress   string             `json:"wallet_address"`
+	Balances        map[string]float64 `json:"balances"`
+	Status          WalletStatus       `json:"status"`
+	StatusReason    StatusReason       `json:"status_reason,omitempty"`
+	StatusChangedAt *time.Time         `json:"status_changed_at,omitempty"`
+	TotalUSD        float64            `json:"total_usd,omitempty"`
+	Valuation       *Valuation         `json:"valuation,omitempty"`
+	CreatedAt       time.Time          `json:"created_at"`
+}
+
+// Valuation is the total wallet value expressed in a reporting currency
+type Valuation struct {
+	ReportingCurrency string              `json:"reporting_currency"`
+	Total             float64             `json:"total"`
+	Contributions     []CurrencyValuation `json:"contributions"`
+	RateTimestamp     time.Time           `json:"rate_timestamp"`
+}
+
+// CurrencyValuation is a single balance's contribution to a Valuation
+type CurrencyValuation struct {
+	Currency string  `json:"currency"`
+	Balance  float64 `json:"balance"`
+	Rate     float64 `json:"rate"`
+	Value    float64 `json:"value"`
+}
+
+// ChangeStatusRequest represents an admin request to move a wallet to a new state
+type ChangeStatusRequest struct {
+	Status         WalletStatus `json:"status"`
+	Reason         StatusReason `json:"reason"`
+	Note           string       `json:"note,omitempty"`
+	SweepToAddress string       `json:"sweep_to_address,omitempty"` // required to close a wallet holding funds
+}
+
+// BalanceAdjustment is a signed change to one currency balance of a wallet
+type BalanceAdjustment struct {
+	WalletID uuid.UUID
+	Currency string
+	Delta    float64
 }
 
 // GetBalanceRequest
@@ -38,14 +106,43 @@ type BalanceResponse struct {
 	Balance  float64 `json:"balance"`
 }
 
+// BalancesAtResponse holds wallet balances reconstructed at a point in time
+type BalancesAtResponse struct {
+	At       time.Time          `json:"at"`
+	Balances map[string]float64 `json:"balances"`
+}
+
+// BalancePoint is a single point in a balance history series
+type BalancePoint struct {
+	Timestamp time.Time          `json:"timestamp"`
+	Balances  map[string]float64 `json:"balances"`
+}
+
+// BalanceHistoryResponse is a daily balance time series for charting
+type BalanceHistoryResponse struct {
+	From   time.Time      `json:"from"`
+	To     time.Time      `json:"to"`
+	Points []BalancePoint `json:"points"`
+}
+
+// balanceDelta is the net change of one currency on one day
+type balanceDelta struct {
+	Day      time.Time
+	Currency string
+	Amount   float64
+}
+
 // convert Wallet to WalletResponse
 func (w *Wallet) ToResponse() *WalletResponse {
 	return &WalletResponse{
-		ID:            w.ID,
-		UserID:        w.UserID,
-		WalletAddress: w.WalletAddress,
-		Balances:      w.Balances,
-		CreatedAt:     w.CreatedAt,
+		ID:              w.ID,
+		UserID:          w.UserID,
+		WalletAddress:   w.WalletAddress,
+		Balances:        w.Balances,
+		Status:          w.Status,
+		StatusReason:    w.StatusReason,
+		StatusChangedAt: w.StatusChangedAt,
+		CreatedAt:       w.CreatedAt,
 	}
 }
 
@@ -101,3 +198,35 @@ func (w *Wallet) GetUpdatedAt() time.Time {
 func (w *Wallet) SetUpdatedAt(t time.Time) {
 	w.UpdatedAt = t
 }
+
+// CanDebit reports whether funds may leave the wallet
+func (w *Wallet) CanDebit() error {
+	switch w.Status {
+	case WalletStatusClosed:
+		return ErrWalletClosed
+	case WalletStatusFrozenDebit, WalletStatusFrozenAll:
+		return ErrWalletFrozen
+	}
+	return nil
+}
+
+// CanCredit reports whether funds may enter the wallet
+func (w *Wallet) CanCredit() error {
+	switch w.Status {
+	case WalletStatusClosed:
+		return ErrWalletClosed
+	case WalletStatusFrozenAll:
+		return ErrWalletFrozen
+	}
+	return nil
+}
+
+// HasFunds reports whether any balance is non-zero
+func (w *Wallet) HasFunds() bool {
+	for _, balance := range w.Balances {
+		if balance != 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -3,12 +3,44 @@ package wallets
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"sort"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// checkViolationCode is the Postgres SQLSTATE for check_violation
+const checkViolationCode = "23514"
+
+// balancesColumn aggregates a wallet's rows in wallet_balances into a JSON object
+const balancesColumn = `COALESCE(
+			(SELECT jsonb_object_agg(b.currency, b.amount) FROM wallet_balances b WHERE b.wallet_id = wallets.id),
+			'{}'::jsonb
+		)`
+
+// walletDeltasQuery lists the signed balance movements of wallet $1 in the window ($2, $3]
+const walletDeltasQuery = `
+	SELECT created_at, from_currency AS currency,
+	       CASE WHEN transaction_type IN ('DEPOSIT', 'SAVINGS_UNLOCK', 'INTEREST', 'ORDER_RELEASE')
+	            THEN from_amount ELSE -from_amount END AS amount
+	FROM transactions
+	WHERE wallet_id = $1 AND status = 'COMPLETED' AND created_at > $2 AND created_at <= $3
+	UNION ALL
+	SELECT created_at, to_currency, to_amount
+	FROM transactions
+	WHERE wallet_id = $1 AND transaction_type IN ('SWAP', 'TRADE') AND status = 'COMPLETED'
+	  AND created_at > $2 AND created_at <= $3
+	UNION ALL
+	SELECT created_at, to_currency, to_amount
+	FROM transactions
+	WHERE recipient_wallet_id = $1 AND transaction_type = 'TRANSFER' AND status = 'COMPLETED'
+	  AND created_at > $2 AND created_at <= $3
+`
+
 // Repository handles database operations for wallets
 type Repository struct {
 	db *pgxpool.Pool
@@ -23,24 +55,19 @@ func NewRepository(db *pgxpool.Pool) *Repository {
 
 // Create wallet
 func (r *Repository) Create(ctx context.Context, wallet *Wallet) error {
-	balancesJSON, err := json.Marshal(wallet.Balances)
-	if err != nil {
-		return err
-	}
-
 	query := `
-		INSERT INTO wallets (id, user_id, wallet_address, balances, created_at, updated_at)
+		INSERT INTO wallets (id, user_id, wallet_address, status, created_at, updated_at)
 		VALUES ($1, $2, $3, $4, $5, $6)
 		RETURNING id, created_at, updated_at
 	`
 
-	err = r.db.QueryRow(
+	err := r.db.QueryRow(
 		ctx,
 		query,
 		wallet.ID,
 		wallet.UserID,
 		wallet.WalletAddress,
-		balancesJSON,
+		wallet.Status,
 		wallet.CreatedAt,
 		wallet.UpdatedAt,
 	).Scan(&wallet.ID, &wallet.CreatedAt, &wallet.UpdatedAt)
@@ -51,7 +78,8 @@ func (r *Repository) Create(ctx context.Context, wallet *Wallet) error {
 // GetByID
 func (r *Repository) GetByID(ctx context.Context, id uuid.UUID) (*Wallet, error) {
 	query := `
-		SELECT id, user_id, wallet_address, balances, created_at, updated_at
+		SELECT id, user_id, wallet_address, ` + balancesColumn + `, status,
+		       COALESCE(status_reason, ''), status_changed_at, created_at, updated_at
 		FROM wallets
 		WHERE id = $1
 	`
@@ -64,6 +92,9 @@ func (r *Repository) GetByID(ctx context.Context, id uuid.UUID) (*Wallet, error)
 		&wallet.UserID,
 		&wallet.WalletAddress,
 		&balancesJSON,
+		&wallet.Status,
+		&wallet.StatusReason,
+		&wallet.StatusChangedAt,
 		&wallet.CreatedAt,
 		&wallet.UpdatedAt,
 	)
@@ -86,7 +117,8 @@ func (r *Repository) GetByID(ctx context.Context, id uuid.UUID) (*Wallet, error)
 // GetByUserID
 func (r *Repository) GetByUserID(ctx context.Context, userID uuid.UUID) (*Wallet, error) {
 	query := `
-		SELECT id, user_id, wallet_address, balances, created_at, updated_at
+		SELECT id, user_id, wallet_address, ` + balancesColumn + `, status,
+		       COALESCE(status_reason, ''), status_changed_at, created_at, updated_at
 		FROM wallets
 		WHERE user_id = $1
 	`
@@ -99,6 +131,9 @@ func (r *Repository) GetByUserID(ctx context.Context, userID uuid.UUID) (*Wallet
 		&wallet.UserID,
 		&wallet.WalletAddress,
 		&balancesJSON,
+		&wallet.Status,
+		&wallet.StatusReason,
+		&wallet.StatusChangedAt,
 		&wallet.CreatedAt,
 		&wallet.UpdatedAt,
 	)
@@ -120,7 +155,8 @@ func (r *Repository) GetByUserID(ctx context.Context, userID uuid.UUID) (*Wallet
 // GetByAddress
 func (r *Repository) GetByAddress(ctx context.Context, address string) (*Wallet, error) {
 	query := `
-		SELECT id, user_id, wallet_address, balances, created_at, updated_at
+		SELECT id, user_id, wallet_address, ` + balancesColumn + `, status,
+		       COALESCE(status_reason, ''), status_changed_at, created_at, updated_at
 		FROM wallets
 		WHERE wallet_address = $1
 	`
@@ -132,6 +168,9 @@ func (r *Repository) GetByAddress(ctx context.Context, address string) (*Wallet,
 		&wallet.UserID,
 		&wallet.WalletAddress,
 		&balancesJSON,
+		&wallet.Status,
+		&wallet.StatusReason,
+		&wallet.StatusChangedAt,
 		&wallet.CreatedAt,
 		&wallet.UpdatedAt,
 	)
@@ -150,22 +189,108 @@ func (r *Repository) GetByAddress(ctx context.Context, address string) (*Wallet,
 	return &wallet, nil
 }
 
-// UpdateBalances
-func (r *Repository) UpdateBalances(ctx context.Context, wallet *Wallet) error {
-	balancesJSON, err := json.Marshal(wallet.Balances)
+// AdjustBalances applies signed deltas as atomic amount = amount + delta updates in one
+// database transaction. A debit that would take a balance below zero violates the
+// wallet_balances CHECK constraint and rolls back every adjustment with ErrInsufficientFunds.
+func (r *Repository) AdjustBalances(ctx context.Context, adjustments ...BalanceAdjustment) error {
+	return r.AdjustBalancesWith(ctx, nil, adjustments...)
+}
+
+// AdjustBalancesWith applies adjustments like AdjustBalances and runs record in the same database
+// transaction, so whatever record writes commits or rolls back together with the balances
+func (r *Repository) AdjustBalancesWith(ctx context.Context, record func(tx pgx.Tx) error, adjustments ...BalanceAdjustment) error {
+	// Lock rows in a stable order so opposing transfers cannot deadlock
+	ordered := make([]BalanceAdjustment, len(adjustments))
+	copy(ordered, adjustments)
+	sort.Slice(ordered, func(i, j int) bool {
+		if ordered[i].WalletID != ordered[j].WalletID {
+			return ordered[i].WalletID.String() < ordered[j].WalletID.String()
+		}
+		return ordered[i].Currency < ordered[j].Currency
+	})
+
+	tx, err := r.db.Begin(ctx)
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback(ctx)
 
+	query := `
+		INSERT INTO wallet_balances (wallet_id, currency, amount, updated_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (wallet_id, currency)
+		DO UPDATE SET amount = wallet_balances.amount + EXCLUDED.amount, updated_at = NOW()
+	`
+
+	for _, adjustment := range ordered {
+		if _, err := tx.Exec(ctx, query, adjustment.WalletID, adjustment.Currency, adjustment.Delta); err != nil {
+			if isNegativeBalanceViolation(err) {
+				return ErrInsufficientFunds
+			}
+			return err
+		}
+
+		if _, err := tx.Exec(ctx, `UPDATE wallets SET updated_at = NOW() WHERE id = $1`, adjustment.WalletID); err != nil {
+			return err
+		}
+	}
+
+	if record != nil {
+		if err := record(tx); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// SetBalance overwrites a single currency balance
+func (r *Repository) SetBalance(ctx context.Context, walletID uuid.UUID, currency string, amount float64) error {
+	query := `
+		INSERT INTO wallet_balances (wallet_id, currency, amount, updated_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (wallet_id, currency)
+		DO UPDATE SET amount = EXCLUDED.amount, updated_at = NOW()
+	`
+	_, err := r.db.Exec(ctx, query, walletID, currency, amount)
+	if isNegativeBalanceViolation(err) {
+		return ErrInvalidAmount
+	}
+	return err
+}
+
+// isNegativeBalanceViolation reports whether err is the wallet_balances non-negative CHECK failing
+func isNegativeBalanceViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) &&
+		pgErr.Code == checkViolationCode &&
+		pgErr.ConstraintName == "check_wallet_balance_non_negative"
+}
+
+// UpdateStatus records a lifecycle transition for a wallet
+func (r *Repository) UpdateStatus(ctx context.Context, wallet *Wallet) error {
 	query := `
 		UPDATE wallets
-		SET balances = $1, updated_at = NOW()
-		WHERE id = $2
+		SET status = $1, status_reason = $2, status_changed_at = $3, updated_at = NOW()
+		WHERE id = $4
 	`
-	_, err = r.db.Exec(ctx, query, balancesJSON, wallet.ID)
+	_, err := r.db.Exec(ctx, query, wallet.Status, wallet.StatusReason, wallet.StatusChangedAt, wallet.ID)
 	return err
 }
 
+// HasOpenHoldings reports whether a wallet's owner still has funds held outside its balances:
+// open savings pockets, unfilled limit orders or resting order book orders
+func (r *Repository) HasOpenHoldings(ctx context.Context, wallet *Wallet) (bool, error) {
+	query := `
+		SELECT EXISTS (SELECT 1 FROM savings_pockets WHERE wallet_id = $1 AND status = 'OPEN')
+			OR EXISTS (SELECT 1 FROM limit_orders WHERE user_id = $2 AND status IN ('OPEN', 'EXECUTING'))
+			OR EXISTS (SELECT 1 FROM exchange_orders WHERE user_id = $2 AND status = 'OPEN')
+	`
+	var open bool
+	err := r.db.QueryRow(ctx, query, wallet.ID, wallet.UserID).Scan(&open)
+	return open, err
+}
+
 // Delete soft deletes a wallet (if you add deleted_at column later)
 // For now, it's a hard delete
 func (r *Repository) Delete(ctx context.Context, id uuid.UUID) error {
@@ -181,3 +306,114 @@ func (r *Repository) UserHasWallet(ctx context.Context, userID uuid.UUID) (bool,
 	err := r.db.QueryRow(ctx, query, userID).Scan(&exists)
 	return exists, err
 }
+
+// CreateSnapshots copies the current balances of every wallet into wallet_balance_snapshots
+func (r *Repository) CreateSnapshots(ctx context.Context, snapshotAt time.Time) (int64, error) {
+	query := `
+		INSERT INTO wallet_balance_snapshots (wallet_id, currency, amount, snapshot_at)
+		SELECT w.id, b.currency, b.amount, $1
+		FROM wallets w
+		JOIN wallet_balances b ON b.wallet_id = w.id
+		ON CONFLICT (wallet_id, currency, snapshot_at) DO NOTHING
+	`
+	result, err := r.db.Exec(ctx, query, snapshotAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+// HasSnapshotSince reports whether any balances have been snapshotted at or after a point in time
+func (r *Repository) HasSnapshotSince(ctx context.Context, since time.Time) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM wallet_balance_snapshots WHERE snapshot_at >= $1)`
+
+	var exists bool
+	err := r.db.QueryRow(ctx, query, since).Scan(&exists)
+	return exists, err
+}
+
+// GetLatestSnapshot returns the most recent snapshot taken at or before a point in time.
+// The returned time is zero when no snapshot exists.
+func (r *Repository) GetLatestSnapshot(ctx context.Context, walletID uuid.UUID, at time.Time) (time.Time, map[string]float64, error) {
+	query := `
+		SELECT currency, amount::float8, snapshot_at
+		FROM wallet_balance_snapshots
+		WHERE wallet_id = $1 AND snapshot_at = (
+			SELECT MAX(snapshot_at) FROM wallet_balance_snapshots
+			WHERE wallet_id = $1 AND snapshot_at <= $2
+		)
+	`
+
+	rows, err := r.db.Query(ctx, query, walletID, at)
+	if err != nil {
+		return time.Time{}, nil, err
+	}
+	defer rows.Close()
+
+	var snapshotAt time.Time
+	balances := make(map[string]float64)
+	for rows.Next() {
+		var currency string
+		var amount float64
+		if err := rows.Scan(&currency, &amount, &snapshotAt); err != nil {
+			return time.Time{}, nil, err
+		}
+		balances[currency] = amount
+	}
+
+	return snapshotAt, balances, rows.Err()
+}
+
+// GetBalanceDeltas sums the completed balance movements of a wallet in the window (after, until]
+func (r *Repository) GetBalanceDeltas(ctx context.Context, walletID uuid.UUID, after, until time.Time) (map[string]float64, error) {
+	query := `
+		SELECT currency, SUM(amount)::float8
+		FROM (` + walletDeltasQuery + `) deltas
+		GROUP BY currency
+	`
+
+	rows, err := r.db.Query(ctx, query, walletID, after, until)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deltas := make(map[string]float64)
+	for rows.Next() {
+		var currency string
+		var amount float64
+		if err := rows.Scan(&currency, &amount); err != nil {
+			return nil, err
+		}
+		deltas[currency] = amount
+	}
+
+	return deltas, rows.Err()
+}
+
+// GetDailyBalanceDeltas returns net balance movements per day and currency in the window (after, until]
+func (r *Repository) GetDailyBalanceDeltas(ctx context.Context, walletID uuid.UUID, after, until time.Time) ([]balanceDelta, error) {
+	query := `
+		SELECT date_trunc('day', created_at) AS day, currency, SUM(amount)::float8
+		FROM (` + walletDeltasQuery + `) deltas
+		GROUP BY day, currency
+		ORDER BY day
+	`
+
+	rows, err := r.db.Query(ctx, query, walletID, after, until)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deltas []balanceDelta
+	for rows.Next() {
+		var delta balanceDelta
+		if err := rows.Scan(&delta.Day, &delta.Currency, &delta.Amount); err != nil {
+			return nil, err
+		}
+		deltas = append(deltas, delta)
+	}
+
+	return deltas, rows.Err()
+}
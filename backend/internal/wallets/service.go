@@ -2,11 +2,16 @@ package wallets
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"log/slog"
+	"sort"
 	"strings"
 	"time"
 
+	"github.com/Bwise1/interstellar/internal/auditlogs"
+	"github.com/Bwise1/interstellar/internal/fxrates"
 	"github.com/google/uuid"
 )
 
@@ -16,17 +21,49 @@ var (
 	ErrInsufficientFunds = errors.New("insufficient funds")
 	ErrInvalidCurrency   = errors.New("invalid currency")
 	ErrInvalidAmount     = errors.New("invalid amount")
+	ErrRateUnavailable   = errors.New("exchange rate unavailable")
+	ErrInvalidTimeRange  = errors.New("invalid time range")
+	ErrWalletFrozen      = errors.New("wallet is frozen")
+	ErrWalletClosed      = errors.New("wallet is closed")
+	ErrInvalidTransition = errors.New("invalid wallet status transition")
+	ErrInvalidReason     = errors.New("invalid status reason")
+	ErrWalletHasFunds    = errors.New("wallet must have zero balances or a sweep destination to close")
+	ErrWalletHasHoldings = errors.New("wallet has open savings pockets or orders; close or cancel them first")
 )
 
+// maxHistoryDays caps the number of daily points returned by GetBalanceHistory
+const maxHistoryDays = 366
+
+// FXRateService defines the interface for FX rate operations
+type FXRateService interface {
+	GetRates(ctx context.Context, baseCurrency string) (*fxrates.FXRatesResponse, error)
+}
+
+// AuditLogger defines the interface for recording wallet status changes
+type AuditLogger interface {
+	LogRequest(ctx context.Context, req *auditlogs.CreateAuditLogRequest) error
+}
+
+// FundsSweeper moves every balance out of one wallet into another
+type FundsSweeper interface {
+	SweepWallet(ctx context.Context, from, to *Wallet) error
+}
+
 // Service handles business logic for wallets
 type Service struct {
-	repo *Repository
+	repo        *Repository
+	fxService   FXRateService
+	auditLogger AuditLogger
+	sweeper     FundsSweeper
 }
 
 // NewService creates a new wallet service
-func NewService(repo *Repository) *Service {
+func NewService(repo *Repository, fxService FXRateService, auditLogger AuditLogger, sweeper FundsSweeper) *Service {
 	return &Service{
-		repo: repo,
+		repo:        repo,
+		fxService:   fxService,
+		auditLogger: auditLogger,
+		sweeper:     sweeper,
 	}
 }
 
@@ -55,6 +92,7 @@ func (s *Service) createWalletInternal(ctx context.Context, userID uuid.UUID) (*
 		UserID:        userID,
 		WalletAddress: walletAddress,
 		Balances:      make(map[string]float64),
+		Status:        WalletStatusActive,
 		CreatedAt:     time.Now(),
 		UpdatedAt:     time.Now(),
 	}
@@ -122,23 +160,374 @@ func (s *Service) GetAllBalances(ctx context.Context, userID uuid.UUID) (map[str
 	return wallet.Balances, nil
 }
 
-// UpdateBalance
-func (s *Service) UpdateBalance(ctx context.Context, walletID uuid.UUID, currency string, amount float64) error {
+// GetValuation values every balance in the user's wallet in the reporting currency.
+// All contributions are priced from a single rate snapshot so they share one timestamp.
+func (s *Service) GetValuation(ctx context.Context, userID uuid.UUID, reportingCurrency string) (*Valuation, error) {
+	wallet, err := s.GetWalletByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.valueWallet(ctx, wallet, reportingCurrency)
+}
+
+// valueWallet prices a wallet's balances against one snapshot of reporting-currency rates
+func (s *Service) valueWallet(ctx context.Context, wallet *Wallet, reportingCurrency string) (*Valuation, error) {
+	base := fxrates.MapToRealCurrency(reportingCurrency)
+
+	rates, err := s.fxService.GetRates(ctx, base)
+	if errors.Is(err, fxrates.ErrUnsupportedCurrency) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrRateUnavailable, err)
+	}
+
+	valuation := &Valuation{
+		ReportingCurrency: reportingCurrency,
+		Contributions:     make([]CurrencyValuation, 0, len(wallet.Balances)),
+		RateTimestamp:     rates.LastUpdated,
+	}
+
+	for currency, balance := range wallet.Balances {
+		realCurrency := fxrates.MapToRealCurrency(currency)
+
+		// Rates are quoted per unit of the reporting currency, so invert them
+		rate := 1.0
+		if realCurrency != base {
+			quote, exists := rates.Rates[realCurrency]
+			if !exists || quote <= 0 {
+				return nil, fmt.Errorf("%w: %s/%s", ErrRateUnavailable, currency, reportingCurrency)
+			}
+			rate = 1 / quote
+		}
+
+		value := balance * rate
+		valuation.Total += value
+		valuation.Contributions = append(valuation.Contributions, CurrencyValuation{
+			Currency: currency,
+			Balance:  balance,
+			Rate:     rate,
+			Value:    value,
+		})
+	}
+
+	sort.Slice(valuation.Contributions, func(i, j int) bool {
+		return valuation.Contributions[i].Currency < valuation.Contributions[j].Currency
+	})
+
+	return valuation, nil
+}
+
+// GetWalletWithValuation returns the user's wallet response with its valuation attached
+func (s *Service) GetWalletWithValuation(ctx context.Context, userID uuid.UUID, reportingCurrency string) (*WalletResponse, error) {
+	wallet, err := s.GetWalletByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	valuation, err := s.valueWallet(ctx, wallet, reportingCurrency)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := wallet.ToResponse()
+	resp.Valuation = valuation
+	if fxrates.MapToRealCurrency(reportingCurrency) == "USD" {
+		resp.TotalUSD = valuation.Total
+	}
+
+	return resp, nil
+}
+
+// GetBalancesAt reconstructs the user's balances as they were at a point in time
+func (s *Service) GetBalancesAt(ctx context.Context, userID uuid.UUID, at time.Time) (*BalancesAtResponse, error) {
+	wallet, err := s.GetWalletByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	balances, err := s.balancesAt(ctx, wallet.ID, at)
+	if err != nil {
+		return nil, err
+	}
+
+	return &BalancesAtResponse{
+		At:       at,
+		Balances: balances,
+	}, nil
+}
+
+// GetBalanceHistory returns end-of-day balances for every day between from and to
+func (s *Service) GetBalanceHistory(ctx context.Context, userID uuid.UUID, from, to time.Time) (*BalanceHistoryResponse, error) {
+	from = startOfDay(from)
+	to = to.UTC()
+	if !to.After(from) || to.Sub(from) > maxHistoryDays*24*time.Hour {
+		return nil, ErrInvalidTimeRange
+	}
+
+	wallet, err := s.GetWalletByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	running, err := s.balancesAt(ctx, wallet.ID, from)
+	if err != nil {
+		return nil, err
+	}
+
+	deltas, err := s.repo.GetDailyBalanceDeltas(ctx, wallet.ID, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	history := &BalanceHistoryResponse{
+		From:   from,
+		To:     to,
+		Points: make([]BalancePoint, 0),
+	}
+
+	next := 0
+	for day := from; day.Before(to); day = day.AddDate(0, 0, 1) {
+		for next < len(deltas) && !deltas[next].Day.After(day) {
+			running[deltas[next].Currency] += deltas[next].Amount
+			next++
+		}
+
+		end := day.AddDate(0, 0, 1)
+		if end.After(to) {
+			end = to
+		}
+
+		point := BalancePoint{
+			Timestamp: end,
+			Balances:  make(map[string]float64, len(running)),
+		}
+		for currency, amount := range running {
+			point.Balances[currency] = amount
+		}
+		history.Points = append(history.Points, point)
+	}
+
+	return history, nil
+}
+
+// balancesAt starts from the latest snapshot before at and replays the transactions since
+func (s *Service) balancesAt(ctx context.Context, walletID uuid.UUID, at time.Time) (map[string]float64, error) {
+	snapshotAt, balances, err := s.repo.GetLatestSnapshot(ctx, walletID, at)
+	if err != nil {
+		return nil, err
+	}
+
+	deltas, err := s.repo.GetBalanceDeltas(ctx, walletID, snapshotAt, at)
+	if err != nil {
+		return nil, err
+	}
+
+	for currency, amount := range deltas {
+		balances[currency] += amount
+	}
+
+	return balances, nil
+}
+
+// RunSnapshots snapshots every wallet's balances on each interval until ctx is cancelled. A
+// snapshot is also taken at startup unless one already exists for today (UTC, like balance
+// history's days), so a restart doesn't leave history without a snapshot until the first tick.
+func (s *Service) RunSnapshots(ctx context.Context, interval time.Duration) {
+	exists, err := s.repo.HasSnapshotSince(ctx, startOfDay(time.Now()))
+	if err != nil {
+		slog.Error("failed to check for today's wallet snapshot", "error", err)
+	}
+	if err == nil && !exists {
+		s.snapshot(ctx)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.snapshot(ctx)
+		}
+	}
+}
+
+// startOfDay returns midnight UTC of t's day, the day boundary balance history and snapshots share
+func startOfDay(t time.Time) time.Time {
+	return t.UTC().Truncate(24 * time.Hour)
+}
+
+// snapshot copies every wallet's current balances into the snapshot history
+func (s *Service) snapshot(ctx context.Context) {
+	count, err := s.repo.CreateSnapshots(ctx, time.Now())
+	if err != nil {
+		slog.Error("failed to snapshot wallet balances", "error", err)
+		return
+	}
+	slog.Info("wallet balances snapshotted", "rows", count)
+}
+
+// ChangeStatus moves a wallet through its lifecycle and records the transition in the audit log.
+// Closing a wallet that still holds funds requires a sweep destination, and is refused while
+// savings pockets or orders are open.
+func (s *Service) ChangeStatus(ctx context.Context, actorID, walletID uuid.UUID, req *ChangeStatusRequest) (*Wallet, error) {
+	if !validStatusReasons[req.Reason] {
+		return nil, ErrInvalidReason
+	}
+
 	wallet, err := s.GetWalletByID(ctx, walletID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !canTransition(wallet.Status, req.Status) {
+		return nil, fmt.Errorf("%w: %s to %s", ErrInvalidTransition, wallet.Status, req.Status)
+	}
+
+	previous := wallet.Status
+	if req.Status == WalletStatusClosed {
+		if err := s.prepareClose(ctx, wallet, req); err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now()
+	wallet.Status = req.Status
+	wallet.StatusReason = req.Reason
+	wallet.StatusChangedAt = &now
+
+	if err := s.repo.UpdateStatus(ctx, wallet); err != nil {
+		return nil, err
+	}
+
+	details, _ := json.Marshal(map[string]string{
+		"wallet_id":        wallet.ID.String(),
+		"from_status":      string(previous),
+		"to_status":        string(req.Status),
+		"reason":           string(req.Reason),
+		"note":             req.Note,
+		"sweep_to_address": req.SweepToAddress,
+	})
+	if err := s.auditLogger.LogRequest(ctx, &auditlogs.CreateAuditLogRequest{
+		UserID:      &actorID,
+		Operation:   "WALLET_STATUS_CHANGE",
+		ClientIP:    "internal",
+		RequestBody: string(details),
+	}); err != nil {
+		slog.Error("failed to audit wallet status change", "wallet_id", wallet.ID, "error", err)
+	}
+
+	return wallet, nil
+}
+
+// prepareClose checks a wallet can be closed and sweeps out its balances. Funds held in pockets
+// or orders must be released first. A wallet with funds is frozen before the sweep so nothing
+// can be credited between the sweep and the close.
+func (s *Service) prepareClose(ctx context.Context, wallet *Wallet, req *ChangeStatusRequest) error {
+	open, err := s.repo.HasOpenHoldings(ctx, wallet)
+	if err != nil {
+		return err
+	}
+	if open {
+		return ErrWalletHasHoldings
+	}
+
+	if !wallet.HasFunds() {
+		return nil
+	}
+	if req.SweepToAddress == "" {
+		return ErrWalletHasFunds
+	}
+
+	destination, err := s.GetWalletByAddress(ctx, req.SweepToAddress)
+	if err != nil {
+		return err
+	}
+	if destination.ID == wallet.ID {
+		return fmt.Errorf("%w: cannot sweep a wallet into itself", ErrInvalidTransition)
+	}
+	if err := destination.CanCredit(); err != nil {
+		return fmt.Errorf("sweep destination: %w", err)
+	}
+
+	if wallet.Status != WalletStatusFrozenAll {
+		now := time.Now()
+		frozen := *wallet
+		frozen.Status = WalletStatusFrozenAll
+		frozen.StatusReason = req.Reason
+		frozen.StatusChangedAt = &now
+		if err := s.repo.UpdateStatus(ctx, &frozen); err != nil {
+			return err
+		}
+	}
+
+	// An order or pocket opened before the freeze took effect still holds funds
+	if open, err := s.repo.HasOpenHoldings(ctx, wallet); err != nil || open {
+		if err == nil {
+			err = ErrWalletHasHoldings
+		}
+		return fmt.Errorf("wallet left frozen: %w", err)
+	}
+
+	// Sweep the balances as of the freeze, not as first read
+	frozen, err := s.GetWalletByID(ctx, wallet.ID)
+	if err != nil {
+		return err
+	}
+	if err := s.sweeper.SweepWallet(ctx, frozen, destination); err != nil {
+		return fmt.Errorf("failed to sweep wallet (left frozen): %w", err)
+	}
+
+	swept, err := s.GetWalletByID(ctx, wallet.ID)
 	if err != nil {
 		return err
 	}
+	if swept.HasFunds() {
+		return fmt.Errorf("wallet left frozen: %w", ErrWalletHasFunds)
+	}
+	return nil
+}
+
+// canTransition reports whether a wallet may move from one status to another
+func canTransition(from, to WalletStatus) bool {
+	if from == to || from == WalletStatusClosed {
+		return false
+	}
 
-	wallet.UpdateBalance(currency, amount)
+	switch to {
+	case WalletStatusActive, WalletStatusFrozenDebit, WalletStatusFrozenAll, WalletStatusClosed:
+		return true
+	}
+	return false
+}
 
-	// Check for negative balance
-	if wallet.GetBalance(currency) < 0 {
-		return ErrInsufficientFunds
+// UpdateBalance
+func (s *Service) UpdateBalance(ctx context.Context, walletID uuid.UUID, currency string, amount float64) error {
+	wallet, err := s.GetWalletByID(ctx, walletID)
+	if err != nil {
+		return err
 	}
 
-	wallet.UpdatedAt = time.Now()
+	// Enforce wallet lifecycle state
+	if amount < 0 {
+		err = wallet.CanDebit()
+	} else {
+		err = wallet.CanCredit()
+	}
+	if err != nil {
+		return err
+	}
 
-	return s.repo.UpdateBalances(ctx, wallet)
+	// The database rejects any delta that would leave the balance negative
+	return s.repo.AdjustBalances(ctx, BalanceAdjustment{
+		WalletID: wallet.ID,
+		Currency: currency,
+		Delta:    amount,
+	})
 }
 
 // SetBalance
@@ -147,15 +536,11 @@ func (s *Service) SetBalance(ctx context.Context, walletID uuid.UUID, currency s
 		return ErrInvalidAmount
 	}
 
-	wallet, err := s.GetWalletByID(ctx, walletID)
-	if err != nil {
+	if _, err := s.GetWalletByID(ctx, walletID); err != nil {
 		return err
 	}
 
-	wallet.SetBalance(currency, amount)
-	wallet.UpdatedAt = time.Now()
-
-	return s.repo.UpdateBalances(ctx, wallet)
+	return s.repo.SetBalance(ctx, walletID, currency, amount)
 }
 
 // AddBalance
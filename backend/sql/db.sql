@@ -77,3 +77,342 @@ CREATE INDEX IF NOT EXISTS idx_audit_logs_user_id ON audit_logs(user_id);
 CREATE INDEX IF NOT EXISTS idx_audit_logs_operation ON audit_logs(operation);
 CREATE INDEX IF NOT EXISTS idx_audit_logs_timestamp ON audit_logs(timestamp DESC);
 CREATE INDEX IF NOT EXISTS idx_audit_logs_client_ip ON audit_logs(client_ip);
+
+-- Wallet balance snapshots - daily point-in-time copies of wallet balances
+CREATE TABLE IF NOT EXISTS wallet_balance_snapshots (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    wallet_id UUID NOT NULL REFERENCES wallets(id) ON DELETE CASCADE,
+    currency VARCHAR(10) NOT NULL,
+    amount NUMERIC(20, 8) NOT NULL,
+    snapshot_at TIMESTAMP NOT NULL,
+    CONSTRAINT uq_wallet_snapshot UNIQUE (wallet_id, currency, snapshot_at)
+);
+
+-- Indexes for wallet_balance_snapshots table
+CREATE INDEX IF NOT EXISTS idx_wallet_balance_snapshots_wallet_at ON wallet_balance_snapshots(wallet_id, snapshot_at DESC);
+
+-- Wallet lifecycle state (ACTIVE, FROZEN_DEBIT, FROZEN_ALL, CLOSED)
+ALTER TABLE wallets ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'ACTIVE';
+ALTER TABLE wallets ADD COLUMN IF NOT EXISTS status_reason VARCHAR(50);
+ALTER TABLE wallets ADD COLUMN IF NOT EXISTS status_changed_at TIMESTAMP;
+ALTER TABLE wallets DROP CONSTRAINT IF EXISTS check_wallet_status;
+ALTER TABLE wallets ADD CONSTRAINT check_wallet_status CHECK (status IN ('ACTIVE', 'FROZEN_DEBIT', 'FROZEN_ALL', 'CLOSED'));
+
+-- Savings pockets - funds locked out of a wallet to earn daily simple interest
+CREATE TABLE IF NOT EXISTS savings_pockets (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    wallet_id UUID NOT NULL REFERENCES wallets(id) ON DELETE CASCADE,
+    currency VARCHAR(10) NOT NULL,
+    principal NUMERIC(20, 8) NOT NULL,
+    apr_bps INTEGER NOT NULL,
+    accrued_interest NUMERIC(20, 8) NOT NULL DEFAULT 0,
+    accrued_days INTEGER NOT NULL DEFAULT 0,
+    status VARCHAR(20) NOT NULL DEFAULT 'OPEN',
+    opened_at TIMESTAMP NOT NULL,
+    last_accrued_at TIMESTAMP,
+    closed_at TIMESTAMP,
+
+    CONSTRAINT check_savings_principal CHECK (principal > 0),
+    CONSTRAINT check_savings_apr CHECK (apr_bps >= 0),
+    CONSTRAINT check_savings_status CHECK (status IN ('OPEN', 'CLOSED'))
+);
+
+-- Indexes for savings_pockets table
+CREATE INDEX IF NOT EXISTS idx_savings_pockets_user_id ON savings_pockets(user_id);
+CREATE INDEX IF NOT EXISTS idx_savings_pockets_status ON savings_pockets(status);
+
+-- Savings movements are recorded as transactions
+ALTER TABLE transactions DROP CONSTRAINT IF EXISTS check_transaction_type;
+ALTER TABLE transactions ADD CONSTRAINT check_transaction_type
+    CHECK (transaction_type IN ('DEPOSIT', 'SWAP', 'TRANSFER', 'WITHDRAW', 'SAVINGS_LOCK', 'SAVINGS_UNLOCK', 'INTEREST'));
+
+-- Wallet balances - one row per wallet and currency; the CHECK makes overdraft impossible
+-- even under concurrent updates, which are applied as amount = amount + delta
+CREATE TABLE IF NOT EXISTS wallet_balances (
+    wallet_id UUID NOT NULL REFERENCES wallets(id) ON DELETE CASCADE,
+    currency VARCHAR(10) NOT NULL,
+    amount NUMERIC(20, 8) NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    PRIMARY KEY (wallet_id, currency),
+    CONSTRAINT check_wallet_balance_non_negative CHECK (amount >= 0)
+);
+
+-- Legacy balances that were negative and so could not be backfilled; each needs resolving by hand
+-- (correcting the ledger, inserting the wallet_balances row and setting resolved_at) before the
+-- wallet is unfrozen
+CREATE TABLE IF NOT EXISTS wallet_balance_backfill_issues (
+    wallet_id UUID NOT NULL REFERENCES wallets(id) ON DELETE CASCADE,
+    currency VARCHAR(10) NOT NULL,
+    legacy_amount NUMERIC(20, 8) NOT NULL,
+    found_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    resolved_at TIMESTAMP NULL, -- set once fixed, so re-running this file doesn't freeze the wallet again
+    PRIMARY KEY (wallet_id, currency)
+);
+
+INSERT INTO wallet_balance_backfill_issues (wallet_id, currency, legacy_amount)
+SELECT w.id, b.key, b.value::numeric
+FROM wallets w, jsonb_each_text(w.balances) b
+WHERE b.value::numeric < 0
+ON CONFLICT (wallet_id, currency) DO NOTHING;
+
+-- Hold those wallets for review so nothing moves until the balance is resolved
+UPDATE wallets SET status = 'FROZEN_ALL', status_reason = 'COMPLIANCE_REVIEW', status_changed_at = NOW()
+WHERE status = 'ACTIVE' AND id IN (SELECT wallet_id FROM wallet_balance_backfill_issues WHERE resolved_at IS NULL);
+
+-- Backfill from the legacy JSONB column; wallets.balances is no longer written. Negative balances
+-- are reported above rather than rewritten.
+INSERT INTO wallet_balances (wallet_id, currency, amount)
+SELECT w.id, b.key, b.value::numeric
+FROM wallets w, jsonb_each_text(w.balances) b
+WHERE b.value::numeric >= 0
+ON CONFLICT (wallet_id, currency) DO NOTHING;
+
+-- FX rates table - every fetched rate snapshot, used for history and to warm the cache on startup
+CREATE TABLE IF NOT EXISTS fx_rates (
+    id BIGSERIAL PRIMARY KEY,
+    base_currency VARCHAR(10) NOT NULL,
+    quote_currency VARCHAR(10) NOT NULL,
+    rate NUMERIC(24, 12) NOT NULL,
+    provider VARCHAR(50) NOT NULL,
+    fetched_at TIMESTAMP NOT NULL,
+
+    CONSTRAINT check_fx_rate_positive CHECK (rate > 0)
+);
+
+-- Indexes for fx_rates table
+CREATE INDEX IF NOT EXISTS idx_fx_rates_pair_fetched_at ON fx_rates(base_currency, quote_currency, fetched_at);
+CREATE INDEX IF NOT EXISTS idx_fx_rates_base_fetched_at ON fx_rates(base_currency, fetched_at DESC);
+
+-- FX rate overrides - admin-pinned rates that take precedence over provider data until they expire
+CREATE TABLE IF NOT EXISTS fx_rate_overrides (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    base_currency VARCHAR(10) NOT NULL,
+    quote_currency VARCHAR(10) NOT NULL,
+    rate NUMERIC(24, 12) NOT NULL,
+    reason TEXT NOT NULL,
+    created_by UUID NOT NULL REFERENCES users(id),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP,
+
+    CONSTRAINT check_fx_rate_override_positive CHECK (rate > 0),
+    CONSTRAINT check_fx_rate_override_expiry CHECK (expires_at > created_at)
+);
+
+CREATE INDEX IF NOT EXISTS idx_fx_rate_overrides_active ON fx_rate_overrides(expires_at) WHERE revoked_at IS NULL;
+
+-- FX provider usage - upstream requests per provider per month, for quota tracking
+CREATE TABLE IF NOT EXISTS fx_provider_usage (
+    provider VARCHAR(50) NOT NULL,
+    period DATE NOT NULL,
+    requests BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (provider, period)
+);
+
+-- Rate alerts - notify a user when a pair crosses a threshold
+CREATE TABLE IF NOT EXISTS rate_alerts (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    base_currency VARCHAR(10) NOT NULL,
+    quote_currency VARCHAR(10) NOT NULL,
+    direction VARCHAR(10) NOT NULL,
+    threshold NUMERIC(24, 12) NOT NULL,
+    recurring BOOLEAN NOT NULL DEFAULT FALSE,
+    status VARCHAR(20) NOT NULL DEFAULT 'ACTIVE',
+    armed BOOLEAN NOT NULL DEFAULT TRUE, -- recurring alerts disarm when fired and re-arm once the rate crosses back
+    last_triggered_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+
+    CONSTRAINT check_rate_alert_direction CHECK (direction IN ('ABOVE', 'BELOW')),
+    CONSTRAINT check_rate_alert_status CHECK (status IN ('ACTIVE', 'TRIGGERED')),
+    CONSTRAINT check_rate_alert_threshold CHECK (threshold > 0)
+);
+
+CREATE INDEX IF NOT EXISTS idx_rate_alerts_user_id ON rate_alerts(user_id);
+CREATE INDEX IF NOT EXISTS idx_rate_alerts_active ON rate_alerts(status) WHERE status = 'ACTIVE';
+
+-- Rate alert notifications - every trigger and the outcome of delivering it
+CREATE TABLE IF NOT EXISTS rate_alert_notifications (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    alert_id UUID NOT NULL, -- not a foreign key so history survives alert deletion
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    pair VARCHAR(21) NOT NULL,
+    direction VARCHAR(10) NOT NULL,
+    threshold NUMERIC(24, 12) NOT NULL,
+    rate NUMERIC(24, 12) NOT NULL,
+    message TEXT NOT NULL,
+    channel VARCHAR(50) NOT NULL,
+    delivered_at TIMESTAMP,
+    error TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_rate_alert_notifications_user_created ON rate_alert_notifications(user_id, created_at DESC);
+
+-- Limit orders - the amount is reserved out of the wallet (ORDER_RESERVE) while the order is open
+-- and released on cancel or expiry (ORDER_RELEASE) or into the swap when it fills
+CREATE TABLE IF NOT EXISTS limit_orders (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    from_currency VARCHAR(10) NOT NULL,
+    to_currency VARCHAR(10) NOT NULL,
+    amount NUMERIC(20, 8) NOT NULL,
+    limit_rate NUMERIC(24, 12) NOT NULL, -- minimum to_currency received per unit of from_currency
+    time_in_force VARCHAR(3) NOT NULL DEFAULT 'GTC',
+    expires_at TIMESTAMP,
+    status VARCHAR(20) NOT NULL DEFAULT 'OPEN',
+    swap_transaction_id UUID REFERENCES transactions(id),
+    executed_rate NUMERIC(24, 12),
+    to_amount NUMERIC(20, 8),
+    failure_reason TEXT, -- why the last execution attempt did not go through
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    closed_at TIMESTAMP,
+
+    CONSTRAINT check_limit_order_amount CHECK (amount > 0),
+    CONSTRAINT check_limit_order_rate CHECK (limit_rate > 0),
+    CONSTRAINT check_limit_order_currencies CHECK (from_currency <> to_currency),
+    CONSTRAINT check_limit_order_tif CHECK (time_in_force IN ('GTC', 'GTD')),
+    CONSTRAINT check_limit_order_expiry CHECK (time_in_force = 'GTC' OR expires_at IS NOT NULL),
+    CONSTRAINT check_limit_order_status CHECK (status IN ('OPEN', 'EXECUTING', 'FILLED', 'CANCELLED', 'EXPIRED'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_limit_orders_user_created ON limit_orders(user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_limit_orders_open ON limit_orders(created_at) WHERE status = 'OPEN';
+
+-- ID the SWAP transaction will be recorded under, chosen when the matcher claims an order, so an
+-- order left EXECUTING by a crash can be matched to its swap on startup
+ALTER TABLE limit_orders ADD COLUMN IF NOT EXISTS execution_id UUID;
+
+-- Limit order reservations are recorded as transactions
+ALTER TABLE transactions DROP CONSTRAINT IF EXISTS check_transaction_type;
+ALTER TABLE transactions ADD CONSTRAINT check_transaction_type
+    CHECK (transaction_type IN ('DEPOSIT', 'SWAP', 'TRANSFER', 'WITHDRAW', 'SAVINGS_LOCK', 'SAVINGS_UNLOCK', 'INTEREST', 'ORDER_RESERVE', 'ORDER_RELEASE'));
+
+-- Order book orders - bids and asks between users; held is what is still reserved from the
+-- wallet for the unfilled remainder (quote currency for bids, base currency for asks)
+CREATE TABLE IF NOT EXISTS exchange_orders (
+    id UUID PRIMARY KEY,
+    seq BIGSERIAL UNIQUE NOT NULL, -- arrival order, for time priority
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    pair VARCHAR(21) NOT NULL,
+    side VARCHAR(4) NOT NULL,
+    price NUMERIC(24, 12) NOT NULL,
+    quantity NUMERIC(20, 8) NOT NULL,
+    remaining NUMERIC(20, 8) NOT NULL,
+    held NUMERIC(20, 8) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'OPEN',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    closed_at TIMESTAMP,
+
+    CONSTRAINT check_exchange_order_side CHECK (side IN ('BUY', 'SELL')),
+    CONSTRAINT check_exchange_order_price CHECK (price > 0),
+    CONSTRAINT check_exchange_order_quantity CHECK (quantity > 0 AND remaining >= 0 AND remaining <= quantity),
+    CONSTRAINT check_exchange_order_held CHECK (held >= 0),
+    CONSTRAINT check_exchange_order_status CHECK (status IN ('OPEN', 'FILLED', 'CANCELLED'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_exchange_orders_user_seq ON exchange_orders(user_id, seq DESC);
+CREATE INDEX IF NOT EXISTS idx_exchange_orders_open ON exchange_orders(seq) WHERE status = 'OPEN';
+
+-- Order book trades - each fill, priced at the resting order's price
+CREATE TABLE IF NOT EXISTS exchange_trades (
+    id UUID PRIMARY KEY,
+    seq BIGSERIAL UNIQUE NOT NULL,
+    pair VARCHAR(21) NOT NULL,
+    price NUMERIC(24, 12) NOT NULL,
+    quantity NUMERIC(20, 8) NOT NULL,
+    quote_amount NUMERIC(20, 8) NOT NULL,
+    taker_side VARCHAR(4) NOT NULL,
+    buy_order_id UUID NOT NULL REFERENCES exchange_orders(id),
+    sell_order_id UUID NOT NULL REFERENCES exchange_orders(id),
+    buyer_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    seller_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_exchange_trades_pair_seq ON exchange_trades(pair, seq DESC);
+
+-- Order book fills are recorded as transactions
+ALTER TABLE transactions DROP CONSTRAINT IF EXISTS check_transaction_type;
+ALTER TABLE transactions ADD CONSTRAINT check_transaction_type
+    CHECK (transaction_type IN ('DEPOSIT', 'SWAP', 'TRANSFER', 'WITHDRAW', 'SAVINGS_LOCK', 'SAVINGS_UNLOCK', 'INTEREST', 'ORDER_RESERVE', 'ORDER_RELEASE', 'TRADE'));
+
+-- Market data reads executed prices by pair and time
+CREATE INDEX IF NOT EXISTS idx_transactions_swap_pair_created ON transactions(from_currency, to_currency, created_at) WHERE transaction_type = 'SWAP';
+CREATE INDEX IF NOT EXISTS idx_exchange_trades_pair_created ON exchange_trades(pair, created_at);
+
+-- Two-factor authentication: the TOTP secret is pending until a code confirms it, and the last
+-- accepted time step stops a code being used twice
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_secret VARCHAR(64);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_enabled BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_last_step BIGINT NOT NULL DEFAULT 0;
+
+-- Single-use recovery codes, stored as SHA-256 hashes
+CREATE TABLE IF NOT EXISTS user_recovery_codes (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash VARCHAR(64) NOT NULL,
+    used_at TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_recovery_codes_user ON user_recovery_codes(user_id, code_hash) WHERE used_at IS NULL;
+
+-- Login sessions: each is a family of rotating refresh tokens plus the access token last issued
+-- from it, which is denied when the session is revoked
+CREATE TABLE IF NOT EXISTS user_sessions (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    access_jti VARCHAR(36) NOT NULL,
+    access_expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    last_seen_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP NULL,
+    revoked_reason VARCHAR(50) NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_sessions_user_active ON user_sessions(user_id) WHERE revoked_at IS NULL;
+
+-- Refresh tokens, stored as SHA-256 hashes; a used token is kept so presenting it again can be
+-- detected as reuse
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id UUID PRIMARY KEY,
+    session_id UUID NOT NULL REFERENCES user_sessions(id) ON DELETE CASCADE,
+    token_hash VARCHAR(64) UNIQUE NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_session ON refresh_tokens(session_id);
+
+-- Access token denylist; rows can be deleted once the token would have expired anyway
+CREATE TABLE IF NOT EXISTS revoked_tokens (
+    jti VARCHAR(36) PRIMARY KEY,
+    expires_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_revoked_tokens_expires ON revoked_tokens(expires_at);
+
+-- Where each session signed in from, updated on refresh; a user agent not seen before for the
+-- user counts as a new device
+ALTER TABLE user_sessions ADD COLUMN IF NOT EXISTS user_agent TEXT NOT NULL DEFAULT '';
+ALTER TABLE user_sessions ADD COLUMN IF NOT EXISTS ip_address VARCHAR(100) NOT NULL DEFAULT '';
+CREATE INDEX IF NOT EXISTS idx_user_sessions_user_agent ON user_sessions(user_id, user_agent);
+
+-- Staff roles granted on top of the user role every account has; permissions are defined in code
+CREATE TABLE IF NOT EXISTS user_roles (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role VARCHAR(20) NOT NULL,
+    granted_by UUID NULL REFERENCES users(id) ON DELETE SET NULL,
+    granted_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, role),
+    CONSTRAINT check_user_role CHECK (role IN ('support', 'compliance', 'admin'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_roles_role ON user_roles(role);
@@ -8,7 +8,7 @@ export function useLogin() {
   return useMutation({
     mutationFn: ({ email, password }) => authAPI.login(email, password),
     onSuccess: (data) => {
-      login(data.data.user, data.data.token);
+      login(data.data.user, data.data.token, data.data.refresh_token);
     },
   });
 }
@@ -21,7 +21,7 @@ export function useRegister() {
     onSuccess: (data) => {
       // After registration, auto-login if token is returned
       if (data.data?.token) {
-        login(data.data.user, data.data.token);
+        login(data.data.user, data.data.token, data.data.refresh_token);
       }
     },
   });
@@ -1,47 +1,32 @@
-import React, { useState } from 'react';
-import { Shield, Lock, Eye, EyeOff, Clock, MapPin, Monitor } from 'lucide-react';
+import React, { useEffect, useState } from 'react';
+import { Shield, Clock, MapPin, Monitor, User } from 'lucide-react';
 import Layout from '../components/Layout';
-import { userAPI, auditLogsAPI } from '../services/api';
+import { useAuth } from '../context/AuthContext';
+import { auditLogsAPI } from '../services/api';
 
 export default function AuditLogs() {
-  const [password, setPassword] = useState('');
-  const [showPassword, setShowPassword] = useState(false);
-  const [isVerifying, setIsVerifying] = useState(false);
-  const [isVerified, setIsVerified] = useState(false);
+  const { user } = useAuth();
+  const canReadAllLogs = user?.permissions?.includes('audit:read');
   const [error, setError] = useState('');
   const [auditLogs, setAuditLogs] = useState([]);
-  const [isLoading, setIsLoading] = useState(false);
+  const [isLoading, setIsLoading] = useState(true);
 
-  const handleVerify = async (e) => {
-    e.preventDefault();
-    setError('');
-    setIsVerifying(true);
-
-    try {
-      await userAPI.verifyPassword(password);
-      setIsVerified(true);
-      setPassword('');
-
-      // Fetch audit logs after verification
-      await fetchAuditLogs();
-    } catch (err) {
-      setError(err.message || 'Invalid password');
-    } finally {
-      setIsVerifying(false);
-    }
-  };
-
-  const fetchAuditLogs = async () => {
-    setIsLoading(true);
-    try {
-      const response = await auditLogsAPI.getAuditLogs(100, 0);
-      setAuditLogs(response.data || []);
-    } catch (err) {
-      setError('Failed to fetch audit logs');
-    } finally {
-      setIsLoading(false);
-    }
-  };
+  useEffect(() => {
+    const fetchAuditLogs = async () => {
+      setIsLoading(true);
+      try {
+        const response = canReadAllLogs
+          ? await auditLogsAPI.getAllAuditLogs(100, 0)
+          : await auditLogsAPI.getAuditLogs(100, 0);
+        setAuditLogs(response.data || []);
+      } catch (err) {
+        setError(err.message || 'Failed to fetch audit logs');
+      } finally {
+        setIsLoading(false);
+      }
+    };
+    fetchAuditLogs();
+  }, [canReadAllLogs]);
 
   const formatTimestamp = (timestamp) => {
     const date = new Date(timestamp);
@@ -68,73 +53,6 @@ export default function AuditLogs() {
     return colors[operation] || 'bg-gray-100 text-gray-800';
   };
 
-  if (!isVerified) {
-    return (
-      <Layout>
-        <div className="max-w-md mx-auto mt-20">
-          <div className="bg-white rounded-2xl border border-gray-200 p-8 shadow-sm">
-            <div className="flex flex-col items-center mb-6">
-              <div className="w-16 h-16 bg-blue-100 rounded-full flex items-center justify-center mb-4">
-                <Shield className="w-8 h-8 text-blue-600" />
-              </div>
-              <h1 className="text-2xl font-bold text-gray-900 mb-2">Audit Logs</h1>
-              <p className="text-sm text-gray-600 text-center">
-                Enter your password to view your security audit logs
-              </p>
-            </div>
-
-            <form onSubmit={handleVerify} className="space-y-4">
-              <div>
-                <label className="block text-sm font-semibold text-gray-900 mb-2">
-                  Password
-                </label>
-                <div className="relative">
-                  <div className="absolute left-3 top-1/2 -translate-y-1/2">
-                    <Lock className="w-5 h-5 text-gray-400" />
-                  </div>
-                  <input
-                    type={showPassword ? 'text' : 'password'}
-                    value={password}
-                    onChange={(e) => setPassword(e.target.value)}
-                    placeholder="Enter your password"
-                    className="input-field pl-10 pr-12"
-                    disabled={isVerifying}
-                    required
-                  />
-                  <button
-                    type="button"
-                    onClick={() => setShowPassword(!showPassword)}
-                    className="absolute right-3 top-1/2 -translate-y-1/2 text-gray-400 hover:text-gray-600"
-                  >
-                    {showPassword ? (
-                      <EyeOff className="w-5 h-5" />
-                    ) : (
-                      <Eye className="w-5 h-5" />
-                    )}
-                  </button>
-                </div>
-              </div>
-
-              {error && (
-                <div className="p-3 bg-red-50 border border-red-200 rounded-lg">
-                  <p className="text-sm text-red-600">{error}</p>
-                </div>
-              )}
-
-              <button
-                type="submit"
-                className="w-full btn-primary"
-                disabled={isVerifying}
-              >
-                {isVerifying ? 'Verifying...' : 'Verify & View Logs'}
-              </button>
-            </form>
-          </div>
-        </div>
-      </Layout>
-    );
-  }
-
   return (
     <Layout>
       <div className="max-w-7xl mx-auto">
@@ -144,10 +62,18 @@ export default function AuditLogs() {
             <h1 className="text-2xl font-bold text-gray-900">Security Audit Logs</h1>
           </div>
           <p className="text-sm text-gray-600">
-            Track all activities performed on your account for security and compliance
+            {canReadAllLogs
+              ? 'Track activity across the platform for security and compliance'
+              : 'Track all activities performed on your account for security and compliance'}
           </p>
         </div>
 
+        {error && (
+          <div className="p-3 mb-6 bg-red-50 border border-red-200 rounded-lg">
+            <p className="text-sm text-red-600">{error}</p>
+          </div>
+        )}
+
         {isLoading ? (
           <div className="bg-white rounded-2xl border border-gray-200 p-8">
             <div className="animate-pulse space-y-4">
@@ -164,7 +90,7 @@ export default function AuditLogs() {
                 No audit logs yet
               </h3>
               <p className="text-gray-600">
-                Your activity logs will appear here as you use the platform
+                Activity will appear here as the platform is used
               </p>
             </div>
           </div>
@@ -177,6 +103,11 @@ export default function AuditLogs() {
                     <th className="px-6 py-3 text-left text-xs font-semibold text-gray-600 uppercase tracking-wider">
                       Operation
                     </th>
+                    {canReadAllLogs && (
+                      <th className="px-6 py-3 text-left text-xs font-semibold text-gray-600 uppercase tracking-wider">
+                        User
+                      </th>
+                    )}
                     <th className="px-6 py-3 text-left text-xs font-semibold text-gray-600 uppercase tracking-wider">
                       Time
                     </th>
@@ -203,6 +134,14 @@ export default function AuditLogs() {
                           {log.operation}
                         </span>
                       </td>
+                      {canReadAllLogs && (
+                        <td className="px-6 py-4 whitespace-nowrap">
+                          <div className="flex items-center gap-2 text-sm text-gray-900 font-mono">
+                            <User className="w-4 h-4 text-gray-400" />
+                            {log.user_id ? log.user_id.slice(0, 8) : '-'}
+                          </div>
+                        </td>
+                      )}
                       <td className="px-6 py-4 whitespace-nowrap">
                         <div className="flex items-center gap-2 text-sm text-gray-900">
                           <Clock className="w-4 h-4 text-gray-400" />
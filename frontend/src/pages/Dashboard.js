@@ -7,7 +7,6 @@ import SwapModal from "../components/SwapModal";
 import SendModal from "../components/SendModal";
 import ExchangeRatesCard from "../components/ExchangeRatesCard";
 import { useWallet, useBalances, useFxRates } from "../hooks/useWallet";
-import { fxRatesAPI } from "../services/api";
 import { Eye, Plus, ArrowLeftRight, Send, Download } from "lucide-react";
 
 export default function Dashboard() {
@@ -98,7 +97,7 @@ export default function Dashboard() {
   const handleRefreshRates = async () => {
     setIsRefreshingRates(true);
     try {
-      await fxRatesAPI.refreshRates("USD");
+      // Rates are refreshed server-side; forcing an upstream refresh is admin-only
       await refetchFxRates();
     } catch (error) {
       console.error("Failed to refresh rates:", error);
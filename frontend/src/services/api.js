@@ -1,7 +1,50 @@
 const API_BASE_URL = process.env.REACT_APP_API_URL || 'http://localhost:8080';
 
+let refreshPromise = null;
+
+// Exchange the stored refresh token for a new access token. Concurrent callers share one
+// request, since each refresh token can only be used once.
+function refreshSession() {
+  if (!refreshPromise) {
+    refreshPromise = (async () => {
+      const refreshToken = localStorage.getItem('refresh_token');
+      if (!refreshToken) {
+        return false;
+      }
+
+      const response = await fetch(`${API_BASE_URL}/api/auth/refresh`, {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({ refresh_token: refreshToken }),
+      });
+      if (!response.ok) {
+        return false;
+      }
+
+      const data = await response.json();
+      localStorage.setItem('token', data.data.token);
+      localStorage.setItem('refresh_token', data.data.refresh_token);
+      localStorage.setItem('user', JSON.stringify(data.data.user));
+      return true;
+    })()
+      .catch(() => false)
+      .finally(() => {
+        refreshPromise = null;
+      });
+  }
+  return refreshPromise;
+}
+
+// Clear stored auth and send the user back to the login page
+function endSession() {
+  localStorage.removeItem('token');
+  localStorage.removeItem('refresh_token');
+  localStorage.removeItem('user');
+  window.location.href = '/login';
+}
+
 // Generic fetch wrapper with auth
-async function fetchWithAuth(endpoint, options = {}) {
+async function fetchWithAuth(endpoint, options = {}, retry = true) {
   const token = localStorage.getItem('token');
 
   const headers = {
@@ -18,6 +61,14 @@ async function fetchWithAuth(endpoint, options = {}) {
     headers,
   });
 
+  // Access tokens are short-lived; refresh once and retry before giving up on the session
+  if (response.status === 401 && token && retry && !endpoint.startsWith('/api/auth/')) {
+    if (await refreshSession()) {
+      return fetchWithAuth(endpoint, options, false);
+    }
+    endSession();
+  }
+
   // Get response text first
   const text = await response.text();
 
@@ -52,6 +103,10 @@ export const authAPI = {
       body: JSON.stringify({ name, email, password }),
     });
   },
+
+  logout: async () => {
+    return fetchWithAuth('/api/auth/logout', { method: 'POST' });
+  },
 };
 
 // Wallet API
@@ -112,21 +167,16 @@ export const transactionAPI = {
   },
 };
 
-// User API
-export const userAPI = {
-  verifyPassword: async (password) => {
-    return fetchWithAuth('/api/users/verify-password', {
-      method: 'POST',
-      body: JSON.stringify({ password }),
-    });
-  },
-};
-
 // Audit Logs API
 export const auditLogsAPI = {
   getAuditLogs: async (limit = 50, offset = 0) => {
     return fetchWithAuth(`/api/audit-logs?limit=${limit}&offset=${offset}`);
   },
+
+  // Every user's logs; requires the audit:read permission
+  getAllAuditLogs: async (limit = 50, offset = 0) => {
+    return fetchWithAuth(`/api/admin/audit-logs?limit=${limit}&offset=${offset}`);
+  },
 };
 
 // FX Rates API
@@ -147,7 +197,7 @@ export const fxRatesAPI = {
   },
 
   refreshRates: async (baseCurrency = 'USD') => {
-    return fetchWithAuth(`/api/fx-rates/refresh?base=${baseCurrency}`, {
+    return fetchWithAuth(`/api/admin/fx-rates/refresh?base=${baseCurrency}`, {
       method: 'POST',
     });
   },